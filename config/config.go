@@ -13,11 +13,106 @@ type Config struct {
 	Port    int                     `json:"port"`
 	Sensors map[string]SensorConfig `json:"sensors"`
 	Zones   map[string]ZoneConfig   `json:"zones"`
+	// The broker used by mqtt sensors.
+	MQTT *MQTTConfig `json:"mqtt"`
+}
+
+type MQTTConfig struct {
+	// e.g. "tcp://localhost:1883"
+	Broker   string `json:"broker"`
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 type SensorConfig struct {
 	Type string `json:"type"`
 	ID   string `json:"id"`
+	// Readings older than this are treated as stale by any thermostats using
+	// the sensor. Zero disables the check.
+	MaxAgeMinutes int `json:"max_age_minutes"`
+	// The sensors a derived sensor ("mean", "min", "max", "difference" or
+	// "offset") is calculated from.
+	Inputs []string `json:"inputs"`
+	// The constant added to the input of an "offset" sensor.
+	Offset units.Temperature `json:"offset"`
+	// Corrects the readings from the sensor. Adjustments made at runtime take
+	// precedence over this.
+	Calibration *CalibrationConfig `json:"calibration"`
+	// Filters the readings from w1, push, mqtt, http and exec sensors.
+	Filter *FilterConfig `json:"filter"`
+	// The number of times to retry a failed w1 read.
+	ReadRetries *int `json:"read_retries"`
+	// The delay before the first retry of a failed w1 read, doubling for each
+	// subsequent retry.
+	RetryBackoffMilliseconds int `json:"retry_backoff_milliseconds"`
+	// How often polling sensors (e.g. w1) are read. Defaults to 60.
+	PollSeconds int `json:"poll_seconds"`
+	// Each read is delayed by a random amount up to this so that sensors
+	// don't all read at once. Must be less than the poll interval.
+	PollJitterSeconds int `json:"poll_jitter_seconds"`
+	// The topic an mqtt sensor subscribes to.
+	Topic string `json:"topic"`
+	// The dot-separated path to the temperature in °C in the JSON payload of
+	// an mqtt or http sensor. Empty means the payload is the temperature.
+	JSONPath string `json:"json_path"`
+	// The URL polled by an http sensor.
+	URL string `json:"url"`
+	// Basic auth credentials for an http sensor.
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// The timeout for each read of an http or exec sensor. Defaults to 10.
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// Matched against the response of an http sensor, with the first group
+	// being the temperature in °C. Takes precedence over JSONPath.
+	Regexp string `json:"regexp"`
+	// The number of consecutive failed reads after which an http sensor is
+	// treated as stale. Defaults to 3.
+	MaxFailures int `json:"max_failures"`
+	// The program and arguments run by an exec sensor, which prints the
+	// temperature on the last line of its output.
+	Command []string `json:"command"`
+	// The form of an exec sensor's output: "celsius" (the default) or
+	// "millidegrees".
+	Format string `json:"format"`
+	// How long the sensor's readings are kept for the history API.
+	History *HistoryConfig `json:"history"`
+}
+
+// HistoryConfig configures how long a sensor's readings are kept. Any missing
+// values use the defaults.
+type HistoryConfig struct {
+	// Readings are kept at full resolution for this long. Defaults to 48.
+	Hours int `json:"hours"`
+	// Older readings are averaged over intervals of this length. Defaults to
+	// 15.
+	DownsampleMinutes int `json:"downsample_minutes"`
+	// The averaged readings are kept until they're this old. Defaults to 14.
+	DownsampledDays int `json:"downsampled_days"`
+	// Saves the history in the data dir, so that it survives a restart.
+	Persist bool `json:"persist"`
+}
+
+// FilterConfig configures the rejection of spurious readings, and smoothing.
+// Any missing values use the defaults.
+type FilterConfig struct {
+	// Readings with any of these values are rejected. For w1 sensors this
+	// defaults to the DS18B20 error values (85°C and -127°C).
+	RejectValues []units.Temperature `json:"reject_values"`
+	// Zero disables rate of change rejection.
+	MaxRatePerMinute units.Temperature `json:"max_rate_per_minute"`
+	// "exponential" or "moving_average". Empty disables smoothing.
+	Smoothing string `json:"smoothing"`
+	// The weight given to each new reading with exponential smoothing.
+	Alpha float64 `json:"alpha"`
+	// The number of readings averaged with moving average smoothing.
+	Window int `json:"window"`
+}
+
+type CalibrationConfig struct {
+	Offset units.Temperature `json:"offset"`
+	// Zero means no scaling.
+	Scale float64 `json:"scale"`
 }
 
 type ZoneConfig struct {
@@ -27,8 +122,68 @@ type ZoneConfig struct {
 }
 
 type ThermostatConfig struct {
-	Sensor        string            `json:"sensor"`
-	DefaultTarget units.Temperature `json:"default_target"`
+	Sensor string `json:"sensor"`
+	// Several sensors can be given in place of Sensor, with their readings
+	// combined using Aggregation. This is one of "mean" (the default), "min",
+	// "max", "median" or "weighted". Weights default to 1.
+	Sensors       []string           `json:"sensors"`
+	Aggregation   string             `json:"aggregation"`
+	Weights       map[string]float64 `json:"weights"`
+	DefaultTarget units.Temperature  `json:"default_target"`
+	// The zone is forced on whenever the temperature falls below this. Zero
+	// disables frost protection.
+	FrostProtection units.Temperature `json:"frost_protection"`
+	// How far below/above the target the temperature has to go before the
+	// thermostat switches on/off. Defaults to 0.2°C below, and 0 above.
+	LowerHysteresis *units.Temperature `json:"lower_hysteresis"`
+	UpperHysteresis *units.Temperature `json:"upper_hysteresis"`
+	// Switch the zone on early so that the target is reached by the time of
+	// each On event, based on the learned warm up rate.
+	OptimumStart bool               `json:"optimum_start"`
+	OptimumStop  *OptimumStopConfig `json:"optimum_stop"`
+	// What to do when the sensor's readings are stale.
+	Failsafe *FailsafeConfig `json:"failsafe"`
+	// The control mode, either "hysteresis" (the default), "tpi" or "pid".
+	Mode string     `json:"mode"`
+	TPI  *TPIConfig `json:"tpi"`
+	PID  *PIDConfig `json:"pid"`
+}
+
+// TPIConfig configures time-proportional integral control. Any missing values
+// use the defaults.
+type TPIConfig struct {
+	CyclesPerHour    int               `json:"cycles_per_hour"`
+	ProportionalBand units.Temperature `json:"proportional_band"`
+	// Zero disables the integral term.
+	IntegralMinutes *int `json:"integral_minutes"`
+}
+
+// FailsafeConfig configures the fallback used while a thermostat's sensor is
+// stale. Any missing values use the defaults.
+type FailsafeConfig struct {
+	// One of "off" (the default), "duty_cycle" or "schedule".
+	Policy string `json:"policy"`
+	// The fraction of each cycle to be on for with the "duty_cycle" policy.
+	DutyCycle     float64 `json:"duty_cycle"`
+	CyclesPerHour int     `json:"cycles_per_hour"`
+}
+
+// OptimumStopConfig configures switching off early ahead of Off events. Any
+// missing values use the defaults.
+type OptimumStopConfig struct {
+	// The most the zone will be switched off early by. Defaults to 60.
+	MaxMinutes int `json:"max_minutes"`
+	// How far below target the temperature is allowed to fall before the
+	// scheduled Off time. Defaults to 0.5°C.
+	Tolerance units.Temperature `json:"tolerance"`
+}
+
+// PIDConfig configures PID control. Any missing values use the defaults.
+type PIDConfig struct {
+	CyclesPerHour int      `json:"cycles_per_hour"`
+	Kp            *float64 `json:"kp"`
+	Ki            *float64 `json:"ki"`
+	Kd            *float64 `json:"kd"`
 }
 
 func New() *Config {
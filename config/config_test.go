@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -52,8 +53,9 @@ var _ = Describe("Parsing the config data", func() {
 						"id":   "1234",
 					},
 					"bar": {
-						"type": "push",
-						"id":   "2345",
+						"type":            "push",
+						"id":              "2345",
+						"max_age_minutes": 15,
 					},
 				},
 			})
@@ -67,6 +69,182 @@ var _ = Describe("Parsing the config data", func() {
 			Expect(cfg.Sensors["foo"].ID).To(Equal("1234"))
 			Expect(cfg.Sensors["bar"].Type).To(Equal("push"))
 			Expect(cfg.Sensors["bar"].ID).To(Equal("2345"))
+			Expect(cfg.Sensors["bar"].MaxAgeMinutes).To(Equal(15))
+		})
+
+		It("should setup derived sensor details", func() {
+			configReader = createConfigReader(configData{
+				"sensors": map[string]map[string]interface{}{
+					"delta": {
+						"type":   "difference",
+						"inputs": []string{"flow", "return"},
+					},
+					"adjusted": {
+						"type":   "offset",
+						"inputs": []string{"flow"},
+						"offset": -1500,
+					},
+				},
+			})
+
+			cfg, err := config.LoadConfig(configReader)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Sensors["delta"].Type).To(Equal("difference"))
+			Expect(cfg.Sensors["delta"].Inputs).To(Equal([]string{"flow", "return"}))
+			Expect(cfg.Sensors["adjusted"].Offset).To(BeEquivalentTo(-1500))
+		})
+
+		It("should setup sensor calibration", func() {
+			configReader = createConfigReader(configData{
+				"sensors": map[string]map[string]interface{}{
+					"foo": {
+						"type": "w1",
+						"id":   "1234",
+						"calibration": map[string]interface{}{
+							"offset": -300,
+							"scale":  1.02,
+						},
+					},
+				},
+			})
+
+			cfg, err := config.LoadConfig(configReader)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Sensors["foo"].Calibration).To(Equal(&config.CalibrationConfig{Offset: -300, Scale: 1.02}))
+		})
+
+		It("should setup the MQTT broker and sensor details", func() {
+			configReader = createConfigReader(configData{
+				"mqtt": map[string]interface{}{
+					"broker":    "tcp://localhost:1883",
+					"client_id": "heating",
+					"username":  "user",
+					"password":  "secret",
+				},
+				"sensors": map[string]map[string]interface{}{
+					"lounge": {
+						"type":      "mqtt",
+						"topic":     "zigbee2mqtt/lounge",
+						"json_path": "temperature",
+					},
+				},
+			})
+
+			cfg, err := config.LoadConfig(configReader)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.MQTT).To(Equal(&config.MQTTConfig{
+				Broker:   "tcp://localhost:1883",
+				ClientID: "heating",
+				Username: "user",
+				Password: "secret",
+			}))
+			Expect(cfg.Sensors["lounge"].Topic).To(Equal("zigbee2mqtt/lounge"))
+			Expect(cfg.Sensors["lounge"].JSONPath).To(Equal("temperature"))
+		})
+
+		It("should setup http sensor details", func() {
+			configReader = createConfigReader(configData{
+				"sensors": map[string]map[string]interface{}{
+					"outside": {
+						"type":            "http",
+						"url":             "http://weather.local/api",
+						"username":        "user",
+						"password":        "secret",
+						"timeout_seconds": 5,
+						"regexp":          "temp=([0-9.]+)",
+						"max_failures":    4,
+					},
+				},
+			})
+
+			cfg, err := config.LoadConfig(configReader)
+
+			Expect(err).NotTo(HaveOccurred())
+			s := cfg.Sensors["outside"]
+			Expect(s.URL).To(Equal("http://weather.local/api"))
+			Expect(s.Username).To(Equal("user"))
+			Expect(s.Password).To(Equal("secret"))
+			Expect(s.TimeoutSeconds).To(Equal(5))
+			Expect(s.Regexp).To(Equal("temp=([0-9.]+)"))
+			Expect(s.MaxFailures).To(Equal(4))
+		})
+
+		It("should setup exec sensor details", func() {
+			configReader = createConfigReader(configData{
+				"sensors": map[string]map[string]interface{}{
+					"loft": {
+						"type":            "exec",
+						"command":         []string{"/usr/local/bin/read-bme280", "--bus", "1"},
+						"format":          "millidegrees",
+						"timeout_seconds": 5,
+					},
+				},
+			})
+
+			cfg, err := config.LoadConfig(configReader)
+
+			Expect(err).NotTo(HaveOccurred())
+			s := cfg.Sensors["loft"]
+			Expect(s.Command).To(Equal([]string{"/usr/local/bin/read-bme280", "--bus", "1"}))
+			Expect(s.Format).To(Equal("millidegrees"))
+			Expect(s.TimeoutSeconds).To(Equal(5))
+		})
+
+		It("should setup sensor history retention", func() {
+			configReader = createConfigReader(configData{
+				"sensors": map[string]map[string]interface{}{
+					"foo": {
+						"type": "w1",
+						"id":   "1234",
+						"history": map[string]interface{}{
+							"hours":              24,
+							"downsample_minutes": 5,
+							"downsampled_days":   30,
+							"persist":            true,
+						},
+					},
+				},
+			})
+
+			cfg, err := config.LoadConfig(configReader)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Sensors["foo"].History).To(Equal(&config.HistoryConfig{
+				Hours:             24,
+				DownsampleMinutes: 5,
+				DownsampledDays:   30,
+				Persist:           true,
+			}))
+		})
+
+		It("should setup sensor filtering", func() {
+			configReader = createConfigReader(configData{
+				"sensors": map[string]map[string]interface{}{
+					"foo": {
+						"type": "w1",
+						"id":   "1234",
+						"filter": map[string]interface{}{
+							"reject_values":       []int{85000},
+							"max_rate_per_minute": 1000,
+							"smoothing":           "exponential",
+							"alpha":               0.3,
+						},
+					},
+				},
+			})
+
+			cfg, err := config.LoadConfig(configReader)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.Sensors["foo"].Filter).To(Equal(&config.FilterConfig{
+				RejectValues:     []units.Temperature{85000},
+				MaxRatePerMinute: 1000,
+				Smoothing:        "exponential",
+				Alpha:            0.3,
+			}))
 		})
 
 		It("should setup the zone details", func() {
@@ -112,8 +290,20 @@ var _ = Describe("Parsing the config data", func() {
 						"foo": {
 							"gpio_pin": 42,
 							"thermostat": map[string]interface{}{
-								"sensor":         "foo",
-								"default_target": 18000,
+								"sensor":           "foo",
+								"default_target":   18000,
+								"frost_protection": 5000,
+								"lower_hysteresis": 100,
+								"upper_hysteresis": 0,
+								"optimum_start":    true,
+								"failsafe": map[string]interface{}{
+									"policy":     "duty_cycle",
+									"duty_cycle": 0.25,
+								},
+								"optimum_stop": map[string]interface{}{
+									"max_minutes": 45,
+									"tolerance":   300,
+								},
 							},
 						},
 					},
@@ -123,6 +313,86 @@ var _ = Describe("Parsing the config data", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(cfg.Zones["foo"].Thermostat.Sensor).To(Equal("foo"))
 				Expect(cfg.Zones["foo"].Thermostat.DefaultTarget).To(BeNumerically("==", 18000))
+				Expect(cfg.Zones["foo"].Thermostat.FrostProtection).To(BeNumerically("==", 5000))
+				Expect(*cfg.Zones["foo"].Thermostat.LowerHysteresis).To(BeNumerically("==", 100))
+				Expect(*cfg.Zones["foo"].Thermostat.UpperHysteresis).To(BeNumerically("==", 0))
+				Expect(cfg.Zones["foo"].Thermostat.OptimumStart).To(BeTrue())
+				Expect(cfg.Zones["foo"].Thermostat.Failsafe).To(Equal(&config.FailsafeConfig{Policy: "duty_cycle", DutyCycle: 0.25}))
+				Expect(cfg.Zones["foo"].Thermostat.OptimumStop).To(Equal(&config.OptimumStopConfig{MaxMinutes: 45, Tolerance: 300}))
+			})
+
+			It("should add multiple sensors if present", func() {
+				configReader = createConfigReader(configData{
+					"zones": map[string]map[string]interface{}{
+						"foo": {
+							"thermostat": map[string]interface{}{
+								"sensors":     []string{"one", "two"},
+								"aggregation": "weighted",
+								"weights":     map[string]float64{"one": 2},
+							},
+						},
+					},
+				})
+
+				cfg, err := config.LoadConfig(configReader)
+				Expect(err).NotTo(HaveOccurred())
+				tc := cfg.Zones["foo"].Thermostat
+				Expect(tc.Sensors).To(Equal([]string{"one", "two"}))
+				Expect(tc.Aggregation).To(Equal("weighted"))
+				Expect(tc.Weights).To(Equal(map[string]float64{"one": 2}))
+			})
+
+			It("should add TPI details if present", func() {
+				configReader = createConfigReader(configData{
+					"zones": map[string]map[string]interface{}{
+						"foo": {
+							"thermostat": map[string]interface{}{
+								"sensor": "foo",
+								"mode":   "tpi",
+								"tpi": map[string]interface{}{
+									"cycles_per_hour":   4,
+									"proportional_band": 1500,
+									"integral_minutes":  0,
+								},
+							},
+						},
+					},
+				})
+
+				cfg, err := config.LoadConfig(configReader)
+				Expect(err).NotTo(HaveOccurred())
+				tc := cfg.Zones["foo"].Thermostat
+				Expect(tc.Mode).To(Equal("tpi"))
+				Expect(tc.TPI.CyclesPerHour).To(Equal(4))
+				Expect(tc.TPI.ProportionalBand).To(BeNumerically("==", 1500))
+				Expect(*tc.TPI.IntegralMinutes).To(Equal(0))
+			})
+
+			It("should add PID details if present", func() {
+				configReader = createConfigReader(configData{
+					"zones": map[string]map[string]interface{}{
+						"foo": {
+							"thermostat": map[string]interface{}{
+								"sensor": "foo",
+								"mode":   "pid",
+								"pid": map[string]interface{}{
+									"cycles_per_hour": 4,
+									"kp":              0.4,
+									"kd":              0,
+								},
+							},
+						},
+					},
+				})
+
+				cfg, err := config.LoadConfig(configReader)
+				Expect(err).NotTo(HaveOccurred())
+				tc := cfg.Zones["foo"].Thermostat
+				Expect(tc.Mode).To(Equal("pid"))
+				Expect(tc.PID.CyclesPerHour).To(Equal(4))
+				Expect(*tc.PID.Kp).To(Equal(0.4))
+				Expect(tc.PID.Ki).To(BeNil())
+				Expect(*tc.PID.Kd).To(Equal(0.0))
 			})
 
 			It("should set thermostat to nil if no details present", func() {
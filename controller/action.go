@@ -65,3 +65,21 @@ func (ta ThermostatAction) Apply(t thermostat.Thermostat) {
 		}
 	}
 }
+
+// ResultingTarget returns the target that applying this action to a
+// thermostat with the given target would give.
+func (ta ThermostatAction) ResultingTarget(current units.Temperature) units.Temperature {
+	switch ta.Action {
+	case SetTarget:
+		return ta.Param
+	case IncreaseTarget:
+		if current < ta.Param {
+			return ta.Param
+		}
+	case DecreaseTarget:
+		if current > ta.Param {
+			return ta.Param
+		}
+	}
+	return current
+}
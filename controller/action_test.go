@@ -100,4 +100,16 @@ var _ = Describe("ThermostatAction", func() {
 			action:    &controller.ThermostatAction{Action: controller.On},
 		}),
 	)
+
+	DescribeTable("calculating the resulting target",
+		func(initial units.Temperature, action controller.ThermostatAction, expected units.Temperature) {
+			Expect(action.ResultingTarget(initial)).To(Equal(expected))
+		},
+		Entry("SetTarget", units.Temperature(19500), controller.ThermostatAction{Action: controller.SetTarget, Param: 19000}, units.Temperature(19000)),
+		Entry("IncreaseTarget when lower", units.Temperature(18500), controller.ThermostatAction{Action: controller.IncreaseTarget, Param: 19000}, units.Temperature(19000)),
+		Entry("IncreaseTarget when higher", units.Temperature(19500), controller.ThermostatAction{Action: controller.IncreaseTarget, Param: 19000}, units.Temperature(19500)),
+		Entry("DecreaseTarget when higher", units.Temperature(19500), controller.ThermostatAction{Action: controller.DecreaseTarget, Param: 19000}, units.Temperature(19000)),
+		Entry("DecreaseTarget when lower", units.Temperature(18500), controller.ThermostatAction{Action: controller.DecreaseTarget, Param: 19000}, units.Temperature(18500)),
+		Entry("an unexpected action", units.Temperature(18500), controller.ThermostatAction{Action: controller.On}, units.Temperature(18500)),
+	)
 })
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alext/heating-controller/units"
+)
+
+var ErrInvalidAwayMode = errors.New("invalid away mode")
+
+const awayTimeFormat = "2006-01-02 15:04"
+
+// AwayMode suspends the scheduled heating in all zones between Start and End.
+// If Target is set, any zones with a thermostat will hold that target (e.g.
+// for frost protection) instead.
+type AwayMode struct {
+	Start  time.Time          `json:"start"`
+	End    time.Time          `json:"end"`
+	Target *units.Temperature `json:"target,omitempty"`
+}
+
+func (a AwayMode) Valid() bool {
+	return !a.Start.IsZero() && a.End.After(a.Start)
+}
+
+// ActiveAt returns whether the given time is within the away period.
+func (a AwayMode) ActiveAt(t time.Time) bool {
+	return !t.Before(a.Start) && t.Before(a.End)
+}
+
+func (a AwayMode) String() string {
+	str := fmt.Sprintf("%s until %s", a.Start.Local().Format(awayTimeFormat), a.End.Local().Format(awayTimeFormat))
+	if a.Target != nil {
+		str += fmt.Sprintf(", holding %s", a.Target)
+	}
+	return str
+}
+
+// SetAway sets the away mode, replacing any existing one.
+func (c *Controller) SetAway(a AwayMode) error {
+	if !a.Valid() || !a.End.After(timeNow()) {
+		return ErrInvalidAwayMode
+	}
+	c.awayLock.Lock()
+	defer c.awayLock.Unlock()
+	log.Printf("[Controller] Setting away mode: %s", a)
+	c.away = &a
+	c.updateAway()
+	return c.saveAway()
+}
+
+func (c *Controller) CancelAway() error {
+	c.awayLock.Lock()
+	defer c.awayLock.Unlock()
+	log.Printf("[Controller] Cancelling away mode")
+	c.away = nil
+	c.updateAway()
+	return c.saveAway()
+}
+
+// Away returns the current away mode, or nil if none is set.
+func (c *Controller) Away() *AwayMode {
+	c.awayLock.Lock()
+	defer c.awayLock.Unlock()
+	if c.away == nil {
+		return nil
+	}
+	a := *c.away
+	return &a
+}
+
+func (c *Controller) awayTransition() {
+	c.awayLock.Lock()
+	defer c.awayLock.Unlock()
+	c.updateAway()
+}
+
+// updateAway applies the current away state to all the zones, and schedules
+// the next transition.
+//
+// Must be called with the awayLock held.
+func (c *Controller) updateAway() {
+	if c.awayTimer != nil {
+		c.awayTimer.Stop()
+		c.awayTimer = nil
+	}
+	now := timeNow()
+	if c.away != nil && !now.Before(c.away.End) {
+		log.Printf("[Controller] Away mode ended")
+		c.away = nil
+		c.saveAway()
+	}
+
+	active := c.away != nil && c.away.ActiveAt(now)
+	var target *units.Temperature
+	if active {
+		target = c.away.Target
+	}
+	for _, z := range c.Zones {
+		z.setAway(active, target)
+	}
+
+	if c.away != nil {
+		next := c.away.End
+		if now.Before(c.away.Start) {
+			next = c.away.Start
+		}
+		c.awayTimer = afterFunc(next.Sub(now), c.awayTransition)
+	}
+}
+
+func awayFilename() string {
+	return filepath.Join(DataDir, "away.json")
+}
+
+// Must be called with the awayLock held.
+func (c *Controller) saveAway() error {
+	if c.away == nil {
+		err := os.Remove(awayFilename())
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("[Controller] Error removing away mode state: %s", err.Error())
+			return err
+		}
+		return nil
+	}
+
+	err := saveJSONFile(awayFilename(), c.away)
+	if err != nil {
+		log.Printf("[Controller] Error saving away mode state: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) restoreAway() error {
+	file, err := os.Open(awayFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var a AwayMode
+	err = json.NewDecoder(file).Decode(&a)
+	if err != nil {
+		// Carry on without it rather than failing to start.
+		log.Printf("[Controller] Discarding saved away mode state, error parsing: %s", err.Error())
+		return nil
+	}
+
+	c.awayLock.Lock()
+	defer c.awayLock.Unlock()
+	c.away = &a
+	c.updateAway()
+	return nil
+}
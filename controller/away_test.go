@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/thermostat/thermostatfakes"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("Away mode", func() {
+	var (
+		ctrl *Controller
+
+		timerDuration time.Duration
+		timerFunc     func()
+
+		z1, z2 *Zone
+		out1   output.Output
+		therm  *thermostatfakes.FakeThermostat
+		target units.Temperature = 7000
+	)
+
+	BeforeEach(func() {
+		var err error
+		DataDir, err = ioutil.TempDir("", "away_test")
+		Expect(err).NotTo(HaveOccurred())
+
+		clock.Set(fridayAt(12, 0, 0))
+		timerDuration, timerFunc = 0, nil
+		afterFunc = func(d time.Duration, f func()) *time.Timer {
+			timerDuration, timerFunc = d, f
+			// A timer that will never fire so that Stop can be called on it.
+			return time.NewTimer(time.Hour)
+		}
+
+		ctrl = New()
+		out1 = output.Virtual("one")
+		z1 = NewZone("one", out1)
+		therm = new(thermostatfakes.FakeThermostat)
+		therm.TargetReturns(19000)
+		z1.Thermostat = therm
+		z2 = NewZone("two", output.Virtual("two"))
+		ctrl.AddZone(z1)
+		ctrl.AddZone(z2)
+		z1.applyEvent(Event{Action: On})
+		z2.applyEvent(Event{Action: On})
+	})
+
+	AfterEach(func() {
+		afterFunc = time.AfterFunc
+		os.RemoveAll(DataDir)
+	})
+
+	Describe("setting away mode", func() {
+		It("rejects an invalid away mode", func() {
+			Expect(ctrl.SetAway(AwayMode{})).To(Equal(ErrInvalidAwayMode))
+			Expect(ctrl.SetAway(AwayMode{Start: clock.Now(), End: clock.Now().Add(-time.Hour)})).To(Equal(ErrInvalidAwayMode))
+			Expect(ctrl.Away()).To(BeNil())
+		})
+
+		It("rejects an away mode that has already ended", func() {
+			a := AwayMode{Start: clock.Now().Add(-48 * time.Hour), End: clock.Now().Add(-time.Hour)}
+			Expect(ctrl.SetAway(a)).To(Equal(ErrInvalidAwayMode))
+		})
+
+		It("puts all the zones into away mode when the away period has started", func() {
+			Expect(ctrl.SetAway(AwayMode{Start: clock.Now().Add(-time.Hour), End: clock.Now().Add(72 * time.Hour)})).To(Succeed())
+
+			Expect(z1.Away()).To(BeTrue())
+			Expect(z2.Away()).To(BeTrue())
+			Expect(z1.Active()).To(BeFalse())
+			Expect(z2.Active()).To(BeFalse())
+		})
+
+		It("holds the away target in zones with a thermostat", func() {
+			Expect(ctrl.SetAway(AwayMode{Start: clock.Now(), End: clock.Now().Add(72 * time.Hour), Target: &target})).To(Succeed())
+
+			Expect(therm.SetCallCount()).To(Equal(1))
+			Expect(therm.SetArgsForCall(0)).To(Equal(target))
+			Expect(z1.Active()).To(BeTrue())
+			Expect(z2.Active()).To(BeFalse())
+		})
+
+		It("does nothing to the zones until the away period starts", func() {
+			Expect(ctrl.SetAway(AwayMode{Start: clock.Now().Add(2 * time.Hour), End: clock.Now().Add(72 * time.Hour)})).To(Succeed())
+
+			Expect(z1.Away()).To(BeFalse())
+			Expect(z1.Active()).To(BeTrue())
+
+			Expect(timerDuration).To(Equal(2 * time.Hour))
+			clock.Add(2 * time.Hour)
+			timerFunc()
+
+			Expect(z1.Away()).To(BeTrue())
+			Expect(z1.Active()).To(BeFalse())
+		})
+
+		It("restores normal behaviour when the away period ends", func() {
+			Expect(ctrl.SetAway(AwayMode{Start: clock.Now(), End: clock.Now().Add(72 * time.Hour), Target: &target})).To(Succeed())
+
+			Expect(timerDuration).To(Equal(72 * time.Hour))
+			clock.Add(72 * time.Hour)
+			timerFunc()
+
+			Expect(ctrl.Away()).To(BeNil())
+			Expect(z1.Away()).To(BeFalse())
+			Expect(z2.Away()).To(BeFalse())
+			Expect(z2.Active()).To(BeTrue())
+			Expect(therm.SetCallCount()).To(Equal(2))
+			Expect(therm.SetArgsForCall(1)).To(Equal(units.Temperature(19000)))
+		})
+
+		It("returns a copy of the away mode", func() {
+			a := AwayMode{Start: clock.Now(), End: clock.Now().Add(72 * time.Hour), Target: &target}
+			Expect(ctrl.SetAway(a)).To(Succeed())
+			Expect(ctrl.Away()).To(Equal(&a))
+		})
+	})
+
+	Describe("cancelling away mode", func() {
+		BeforeEach(func() {
+			ctrl.SetAway(AwayMode{Start: clock.Now(), End: clock.Now().Add(72 * time.Hour), Target: &target})
+		})
+
+		It("restores normal behaviour", func() {
+			Expect(ctrl.CancelAway()).To(Succeed())
+
+			Expect(ctrl.Away()).To(BeNil())
+			Expect(z1.Away()).To(BeFalse())
+			Expect(z2.Active()).To(BeTrue())
+			Expect(therm.SetArgsForCall(1)).To(Equal(units.Temperature(19000)))
+		})
+
+		It("removes the saved state", func() {
+			Expect(ctrl.CancelAway()).To(Succeed())
+
+			_, err := os.Stat(filepath.Join(DataDir, "away.json"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Describe("persistence", func() {
+		It("saves the away mode to the data dir", func() {
+			Expect(ctrl.SetAway(AwayMode{
+				Start:  time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC),
+				End:    time.Date(2018, 10, 5, 18, 0, 0, 0, time.UTC),
+				Target: &target,
+			})).To(Succeed())
+
+			data := readFile(filepath.Join(DataDir, "away.json"))
+			Expect(data).To(MatchJSON(`{"start":"2018-09-28T12:00:00Z","end":"2018-10-05T18:00:00Z","target":7000}`))
+		})
+
+		It("restores the away mode when setting up the controller", func() {
+			writeJSONToFile(filepath.Join(DataDir, "away.json"), map[string]interface{}{
+				"start": clock.Now().Add(-time.Hour),
+				"end":   clock.Now().Add(time.Hour),
+			})
+			ctrl = New()
+			cfg := config.New()
+			cfg.Zones["ch"] = config.ZoneConfig{Virtual: true}
+			Expect(ctrl.Setup(cfg)).To(Succeed())
+			defer ctrl.Zones["ch"].Scheduler.Stop()
+
+			Expect(ctrl.Away()).NotTo(BeNil())
+			Expect(ctrl.Zones["ch"].Away()).To(BeTrue())
+		})
+
+		It("discards a saved away mode that can't be parsed", func() {
+			Expect(ioutil.WriteFile(filepath.Join(DataDir, "away.json"), []byte(`{"start":`), 0644)).To(Succeed())
+			ctrl = New()
+			cfg := config.New()
+			cfg.Zones["ch"] = config.ZoneConfig{Virtual: true}
+			Expect(ctrl.Setup(cfg)).To(Succeed())
+			defer ctrl.Zones["ch"].Scheduler.Stop()
+
+			Expect(ctrl.Away()).To(BeNil())
+			Expect(ctrl.Zones["ch"].Away()).To(BeFalse())
+		})
+
+		It("doesn't leave a partially written file in place of the saved state", func() {
+			Expect(ctrl.SetAway(AwayMode{Start: clock.Now(), End: clock.Now().Add(time.Hour)})).To(Succeed())
+			Expect(filepath.Join(DataDir, "away.json.tmp")).NotTo(BeAnExistingFile())
+
+			// Make the temporary file impossible to create.
+			Expect(os.Mkdir(filepath.Join(DataDir, "away.json.tmp"), 0755)).To(Succeed())
+			Expect(ctrl.SetAway(AwayMode{Start: clock.Now(), End: clock.Now().Add(2 * time.Hour)})).NotTo(Succeed())
+			Expect(readFile(filepath.Join(DataDir, "away.json"))).To(ContainSubstring(`"end": "2018-09-28T13:00:00Z"`))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/sensor"
+)
+
+var ErrInvalidCalibration = errors.New("invalid sensor calibration")
+
+func calibrationFromConfig(cfg *config.CalibrationConfig) sensor.Calibration {
+	return sensor.Calibration{Offset: cfg.Offset, Scale: cfg.Scale}
+}
+
+// SetSensorCalibration sets the calibration of the named sensor, and saves it
+// so that it takes precedence over the configured calibration.
+func (c *Controller) SetSensorCalibration(name string, cal sensor.Calibration) error {
+	if !cal.Valid() {
+		return ErrInvalidCalibration
+	}
+	s, _ := c.Sensor(name)
+	cs, ok := s.(sensor.CalibratedSensor)
+	if !ok {
+		return fmt.Errorf("Sensor '%s' doesn't support calibration", name)
+	}
+	cs.SetCalibration(cal)
+
+	c.calibrationLock.Lock()
+	defer c.calibrationLock.Unlock()
+	if c.calibrations == nil {
+		c.calibrations = make(map[string]sensor.Calibration)
+	}
+	c.calibrations[name] = cal
+	return c.saveCalibrations()
+}
+
+func calibrationsFilename() string {
+	return filepath.Join(DataDir, "sensor_calibrations.json")
+}
+
+// Must be called with the calibrationLock held.
+func (c *Controller) saveCalibrations() error {
+	err := saveJSONFile(calibrationsFilename(), c.calibrations)
+	if err != nil {
+		log.Printf("[Controller] Error saving sensor calibrations: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) restoreCalibrations() error {
+	file, err := os.Open(calibrationsFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var calibrations map[string]sensor.Calibration
+	err = json.NewDecoder(file).Decode(&calibrations)
+	if err != nil {
+		// Carry on with the configured calibrations rather than failing to start.
+		log.Printf("[Controller] Discarding saved sensor calibrations, error parsing: %s", err.Error())
+		return nil
+	}
+
+	c.calibrationLock.Lock()
+	defer c.calibrationLock.Unlock()
+	c.calibrations = calibrations
+	for name, cal := range calibrations {
+		s, _ := c.Sensor(name)
+		cs, ok := s.(sensor.CalibratedSensor)
+		if !ok || !cal.Valid() {
+			log.Printf("[Controller] Ignoring saved calibration for sensor '%s'", name)
+			continue
+		}
+		cs.SetCalibration(cal)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/sensor"
+)
+
+var _ = Describe("Sensor calibration", func() {
+	var (
+		ctrl *Controller
+		cfg  *config.Config
+	)
+
+	BeforeEach(func() {
+		var err error
+		DataDir, err = ioutil.TempDir("", "calibration_test")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg = config.New()
+		cfg.Sensors["foo"] = config.SensorConfig{
+			Type:        "push",
+			ID:          "1234",
+			Calibration: &config.CalibrationConfig{Offset: -300, Scale: 1.01},
+		}
+		cfg.Sensors["bar"] = config.SensorConfig{Type: "push", ID: "2345"}
+		ctrl = New()
+		Expect(ctrl.Setup(cfg)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(DataDir)
+	})
+
+	It("applies the configured calibration", func() {
+		s := ctrl.sensorsByName["foo"].(sensor.CalibratedSensor)
+		Expect(s.Calibration()).To(Equal(sensor.Calibration{Offset: -300, Scale: 1.01}))
+		Expect(ctrl.sensorsByName["bar"].(sensor.CalibratedSensor).Calibration()).To(Equal(sensor.Calibration{}))
+	})
+
+	It("errors with an invalid configured calibration", func() {
+		cfg.Sensors["foo"] = config.SensorConfig{
+			Type:        "push",
+			Calibration: &config.CalibrationConfig{Scale: -1},
+		}
+		Expect(New().Setup(cfg)).To(MatchError("Invalid calibration for sensor 'foo'"))
+	})
+
+	Describe("adjusting the calibration", func() {
+		It("sets the calibration for the sensor", func() {
+			Expect(ctrl.SetSensorCalibration("bar", sensor.Calibration{Offset: 500})).To(Succeed())
+
+			s := ctrl.sensorsByName["bar"].(sensor.SettableSensor)
+			s.Set(19000, time.Now())
+			temp, _ := s.Read()
+			Expect(temp).To(BeEquivalentTo(19500))
+		})
+
+		It("rejects an invalid calibration", func() {
+			Expect(ctrl.SetSensorCalibration("bar", sensor.Calibration{Scale: -2})).To(Equal(ErrInvalidCalibration))
+			Expect(ctrl.sensorsByName["bar"].(sensor.CalibratedSensor).Calibration()).To(Equal(sensor.Calibration{}))
+		})
+
+		It("errors for a non-existent sensor", func() {
+			Expect(ctrl.SetSensorCalibration("non-existent", sensor.Calibration{})).NotTo(Succeed())
+		})
+
+		It("saves the calibration, taking precedence over the config on restore", func() {
+			Expect(ctrl.SetSensorCalibration("foo", sensor.Calibration{Offset: 200})).To(Succeed())
+
+			Expect(readFile(filepath.Join(DataDir, "sensor_calibrations.json"))).To(MatchJSON(`{"foo":{"offset":200}}`))
+
+			ctrl2 := New()
+			Expect(ctrl2.Setup(cfg)).To(Succeed())
+			Expect(ctrl2.sensorsByName["foo"].(sensor.CalibratedSensor).Calibration()).To(Equal(sensor.Calibration{Offset: 200}))
+			Expect(ctrl2.sensorsByName["bar"].(sensor.CalibratedSensor).Calibration()).To(Equal(sensor.Calibration{}))
+		})
+
+		It("doesn't leave a temporary file behind after saving", func() {
+			Expect(ctrl.SetSensorCalibration("foo", sensor.Calibration{Offset: 200})).To(Succeed())
+			Expect(filepath.Join(DataDir, "sensor_calibrations.json.tmp")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	It("falls back to the configured calibration if the saved calibrations can't be parsed", func() {
+		Expect(ioutil.WriteFile(filepath.Join(DataDir, "sensor_calibrations.json"), []byte(`{"foo":`), 0644)).To(Succeed())
+
+		ctrl2 := New()
+		Expect(ctrl2.Setup(cfg)).To(Succeed())
+		Expect(ctrl2.sensorsByName["foo"].(sensor.CalibratedSensor).Calibration()).To(Equal(sensor.Calibration{Offset: -300, Scale: 1.01}))
+
+		Expect(ctrl2.SetSensorCalibration("bar", sensor.Calibration{Offset: 100})).To(Succeed())
+		Expect(readFile(filepath.Join(DataDir, "sensor_calibrations.json"))).To(MatchJSON(`{"bar":{"offset":100}}`))
+	})
+})
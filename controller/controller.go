@@ -2,44 +2,144 @@ package controller
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/alext/heating-controller/config"
 	"github.com/alext/heating-controller/output"
 	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/thermostat"
+	"github.com/alext/heating-controller/units"
 )
 
 type Controller struct {
-	SensorsByName map[string]sensor.Sensor
-	SensorsByID   map[string]sensor.Sensor
-	Zones         map[string]*Zone
+	Zones map[string]*Zone
+
+	awayLock  sync.Mutex
+	away      *AwayMode
+	awayTimer *time.Timer
+
+	sensorsLock   sync.RWMutex
+	sensorsByName map[string]sensor.Sensor
+	sensorsByID   map[string]sensor.Sensor
+	// Sensors adopted at runtime.
+	adopted map[string]config.SensorConfig
+
+	calibrationLock sync.Mutex
+	// Calibrations adjusted at runtime.
+	calibrations map[string]sensor.Calibration
+
+	historyLock sync.Mutex
+	// Sensors whose history is saved in the DataDir.
+	persistHistory []string
+
+	timersLock   sync.Mutex
+	closed       bool
+	saveTimer    *time.Timer
+	historyTimer *time.Timer
 }
 
 func New() *Controller {
 	return &Controller{
-		SensorsByName: make(map[string]sensor.Sensor),
-		SensorsByID:   make(map[string]sensor.Sensor),
+		sensorsByName: make(map[string]sensor.Sensor),
+		sensorsByID:   make(map[string]sensor.Sensor),
 		Zones:         make(map[string]*Zone),
 	}
 }
 
 func (c *Controller) AddSensor(name string, s sensor.Sensor) {
-	c.SensorsByName[name] = s
-	c.SensorsByID[s.ID()] = s
+	c.sensorsLock.Lock()
+	defer c.sensorsLock.Unlock()
+	c.addSensor(name, s)
+}
+
+// Must be called with the sensorsLock held for writing.
+func (c *Controller) addSensor(name string, s sensor.Sensor) {
+	c.sensorsByName[name] = s
+	c.sensorsByID[s.ID()] = s
+}
+
+// Sensor returns the sensor with the given name.
+func (c *Controller) Sensor(name string) (sensor.Sensor, bool) {
+	c.sensorsLock.RLock()
+	defer c.sensorsLock.RUnlock()
+	s, ok := c.sensorsByName[name]
+	return s, ok
+}
+
+// SensorByID returns the sensor with the given ID.
+func (c *Controller) SensorByID(id string) (sensor.Sensor, bool) {
+	c.sensorsLock.RLock()
+	defer c.sensorsLock.RUnlock()
+	s, ok := c.sensorsByID[id]
+	return s, ok
+}
+
+// Sensors returns all the sensors keyed by name.
+func (c *Controller) Sensors() map[string]sensor.Sensor {
+	c.sensorsLock.RLock()
+	defer c.sensorsLock.RUnlock()
+	sensors := make(map[string]sensor.Sensor, len(c.sensorsByName))
+	for name, s := range c.sensorsByName {
+		sensors[name] = s
+	}
+	return sensors
 }
 
 func (c *Controller) AddZone(z *Zone) {
 	c.Zones[z.ID] = z
 }
 
-var outputNew = output.New // variable indirection to facilitate testing
+// Close stops the controller's periodic saves, and closes all of its zones.
+func (c *Controller) Close() {
+	c.timersLock.Lock()
+	c.closed = true
+	for _, t := range []*time.Timer{c.saveTimer, c.historyTimer} {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	c.timersLock.Unlock()
+
+	for _, z := range c.Zones {
+		z.Close()
+	}
+}
+
+// variable indirection to facilitate testing
+var (
+	outputNew   = output.New
+	sensorNew   = sensor.New
+	mqttConnect = sensor.ConnectMQTT
+)
 
 func (c *Controller) Setup(cfg *config.Config) error {
+	if cfg.MQTT != nil {
+		mqttConnect(cfg.MQTT)
+	}
+
+	sensors := make(map[string]config.SensorConfig, len(cfg.Sensors))
 	for name, sensorConfig := range cfg.Sensors {
-		s, err := sensor.New(name, sensorConfig)
-		if err != nil {
-			return err
-		}
-		c.AddSensor(name, s)
+		sensors[name] = sensorConfig
+	}
+	err := c.restoreAdoptedSensors(sensors)
+	if err != nil {
+		return err
+	}
+	persistHistory := persistedHistorySensors(sensors)
+	err = c.setupSensors(sensors)
+	if err != nil {
+		return err
+	}
+	err = c.restoreCalibrations()
+	if err != nil {
+		return err
+	}
+	err = c.setupHistoryPersistence(persistHistory)
+	if err != nil {
+		return err
 	}
 
 	for name, zoneConfig := range cfg.Zones {
@@ -55,16 +155,243 @@ func (c *Controller) Setup(cfg *config.Config) error {
 		}
 		z := NewZone(name, out)
 		if zoneConfig.Thermostat != nil {
-			s, ok := c.SensorsByName[zoneConfig.Thermostat.Sensor]
-			if !ok {
-				return fmt.Errorf("Non-existent sensor '%s' for zone '%s'", zoneConfig.Thermostat.Sensor, name)
+			s, maxAge, err := c.thermostatSource(name, cfg, zoneConfig.Thermostat)
+			if err != nil {
+				return err
+			}
+			if len(zoneConfig.Thermostat.Sensors) > 0 {
+				// The combined sensor was created just for this zone.
+				z.ownSensor(s)
+			}
+			switch zoneConfig.Thermostat.Mode {
+			case "", "hysteresis":
+				z.SetupThermostat(s, zoneConfig.Thermostat.DefaultTarget)
+			case "tpi":
+				z.SetupTPIThermostat(s, zoneConfig.Thermostat.DefaultTarget, tpiSettingsFromConfig(zoneConfig.Thermostat.TPI))
+			case "pid":
+				z.SetupPIDThermostat(s, zoneConfig.Thermostat.DefaultTarget, pidSettingsFromConfig(zoneConfig.Thermostat.PID))
+			default:
+				return fmt.Errorf("Unrecognised thermostat mode '%s' for zone '%s'", zoneConfig.Thermostat.Mode, name)
+			}
+			if h, ok := hysteresisFromConfig(zoneConfig.Thermostat); ok {
+				if !h.Valid() {
+					return fmt.Errorf("Invalid thermostat hysteresis for zone '%s'", name)
+				}
+				z.setConfiguredHysteresis(h)
+			}
+			if zoneConfig.Thermostat.FrostProtection != 0 {
+				z.SetupFrostProtection(s, zoneConfig.Thermostat.FrostProtection)
+			}
+			if zoneConfig.Thermostat.OptimumStart {
+				z.SetupOptimumStart(s)
+			}
+			if maxAge > 0 {
+				err := z.SetupStaleFailsafe(s, maxAge, stalePolicyFromConfig(zoneConfig.Thermostat.Failsafe))
+				if err != nil {
+					return fmt.Errorf("Invalid failsafe for zone '%s': %w", name, err)
+				}
+			}
+			if zoneConfig.Thermostat.OptimumStop != nil {
+				maxEarly, tolerance := optimumStopFromConfig(zoneConfig.Thermostat.OptimumStop)
+				z.SetupOptimumStop(s, maxEarly, tolerance)
 			}
-			z.SetupThermostat(s, zoneConfig.Thermostat.DefaultTarget)
 		}
 		z.Restore()
 		z.Scheduler.Start()
 		c.AddZone(z)
 	}
+	if len(cfg.Zones) > 0 {
+		c.saveZonesPeriodically()
+	}
+
+	err = c.restoreAway()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
 
+// setupSensors creates the given sensors, removing them from the map as it
+// goes. Derived sensors are created once all of their inputs exist.
+func (c *Controller) setupSensors(sensors map[string]config.SensorConfig) error {
+	pending := sensors
+	for len(pending) > 0 {
+		created := false
+		for name, sensorConfig := range pending {
+			if !c.hasSensors(sensorConfig.Inputs) {
+				continue
+			}
+			s, err := sensorNew(name, sensorConfig, c.Sensors())
+			if err != nil {
+				return err
+			}
+			if sensorConfig.Calibration != nil {
+				cal := calibrationFromConfig(sensorConfig.Calibration)
+				if !cal.Valid() {
+					return fmt.Errorf("Invalid calibration for sensor '%s'", name)
+				}
+				if cs, ok := s.(sensor.CalibratedSensor); ok {
+					cs.SetCalibration(cal)
+				}
+			}
+			c.AddSensor(name, s)
+			delete(pending, name)
+			created = true
+		}
+		if !created {
+			names := make([]string, 0, len(pending))
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("Missing or circular inputs for sensors: %s", strings.Join(names, ", "))
+		}
+	}
 	return nil
 }
+
+func (c *Controller) hasSensors(names []string) bool {
+	for _, name := range names {
+		if _, ok := c.Sensor(name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tpiSettingsFromConfig returns the TPI settings given in the config, using
+// the defaults for any missing values.
+func tpiSettingsFromConfig(cfg *config.TPIConfig) thermostat.TPISettings {
+	settings := thermostat.DefaultTPISettings
+	if cfg == nil {
+		return settings
+	}
+	if cfg.CyclesPerHour > 0 {
+		settings.CycleLength = time.Hour / time.Duration(cfg.CyclesPerHour)
+	}
+	if cfg.ProportionalBand > 0 {
+		settings.ProportionalBand = cfg.ProportionalBand
+	}
+	if cfg.IntegralMinutes != nil {
+		settings.IntegralTime = time.Duration(*cfg.IntegralMinutes) * time.Minute
+	}
+	return settings
+}
+
+// thermostatSource returns the sensor for a zone's thermostat, combining
+// multiple sensors if configured. Also returns the maximum reading age for the
+// sensor, or zero if the sensor doesn't have one.
+func (c *Controller) thermostatSource(zone string, cfg *config.Config, tc *config.ThermostatConfig) (sensor.Sensor, time.Duration, error) {
+	if len(tc.Sensors) == 0 {
+		s, ok := c.Sensor(tc.Sensor)
+		if !ok {
+			return nil, 0, fmt.Errorf("Non-existent sensor '%s' for zone '%s'", tc.Sensor, zone)
+		}
+		return s, time.Duration(cfg.Sensors[tc.Sensor].MaxAgeMinutes) * time.Minute, nil
+	}
+
+	var (
+		inputs []sensor.AggregateInput
+		maxAge time.Duration
+	)
+	for _, name := range tc.Sensors {
+		s, ok := c.Sensor(name)
+		if !ok {
+			return nil, 0, fmt.Errorf("Non-existent sensor '%s' for zone '%s'", name, zone)
+		}
+		weight, ok := tc.Weights[name]
+		if !ok {
+			weight = 1
+		}
+		in := sensor.AggregateInput{
+			Name:   name,
+			Sensor: s,
+			Weight: weight,
+			MaxAge: time.Duration(cfg.Sensors[name].MaxAgeMinutes) * time.Minute,
+		}
+		if in.MaxAge > maxAge {
+			maxAge = in.MaxAge
+		}
+		inputs = append(inputs, in)
+	}
+	aggregation := sensor.AggregateMean
+	if tc.Aggregation != "" {
+		aggregation = sensor.Aggregation(tc.Aggregation)
+	}
+	s, err := sensor.NewAggregateSensor(zone+"-thermostat", aggregation, inputs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid thermostat sensors for zone '%s': %w", zone, err)
+	}
+	return s, maxAge, nil
+}
+
+// stalePolicyFromConfig returns the stale sensor policy given in the config,
+// using the defaults for any missing values.
+func stalePolicyFromConfig(cfg *config.FailsafeConfig) StalePolicy {
+	policy := DefaultStalePolicy
+	if cfg == nil {
+		return policy
+	}
+	if cfg.Policy != "" {
+		policy.Mode = StaleMode(cfg.Policy)
+	}
+	if cfg.DutyCycle != 0 {
+		policy.DutyCycle = cfg.DutyCycle
+	}
+	if cfg.CyclesPerHour > 0 {
+		policy.CycleLength = time.Hour / time.Duration(cfg.CyclesPerHour)
+	}
+	return policy
+}
+
+// optimumStopFromConfig returns the maximum early stop and tolerance given in
+// the config, using the defaults for any missing values.
+func optimumStopFromConfig(cfg *config.OptimumStopConfig) (time.Duration, units.Temperature) {
+	maxEarly, tolerance := time.Hour, units.Temperature(500)
+	if cfg.MaxMinutes > 0 {
+		maxEarly = time.Duration(cfg.MaxMinutes) * time.Minute
+	}
+	if cfg.Tolerance > 0 {
+		tolerance = cfg.Tolerance
+	}
+	return maxEarly, tolerance
+}
+
+// pidSettingsFromConfig returns the PID settings given in the config, using
+// the defaults for any missing values.
+func pidSettingsFromConfig(cfg *config.PIDConfig) thermostat.PIDSettings {
+	settings := thermostat.DefaultPIDSettings
+	if cfg == nil {
+		return settings
+	}
+	if cfg.CyclesPerHour > 0 {
+		settings.CycleLength = time.Hour / time.Duration(cfg.CyclesPerHour)
+	}
+	if cfg.Kp != nil {
+		settings.Kp = *cfg.Kp
+	}
+	if cfg.Ki != nil {
+		settings.Ki = *cfg.Ki
+	}
+	if cfg.Kd != nil {
+		settings.Kd = *cfg.Kd
+	}
+	return settings
+}
+
+// hysteresisFromConfig returns the hysteresis given in the config, using the
+// defaults for any missing values. Returns false if none are given.
+func hysteresisFromConfig(cfg *config.ThermostatConfig) (thermostat.Hysteresis, bool) {
+	h := thermostat.DefaultHysteresis
+	if cfg.LowerHysteresis == nil && cfg.UpperHysteresis == nil {
+		return h, false
+	}
+	if cfg.LowerHysteresis != nil {
+		h.Lower = *cfg.LowerHysteresis
+	}
+	if cfg.UpperHysteresis != nil {
+		h.Upper = *cfg.UpperHysteresis
+	}
+	return h, true
+}
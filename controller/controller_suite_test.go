@@ -3,6 +3,7 @@ package controller
 import (
 	"io/ioutil"
 	"log"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,11 +15,59 @@ func TestController(t *testing.T) {
 	RegisterFailHandler(Fail)
 
 	log.SetOutput(ioutil.Discard)
+	timeNow = clock.Now
 
 	RunSpecs(t, "Controller")
 }
 
+// clock stands in for timeNow throughout the suite. Specs set the time through
+// it rather than replacing timeNow, because zone goroutines (including ones
+// left over from earlier specs) may be reading the time concurrently.
+var clock = &mockClock{}
+
+var _ = AfterEach(func() {
+	clock.Reset()
+})
+
+type mockClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// Now returns the time that's been set, or the real time if none has.
+func (c *mockClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.now.IsZero() {
+		return time.Now()
+	}
+	return c.now
+}
+
+func (c *mockClock) Set(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = t
+}
+
+// Add moves the time that's been set on by the given duration.
+func (c *mockClock) Add(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Reset returns to the real time.
+func (c *mockClock) Reset() {
+	c.Set(time.Time{})
+}
+
 func todayAt(hour, minute, second int) time.Time {
 	now := time.Now().Local()
 	return time.Date(now.Year(), now.Month(), now.Day(), hour, minute, second, 0, now.Location())
 }
+
+// 2018-09-28 is a Friday.
+func fridayAt(hour, minute, second int) time.Time {
+	return time.Date(2018, 9, 28, hour, minute, second, 0, time.Local)
+}
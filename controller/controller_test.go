@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/alext/heating-controller/config"
 	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/thermostat"
+	"github.com/alext/heating-controller/units"
 )
 
 var _ = Describe("Controller", func() {
@@ -42,8 +46,8 @@ var _ = Describe("Controller", func() {
 		It("should do nothing with no sensors or zones", func() {
 			Expect(ctrl.Setup(cfg)).To(Succeed())
 
-			Expect(ctrl.SensorsByName).To(HaveLen(0))
-			Expect(ctrl.SensorsByID).To(HaveLen(0))
+			Expect(ctrl.sensorsByName).To(HaveLen(0))
+			Expect(ctrl.sensorsByID).To(HaveLen(0))
 			Expect(ctrl.Zones).To(HaveLen(0))
 		})
 
@@ -60,8 +64,74 @@ var _ = Describe("Controller", func() {
 
 				Expect(ctrl.Setup(cfg)).To(Succeed())
 
-				Expect(ctrl.SensorsByName).To(HaveLen(2))
-				Expect(ctrl.SensorsByID).To(HaveLen(2))
+				Expect(ctrl.sensorsByName).To(HaveLen(2))
+				Expect(ctrl.sensorsByID).To(HaveLen(2))
+			})
+
+			It("looks up the sensors by name and ID", func() {
+				cfg.Sensors["foo"] = config.SensorConfig{
+					Type: "push",
+					ID:   "1234",
+				}
+				Expect(ctrl.Setup(cfg)).To(Succeed())
+
+				s, ok := ctrl.Sensor("foo")
+				Expect(ok).To(BeTrue())
+				Expect(s.ID()).To(Equal("1234"))
+				s2, ok := ctrl.SensorByID("1234")
+				Expect(ok).To(BeTrue())
+				Expect(s2).To(BeIdenticalTo(s))
+				_, ok = ctrl.Sensor("bar")
+				Expect(ok).To(BeFalse())
+
+				sensors := ctrl.Sensors()
+				Expect(sensors).To(Equal(map[string]sensor.Sensor{"foo": s}))
+				ctrl.AddSensor("bar", sensor.NewPushSensor("bar", "2345"))
+				Expect(sensors).To(HaveLen(1), "should return a copy")
+			})
+
+			It("should add derived sensors after their inputs", func() {
+				cfg.Sensors["average"] = config.SensorConfig{Type: "mean", Inputs: []string{"foo", "delta"}}
+				cfg.Sensors["delta"] = config.SensorConfig{Type: "difference", Inputs: []string{"foo", "bar"}}
+				cfg.Sensors["foo"] = config.SensorConfig{Type: "push", ID: "1234"}
+				cfg.Sensors["bar"] = config.SensorConfig{Type: "push", ID: "2345"}
+
+				Expect(ctrl.Setup(cfg)).To(Succeed())
+
+				Expect(ctrl.sensorsByName).To(HaveLen(4))
+				ctrl.sensorsByName["foo"].(sensor.SettableSensor).Set(40000, time.Now())
+				ctrl.sensorsByName["bar"].(sensor.SettableSensor).Set(30000, time.Now())
+				Eventually(func() units.Temperature {
+					temp, _ := ctrl.sensorsByName["average"].Read()
+					return temp
+				}).Should(BeEquivalentTo(25000))
+			})
+
+			It("should return an error for derived sensors with missing or circular inputs", func() {
+				cfg.Sensors["foo"] = config.SensorConfig{Type: "offset", Inputs: []string{"bar"}}
+				cfg.Sensors["bar"] = config.SensorConfig{Type: "offset", Inputs: []string{"foo"}}
+				cfg.Sensors["baz"] = config.SensorConfig{Type: "mean", Inputs: []string{"non-existent"}}
+				Expect(ctrl.Setup(cfg)).To(MatchError("Missing or circular inputs for sensors: bar, baz, foo"))
+			})
+
+			Describe("with an MQTT broker", func() {
+				var connectedTo *config.MQTTConfig
+
+				BeforeEach(func() {
+					connectedTo = nil
+					cfg.MQTT = &config.MQTTConfig{Broker: "tcp://localhost:1883"}
+				})
+				AfterEach(func() {
+					mqttConnect = sensor.ConnectMQTT
+				})
+
+				It("connects to the broker", func() {
+					mqttConnect = func(c *config.MQTTConfig) {
+						connectedTo = c
+					}
+					Expect(ctrl.Setup(cfg)).To(Succeed())
+					Expect(connectedTo).To(Equal(cfg.MQTT))
+				})
 			})
 
 			It("should return an error if setting up a sensor fails", func() {
@@ -115,6 +185,195 @@ var _ = Describe("Controller", func() {
 				It("errors when the given sensor doesn't exist", func() {
 					Expect(ctrl.Setup(cfg)).NotTo(Succeed())
 				})
+
+				Describe("with multiple sensors", func() {
+					BeforeEach(func() {
+						cfg.Sensors["bar"] = config.SensorConfig{Type: "push", ID: "bar", MaxAgeMinutes: 10}
+						cfg.Sensors["baz"] = config.SensorConfig{Type: "push", ID: "baz", MaxAgeMinutes: 20}
+						tc := cfg.Zones["foo"].Thermostat
+						tc.Sensor = ""
+						tc.Sensors = []string{"bar", "baz"}
+						tc.Aggregation = "max"
+					})
+
+					It("combines the sensors for the thermostat", func() {
+						Expect(ctrl.Setup(cfg)).To(Succeed())
+						ctrl.sensorsByName["bar"].(sensor.SettableSensor).Set(19000, time.Now())
+						ctrl.sensorsByName["baz"].(sensor.SettableSensor).Set(21000, time.Now())
+
+						z := ctrl.Zones["foo"]
+						Eventually(z.Thermostat.Current).Should(BeEquivalentTo(21000))
+						Expect(z.ThermostatInputs()).To(HaveLen(2))
+						Expect(z.staleMaxAge).To(Equal(20 * time.Minute))
+					})
+
+					It("closes the combined sensor when the zone is closed", func() {
+						Expect(ctrl.Setup(cfg)).To(Succeed())
+						z := ctrl.Zones["foo"]
+						sub := z.thermSource.Subscribe()
+
+						z.Close()
+						Eventually(sub.C).Should(BeClosed())
+					})
+
+					It("errors when one of the sensors doesn't exist", func() {
+						cfg.Zones["foo"].Thermostat.Sensors = []string{"bar", "nope"}
+						Expect(ctrl.Setup(cfg)).NotTo(Succeed())
+					})
+
+					It("errors with an unrecognised aggregation", func() {
+						cfg.Zones["foo"].Thermostat.Aggregation = "fuzzy"
+						Expect(ctrl.Setup(cfg)).NotTo(Succeed())
+					})
+				})
+
+				It("should set up frost protection when configured", func() {
+					cfg.Sensors["bar"] = config.SensorConfig{
+						Type: "push",
+						ID:   "bar",
+					}
+					tc := cfg.Zones["foo"].Thermostat
+					tc.FrostProtection = 5000
+
+					Expect(ctrl.Setup(cfg)).To(Succeed())
+
+					Expect(ctrl.Zones["foo"].FrostProtection()).To(BeEquivalentTo(5000))
+				})
+
+				It("should set up optimum start when configured", func() {
+					cfg.Sensors["bar"] = config.SensorConfig{
+						Type: "push",
+						ID:   "bar",
+					}
+					cfg.Zones["foo"].Thermostat.OptimumStart = true
+
+					Expect(ctrl.Setup(cfg)).To(Succeed())
+
+					Expect(ctrl.Zones["foo"].OptimumStart()).To(BeTrue())
+				})
+
+				It("should set up the stale sensor failsafe when the sensor has a max age", func() {
+					cfg.Sensors["bar"] = config.SensorConfig{
+						Type:          "push",
+						ID:            "bar",
+						MaxAgeMinutes: 15,
+					}
+					cfg.Zones["foo"].Thermostat.Failsafe = &config.FailsafeConfig{Policy: "schedule"}
+
+					Expect(ctrl.Setup(cfg)).To(Succeed())
+
+					z := ctrl.Zones["foo"]
+					Expect(z.StalePolicy()).NotTo(BeNil())
+					Expect(z.StalePolicy().Mode).To(Equal(StaleSchedule))
+					Expect(z.staleMaxAge).To(Equal(15 * time.Minute))
+				})
+
+				It("errors with an invalid failsafe policy", func() {
+					cfg.Sensors["bar"] = config.SensorConfig{
+						Type:          "push",
+						ID:            "bar",
+						MaxAgeMinutes: 15,
+					}
+					cfg.Zones["foo"].Thermostat.Failsafe = &config.FailsafeConfig{Policy: "fuzzy"}
+
+					Expect(ctrl.Setup(cfg)).NotTo(Succeed())
+				})
+
+				It("should set up optimum stop when configured", func() {
+					cfg.Sensors["bar"] = config.SensorConfig{
+						Type: "push",
+						ID:   "bar",
+					}
+					cfg.Zones["foo"].Thermostat.OptimumStop = &config.OptimumStopConfig{MaxMinutes: 45}
+
+					Expect(ctrl.Setup(cfg)).To(Succeed())
+
+					z := ctrl.Zones["foo"]
+					Expect(z.OptimumStop()).To(BeTrue())
+					Expect(z.optimumStopMaxEarly).To(Equal(45 * time.Minute))
+					Expect(z.optimumStopTolerance).To(BeEquivalentTo(500))
+				})
+
+				Describe("configuring the control mode", func() {
+					BeforeEach(func() {
+						cfg.Sensors["bar"] = config.SensorConfig{
+							Type: "push",
+							ID:   "bar",
+						}
+					})
+					AfterEach(func() {
+						for _, z := range ctrl.Zones {
+							z.Thermostat.Close()
+						}
+					})
+
+					It("should add a TPI thermostat when configured", func() {
+						cfg.Zones["foo"].Thermostat.Mode = "tpi"
+
+						Expect(ctrl.Setup(cfg)).To(Succeed())
+
+						Expect(fmt.Sprintf("%T", ctrl.Zones["foo"].Thermostat)).To(Equal("*thermostat.tpiThermostat"))
+					})
+
+					It("should add a PID thermostat when configured", func() {
+						cfg.Zones["foo"].Thermostat.Mode = "pid"
+
+						Expect(ctrl.Setup(cfg)).To(Succeed())
+
+						Expect(fmt.Sprintf("%T", ctrl.Zones["foo"].Thermostat)).To(Equal("*thermostat.pidThermostat"))
+					})
+
+					It("errors with an unrecognised mode", func() {
+						cfg.Zones["foo"].Thermostat.Mode = "fuzzy"
+
+						Expect(ctrl.Setup(cfg)).NotTo(Succeed())
+					})
+				})
+
+				Describe("configuring the hysteresis", func() {
+					BeforeEach(func() {
+						cfg.Sensors["bar"] = config.SensorConfig{
+							Type: "push",
+							ID:   "bar",
+						}
+					})
+
+					It("should use the default hysteresis when not configured", func() {
+						Expect(ctrl.Setup(cfg)).To(Succeed())
+
+						Expect(ctrl.Zones["foo"].Thermostat.Hysteresis()).To(Equal(thermostat.DefaultHysteresis))
+					})
+
+					It("should set the configured hysteresis, defaulting any missing values", func() {
+						upper := units.Temperature(300)
+						cfg.Zones["foo"].Thermostat.UpperHysteresis = &upper
+
+						Expect(ctrl.Setup(cfg)).To(Succeed())
+
+						Expect(ctrl.Zones["foo"].Thermostat.Hysteresis()).To(Equal(thermostat.Hysteresis{Lower: 200, Upper: 300}))
+					})
+
+					It("should use a hysteresis saved at runtime in preference to the config", func() {
+						upper := units.Temperature(300)
+						cfg.Zones["foo"].Thermostat.UpperHysteresis = &upper
+						writeJSONToFile(DataDir+"/foo.json", map[string]interface{}{
+							"thermostat_hysteresis": map[string]interface{}{"lower": 100, "upper": 50},
+						})
+
+						Expect(ctrl.Setup(cfg)).To(Succeed())
+
+						Expect(ctrl.Zones["foo"].Thermostat.Hysteresis()).To(Equal(thermostat.Hysteresis{Lower: 100, Upper: 50}))
+						ctrl.Zones["foo"].ResetThermostatHysteresis()
+						Expect(ctrl.Zones["foo"].Thermostat.Hysteresis()).To(Equal(thermostat.Hysteresis{Lower: 200, Upper: 300}))
+					})
+
+					It("errors with a negative hysteresis", func() {
+						lower := units.Temperature(-100)
+						cfg.Zones["foo"].Thermostat.LowerHysteresis = &lower
+
+						Expect(ctrl.Setup(cfg)).NotTo(Succeed())
+					})
+				})
 			})
 
 			It("Should restore the state of the zones", func() {
@@ -158,3 +417,67 @@ var _ = Describe("Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("building TPI settings from config", func() {
+	It("uses the defaults with no config", func() {
+		Expect(tpiSettingsFromConfig(nil)).To(Equal(thermostat.DefaultTPISettings))
+		Expect(tpiSettingsFromConfig(&config.TPIConfig{})).To(Equal(thermostat.DefaultTPISettings))
+	})
+
+	It("uses the given values", func() {
+		integral := 0
+		settings := tpiSettingsFromConfig(&config.TPIConfig{
+			CyclesPerHour:    4,
+			ProportionalBand: 1500,
+			IntegralMinutes:  &integral,
+		})
+		Expect(settings).To(Equal(thermostat.TPISettings{
+			CycleLength:      15 * time.Minute,
+			ProportionalBand: 1500,
+			IntegralTime:     0,
+		}))
+	})
+})
+
+var _ = Describe("building PID settings from config", func() {
+	It("uses the defaults with no config", func() {
+		Expect(pidSettingsFromConfig(nil)).To(Equal(thermostat.DefaultPIDSettings))
+		Expect(pidSettingsFromConfig(&config.PIDConfig{})).To(Equal(thermostat.DefaultPIDSettings))
+	})
+
+	It("uses the given values", func() {
+		kp, ki, kd := 0.4, 0.0, 0.2
+		settings := pidSettingsFromConfig(&config.PIDConfig{
+			CyclesPerHour: 4,
+			Kp:            &kp,
+			Ki:            &ki,
+			Kd:            &kd,
+		})
+		Expect(settings).To(Equal(thermostat.PIDSettings{
+			CycleLength: 15 * time.Minute,
+			Kp:          0.4,
+			Ki:          0,
+			Kd:          0.2,
+		}))
+	})
+})
+
+var _ = Describe("building the stale sensor policy from config", func() {
+	It("uses the defaults with no config", func() {
+		Expect(stalePolicyFromConfig(nil)).To(Equal(DefaultStalePolicy))
+		Expect(stalePolicyFromConfig(&config.FailsafeConfig{})).To(Equal(DefaultStalePolicy))
+	})
+
+	It("uses the given values", func() {
+		policy := stalePolicyFromConfig(&config.FailsafeConfig{
+			Policy:        "duty_cycle",
+			DutyCycle:     0.5,
+			CyclesPerHour: 4,
+		})
+		Expect(policy).To(Equal(StalePolicy{
+			Mode:        StaleDutyCycle,
+			DutyCycle:   0.5,
+			CycleLength: 15 * time.Minute,
+		}))
+	})
+})
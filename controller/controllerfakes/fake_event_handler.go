@@ -21,6 +21,17 @@ type FakeEventHandler struct {
 	addEventReturnsOnCall map[int]struct {
 		result1 error
 	}
+	AddExceptionStub        func(controller.Exception) error
+	addExceptionMutex       sync.RWMutex
+	addExceptionArgsForCall []struct {
+		arg1 controller.Exception
+	}
+	addExceptionReturns struct {
+		result1 error
+	}
+	addExceptionReturnsOnCall map[int]struct {
+		result1 error
+	}
 	BoostStub        func(time.Duration)
 	boostMutex       sync.RWMutex
 	boostArgsForCall []struct {
@@ -40,10 +51,11 @@ type FakeEventHandler struct {
 	cancelBoostMutex       sync.RWMutex
 	cancelBoostArgsForCall []struct {
 	}
-	FindEventStub        func(units.TimeOfDay) (controller.Event, bool)
+	FindEventStub        func(units.TimeOfDay, units.Weekdays) (controller.Event, bool)
 	findEventMutex       sync.RWMutex
 	findEventArgsForCall []struct {
 		arg1 units.TimeOfDay
+		arg2 units.Weekdays
 	}
 	findEventReturns struct {
 		result1 controller.Event
@@ -63,6 +75,18 @@ type FakeEventHandler struct {
 	nextEventReturnsOnCall map[int]struct {
 		result1 *controller.Event
 	}
+	NextScheduledEventStub        func() (*controller.Event, time.Time)
+	nextScheduledEventMutex       sync.RWMutex
+	nextScheduledEventArgsForCall []struct {
+	}
+	nextScheduledEventReturns struct {
+		result1 *controller.Event
+		result2 time.Time
+	}
+	nextScheduledEventReturnsOnCall map[int]struct {
+		result1 *controller.Event
+		result2 time.Time
+	}
 	ReadEventsStub        func() []controller.Event
 	readEventsMutex       sync.RWMutex
 	readEventsArgsForCall []struct {
@@ -73,10 +97,21 @@ type FakeEventHandler struct {
 	readEventsReturnsOnCall map[int]struct {
 		result1 []controller.Event
 	}
-	RemoveEventStub        func(units.TimeOfDay) error
+	ReadExceptionsStub        func() []controller.Exception
+	readExceptionsMutex       sync.RWMutex
+	readExceptionsArgsForCall []struct {
+	}
+	readExceptionsReturns struct {
+		result1 []controller.Exception
+	}
+	readExceptionsReturnsOnCall map[int]struct {
+		result1 []controller.Exception
+	}
+	RemoveEventStub        func(units.TimeOfDay, units.Weekdays) error
 	removeEventMutex       sync.RWMutex
 	removeEventArgsForCall []struct {
 		arg1 units.TimeOfDay
+		arg2 units.Weekdays
 	}
 	removeEventReturns struct {
 		result1 error
@@ -84,11 +119,23 @@ type FakeEventHandler struct {
 	removeEventReturnsOnCall map[int]struct {
 		result1 error
 	}
-	ReplaceEventStub        func(units.TimeOfDay, controller.Event) error
+	RemoveExceptionStub        func(units.Date) error
+	removeExceptionMutex       sync.RWMutex
+	removeExceptionArgsForCall []struct {
+		arg1 units.Date
+	}
+	removeExceptionReturns struct {
+		result1 error
+	}
+	removeExceptionReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ReplaceEventStub        func(units.TimeOfDay, units.Weekdays, controller.Event) error
 	replaceEventMutex       sync.RWMutex
 	replaceEventArgsForCall []struct {
 		arg1 units.TimeOfDay
-		arg2 controller.Event
+		arg2 units.Weekdays
+		arg3 controller.Event
 	}
 	replaceEventReturns struct {
 		result1 error
@@ -96,6 +143,17 @@ type FakeEventHandler struct {
 	replaceEventReturnsOnCall map[int]struct {
 		result1 error
 	}
+	StopEarlyStub        func(time.Time) error
+	stopEarlyMutex       sync.RWMutex
+	stopEarlyArgsForCall []struct {
+		arg1 time.Time
+	}
+	stopEarlyReturns struct {
+		result1 error
+	}
+	stopEarlyReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -150,6 +208,56 @@ func (fake *FakeEventHandler) AddEventReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeEventHandler) AddException(arg1 controller.Exception) error {
+	fake.addExceptionMutex.Lock()
+	ret, specificReturn := fake.addExceptionReturnsOnCall[len(fake.addExceptionArgsForCall)]
+	fake.addExceptionArgsForCall = append(fake.addExceptionArgsForCall, struct {
+		arg1 controller.Exception
+	}{arg1})
+	fake.recordInvocation("AddException", []interface{}{arg1})
+	fake.addExceptionMutex.Unlock()
+	if fake.AddExceptionStub != nil {
+		return fake.AddExceptionStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.addExceptionReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeEventHandler) AddExceptionCallCount() int {
+	fake.addExceptionMutex.RLock()
+	defer fake.addExceptionMutex.RUnlock()
+	return len(fake.addExceptionArgsForCall)
+}
+
+func (fake *FakeEventHandler) AddExceptionArgsForCall(i int) controller.Exception {
+	fake.addExceptionMutex.RLock()
+	defer fake.addExceptionMutex.RUnlock()
+	argsForCall := fake.addExceptionArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeEventHandler) AddExceptionReturns(result1 error) {
+	fake.AddExceptionStub = nil
+	fake.addExceptionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeEventHandler) AddExceptionReturnsOnCall(i int, result1 error) {
+	fake.AddExceptionStub = nil
+	if fake.addExceptionReturnsOnCall == nil {
+		fake.addExceptionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addExceptionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeEventHandler) Boost(arg1 time.Duration) {
 	fake.boostMutex.Lock()
 	fake.boostArgsForCall = append(fake.boostArgsForCall, struct {
@@ -234,16 +342,17 @@ func (fake *FakeEventHandler) CancelBoostCallCount() int {
 	return len(fake.cancelBoostArgsForCall)
 }
 
-func (fake *FakeEventHandler) FindEvent(arg1 units.TimeOfDay) (controller.Event, bool) {
+func (fake *FakeEventHandler) FindEvent(arg1 units.TimeOfDay, arg2 units.Weekdays) (controller.Event, bool) {
 	fake.findEventMutex.Lock()
 	ret, specificReturn := fake.findEventReturnsOnCall[len(fake.findEventArgsForCall)]
 	fake.findEventArgsForCall = append(fake.findEventArgsForCall, struct {
 		arg1 units.TimeOfDay
-	}{arg1})
-	fake.recordInvocation("FindEvent", []interface{}{arg1})
+		arg2 units.Weekdays
+	}{arg1, arg2})
+	fake.recordInvocation("FindEvent", []interface{}{arg1, arg2})
 	fake.findEventMutex.Unlock()
 	if fake.FindEventStub != nil {
-		return fake.FindEventStub(arg1)
+		return fake.FindEventStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -258,11 +367,11 @@ func (fake *FakeEventHandler) FindEventCallCount() int {
 	return len(fake.findEventArgsForCall)
 }
 
-func (fake *FakeEventHandler) FindEventArgsForCall(i int) units.TimeOfDay {
+func (fake *FakeEventHandler) FindEventArgsForCall(i int) (units.TimeOfDay, units.Weekdays) {
 	fake.findEventMutex.RLock()
 	defer fake.findEventMutex.RUnlock()
 	argsForCall := fake.findEventArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeEventHandler) FindEventReturns(result1 controller.Event, result2 bool) {
@@ -329,6 +438,51 @@ func (fake *FakeEventHandler) NextEventReturnsOnCall(i int, result1 *controller.
 	}{result1}
 }
 
+func (fake *FakeEventHandler) NextScheduledEvent() (*controller.Event, time.Time) {
+	fake.nextScheduledEventMutex.Lock()
+	ret, specificReturn := fake.nextScheduledEventReturnsOnCall[len(fake.nextScheduledEventArgsForCall)]
+	fake.nextScheduledEventArgsForCall = append(fake.nextScheduledEventArgsForCall, struct {
+	}{})
+	fake.recordInvocation("NextScheduledEvent", []interface{}{})
+	fake.nextScheduledEventMutex.Unlock()
+	if fake.NextScheduledEventStub != nil {
+		return fake.NextScheduledEventStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.nextScheduledEventReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeEventHandler) NextScheduledEventCallCount() int {
+	fake.nextScheduledEventMutex.RLock()
+	defer fake.nextScheduledEventMutex.RUnlock()
+	return len(fake.nextScheduledEventArgsForCall)
+}
+
+func (fake *FakeEventHandler) NextScheduledEventReturns(result1 *controller.Event, result2 time.Time) {
+	fake.NextScheduledEventStub = nil
+	fake.nextScheduledEventReturns = struct {
+		result1 *controller.Event
+		result2 time.Time
+	}{result1, result2}
+}
+
+func (fake *FakeEventHandler) NextScheduledEventReturnsOnCall(i int, result1 *controller.Event, result2 time.Time) {
+	fake.NextScheduledEventStub = nil
+	if fake.nextScheduledEventReturnsOnCall == nil {
+		fake.nextScheduledEventReturnsOnCall = make(map[int]struct {
+			result1 *controller.Event
+			result2 time.Time
+		})
+	}
+	fake.nextScheduledEventReturnsOnCall[i] = struct {
+		result1 *controller.Event
+		result2 time.Time
+	}{result1, result2}
+}
+
 func (fake *FakeEventHandler) ReadEvents() []controller.Event {
 	fake.readEventsMutex.Lock()
 	ret, specificReturn := fake.readEventsReturnsOnCall[len(fake.readEventsArgsForCall)]
@@ -371,16 +525,59 @@ func (fake *FakeEventHandler) ReadEventsReturnsOnCall(i int, result1 []controlle
 	}{result1}
 }
 
-func (fake *FakeEventHandler) RemoveEvent(arg1 units.TimeOfDay) error {
+func (fake *FakeEventHandler) ReadExceptions() []controller.Exception {
+	fake.readExceptionsMutex.Lock()
+	ret, specificReturn := fake.readExceptionsReturnsOnCall[len(fake.readExceptionsArgsForCall)]
+	fake.readExceptionsArgsForCall = append(fake.readExceptionsArgsForCall, struct {
+	}{})
+	fake.recordInvocation("ReadExceptions", []interface{}{})
+	fake.readExceptionsMutex.Unlock()
+	if fake.ReadExceptionsStub != nil {
+		return fake.ReadExceptionsStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.readExceptionsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeEventHandler) ReadExceptionsCallCount() int {
+	fake.readExceptionsMutex.RLock()
+	defer fake.readExceptionsMutex.RUnlock()
+	return len(fake.readExceptionsArgsForCall)
+}
+
+func (fake *FakeEventHandler) ReadExceptionsReturns(result1 []controller.Exception) {
+	fake.ReadExceptionsStub = nil
+	fake.readExceptionsReturns = struct {
+		result1 []controller.Exception
+	}{result1}
+}
+
+func (fake *FakeEventHandler) ReadExceptionsReturnsOnCall(i int, result1 []controller.Exception) {
+	fake.ReadExceptionsStub = nil
+	if fake.readExceptionsReturnsOnCall == nil {
+		fake.readExceptionsReturnsOnCall = make(map[int]struct {
+			result1 []controller.Exception
+		})
+	}
+	fake.readExceptionsReturnsOnCall[i] = struct {
+		result1 []controller.Exception
+	}{result1}
+}
+
+func (fake *FakeEventHandler) RemoveEvent(arg1 units.TimeOfDay, arg2 units.Weekdays) error {
 	fake.removeEventMutex.Lock()
 	ret, specificReturn := fake.removeEventReturnsOnCall[len(fake.removeEventArgsForCall)]
 	fake.removeEventArgsForCall = append(fake.removeEventArgsForCall, struct {
 		arg1 units.TimeOfDay
-	}{arg1})
-	fake.recordInvocation("RemoveEvent", []interface{}{arg1})
+		arg2 units.Weekdays
+	}{arg1, arg2})
+	fake.recordInvocation("RemoveEvent", []interface{}{arg1, arg2})
 	fake.removeEventMutex.Unlock()
 	if fake.RemoveEventStub != nil {
-		return fake.RemoveEventStub(arg1)
+		return fake.RemoveEventStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
@@ -395,11 +592,11 @@ func (fake *FakeEventHandler) RemoveEventCallCount() int {
 	return len(fake.removeEventArgsForCall)
 }
 
-func (fake *FakeEventHandler) RemoveEventArgsForCall(i int) units.TimeOfDay {
+func (fake *FakeEventHandler) RemoveEventArgsForCall(i int) (units.TimeOfDay, units.Weekdays) {
 	fake.removeEventMutex.RLock()
 	defer fake.removeEventMutex.RUnlock()
 	argsForCall := fake.removeEventArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeEventHandler) RemoveEventReturns(result1 error) {
@@ -421,17 +618,68 @@ func (fake *FakeEventHandler) RemoveEventReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeEventHandler) ReplaceEvent(arg1 units.TimeOfDay, arg2 controller.Event) error {
+func (fake *FakeEventHandler) RemoveException(arg1 units.Date) error {
+	fake.removeExceptionMutex.Lock()
+	ret, specificReturn := fake.removeExceptionReturnsOnCall[len(fake.removeExceptionArgsForCall)]
+	fake.removeExceptionArgsForCall = append(fake.removeExceptionArgsForCall, struct {
+		arg1 units.Date
+	}{arg1})
+	fake.recordInvocation("RemoveException", []interface{}{arg1})
+	fake.removeExceptionMutex.Unlock()
+	if fake.RemoveExceptionStub != nil {
+		return fake.RemoveExceptionStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.removeExceptionReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeEventHandler) RemoveExceptionCallCount() int {
+	fake.removeExceptionMutex.RLock()
+	defer fake.removeExceptionMutex.RUnlock()
+	return len(fake.removeExceptionArgsForCall)
+}
+
+func (fake *FakeEventHandler) RemoveExceptionArgsForCall(i int) units.Date {
+	fake.removeExceptionMutex.RLock()
+	defer fake.removeExceptionMutex.RUnlock()
+	argsForCall := fake.removeExceptionArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeEventHandler) RemoveExceptionReturns(result1 error) {
+	fake.RemoveExceptionStub = nil
+	fake.removeExceptionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeEventHandler) RemoveExceptionReturnsOnCall(i int, result1 error) {
+	fake.RemoveExceptionStub = nil
+	if fake.removeExceptionReturnsOnCall == nil {
+		fake.removeExceptionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.removeExceptionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeEventHandler) ReplaceEvent(arg1 units.TimeOfDay, arg2 units.Weekdays, arg3 controller.Event) error {
 	fake.replaceEventMutex.Lock()
 	ret, specificReturn := fake.replaceEventReturnsOnCall[len(fake.replaceEventArgsForCall)]
 	fake.replaceEventArgsForCall = append(fake.replaceEventArgsForCall, struct {
 		arg1 units.TimeOfDay
-		arg2 controller.Event
-	}{arg1, arg2})
-	fake.recordInvocation("ReplaceEvent", []interface{}{arg1, arg2})
+		arg2 units.Weekdays
+		arg3 controller.Event
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("ReplaceEvent", []interface{}{arg1, arg2, arg3})
 	fake.replaceEventMutex.Unlock()
 	if fake.ReplaceEventStub != nil {
-		return fake.ReplaceEventStub(arg1, arg2)
+		return fake.ReplaceEventStub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
@@ -446,11 +694,11 @@ func (fake *FakeEventHandler) ReplaceEventCallCount() int {
 	return len(fake.replaceEventArgsForCall)
 }
 
-func (fake *FakeEventHandler) ReplaceEventArgsForCall(i int) (units.TimeOfDay, controller.Event) {
+func (fake *FakeEventHandler) ReplaceEventArgsForCall(i int) (units.TimeOfDay, units.Weekdays, controller.Event) {
 	fake.replaceEventMutex.RLock()
 	defer fake.replaceEventMutex.RUnlock()
 	argsForCall := fake.replaceEventArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeEventHandler) ReplaceEventReturns(result1 error) {
@@ -472,11 +720,63 @@ func (fake *FakeEventHandler) ReplaceEventReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeEventHandler) StopEarly(arg1 time.Time) error {
+	fake.stopEarlyMutex.Lock()
+	ret, specificReturn := fake.stopEarlyReturnsOnCall[len(fake.stopEarlyArgsForCall)]
+	fake.stopEarlyArgsForCall = append(fake.stopEarlyArgsForCall, struct {
+		arg1 time.Time
+	}{arg1})
+	fake.recordInvocation("StopEarly", []interface{}{arg1})
+	fake.stopEarlyMutex.Unlock()
+	if fake.StopEarlyStub != nil {
+		return fake.StopEarlyStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.stopEarlyReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeEventHandler) StopEarlyCallCount() int {
+	fake.stopEarlyMutex.RLock()
+	defer fake.stopEarlyMutex.RUnlock()
+	return len(fake.stopEarlyArgsForCall)
+}
+
+func (fake *FakeEventHandler) StopEarlyArgsForCall(i int) time.Time {
+	fake.stopEarlyMutex.RLock()
+	defer fake.stopEarlyMutex.RUnlock()
+	argsForCall := fake.stopEarlyArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeEventHandler) StopEarlyReturns(result1 error) {
+	fake.StopEarlyStub = nil
+	fake.stopEarlyReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeEventHandler) StopEarlyReturnsOnCall(i int, result1 error) {
+	fake.StopEarlyStub = nil
+	if fake.stopEarlyReturnsOnCall == nil {
+		fake.stopEarlyReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.stopEarlyReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeEventHandler) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.addEventMutex.RLock()
 	defer fake.addEventMutex.RUnlock()
+	fake.addExceptionMutex.RLock()
+	defer fake.addExceptionMutex.RUnlock()
 	fake.boostMutex.RLock()
 	defer fake.boostMutex.RUnlock()
 	fake.boostedMutex.RLock()
@@ -487,12 +787,20 @@ func (fake *FakeEventHandler) Invocations() map[string][][]interface{} {
 	defer fake.findEventMutex.RUnlock()
 	fake.nextEventMutex.RLock()
 	defer fake.nextEventMutex.RUnlock()
+	fake.nextScheduledEventMutex.RLock()
+	defer fake.nextScheduledEventMutex.RUnlock()
 	fake.readEventsMutex.RLock()
 	defer fake.readEventsMutex.RUnlock()
+	fake.readExceptionsMutex.RLock()
+	defer fake.readExceptionsMutex.RUnlock()
 	fake.removeEventMutex.RLock()
 	defer fake.removeEventMutex.RUnlock()
+	fake.removeExceptionMutex.RLock()
+	defer fake.removeExceptionMutex.RUnlock()
 	fake.replaceEventMutex.RLock()
 	defer fake.replaceEventMutex.RUnlock()
+	fake.stopEarlyMutex.RLock()
+	defer fake.stopEarlyMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/sensor"
+)
+
+var (
+	ErrSensorExists      = errors.New("sensor already exists")
+	ErrUnknownW1Device   = errors.New("unknown 1-wire device")
+	ErrInvalidSensorName = errors.New("invalid sensor name")
+)
+
+var discoverW1Devices = sensor.DiscoverW1Devices // variable indirection to facilitate testing
+
+// DiscoverW1Sensors returns the 1-wire temperature sensors on the bus that
+// haven't been set up.
+func (c *Controller) DiscoverW1Sensors() ([]sensor.W1Device, error) {
+	devices, err := discoverW1Devices()
+	if err != nil {
+		return nil, err
+	}
+	unconfigured := make([]sensor.W1Device, 0, len(devices))
+	for _, d := range devices {
+		if _, ok := c.SensorByID(d.ID); ok {
+			continue
+		}
+		unconfigured = append(unconfigured, d)
+	}
+	return unconfigured, nil
+}
+
+// AdoptW1Sensor adds a sensor with the given name for a discovered 1-wire
+// device, and saves it so that it's set up again on restart.
+func (c *Controller) AdoptW1Sensor(name, id string) error {
+	if name == "" {
+		return ErrInvalidSensorName
+	}
+	if _, ok := c.Sensor(name); ok {
+		return ErrSensorExists
+	}
+	devices, err := c.DiscoverW1Sensors()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, d := range devices {
+		found = found || d.ID == id
+	}
+	if !found {
+		return ErrUnknownW1Device
+	}
+
+	// Creating the sensor reads the bus, so it's done without holding the
+	// lock, and the checks repeated once it's taken.
+	sensorConfig := config.SensorConfig{Type: "w1", ID: id}
+	s, err := sensorNew(name, sensorConfig, nil)
+	if err != nil {
+		return err
+	}
+
+	c.sensorsLock.Lock()
+	defer c.sensorsLock.Unlock()
+	if _, ok := c.sensorsByName[name]; ok {
+		s.Close()
+		return ErrSensorExists
+	}
+	if _, ok := c.sensorsByID[id]; ok {
+		s.Close()
+		return ErrUnknownW1Device
+	}
+	log.Printf("[Controller] Adopting 1-wire device %s as sensor '%s'", id, name)
+	c.addSensor(name, s)
+
+	if c.adopted == nil {
+		c.adopted = make(map[string]config.SensorConfig)
+	}
+	c.adopted[name] = sensorConfig
+	return c.saveAdoptedSensors()
+}
+
+func adoptedSensorsFilename() string {
+	return filepath.Join(DataDir, "adopted_sensors.json")
+}
+
+// Must be called with the sensorsLock held.
+func (c *Controller) saveAdoptedSensors() error {
+	err := saveJSONFile(adoptedSensorsFilename(), c.adopted)
+	if err != nil {
+		log.Printf("[Controller] Error saving adopted sensors: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// restoreAdoptedSensors adds any previously adopted sensors that aren't in the
+// config to the given sensor configs.
+func (c *Controller) restoreAdoptedSensors(sensors map[string]config.SensorConfig) error {
+	file, err := os.Open(adoptedSensorsFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var adopted map[string]config.SensorConfig
+	err = json.NewDecoder(file).Decode(&adopted)
+	if err != nil {
+		// Carry on with just the configured sensors rather than failing to start.
+		log.Printf("[Controller] Discarding saved adopted sensors, error parsing: %s", err.Error())
+		return nil
+	}
+
+	c.sensorsLock.Lock()
+	defer c.sensorsLock.Unlock()
+	c.adopted = adopted
+	for name, sensorConfig := range adopted {
+		if _, ok := sensors[name]; ok {
+			log.Printf("[Controller] Ignoring adopted sensor '%s' that's now in the config", name)
+			continue
+		}
+		sensors[name] = sensorConfig
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/sensor"
+)
+
+var _ = Describe("Discovering w1 sensors", func() {
+	var (
+		ctrl    *Controller
+		cfg     *config.Config
+		devices []sensor.W1Device
+	)
+
+	BeforeEach(func() {
+		var err error
+		DataDir, err = ioutil.TempDir("", "discovery_test")
+		Expect(err).NotTo(HaveOccurred())
+
+		devices = []sensor.W1Device{
+			{ID: "28-0001", Temperature: 19000},
+			{ID: "28-0002", Temperature: 21500},
+		}
+		discoverW1Devices = func() ([]sensor.W1Device, error) { return devices, nil }
+		// Use push sensors in place of w1 sensors to avoid reading the bus.
+		sensorNew = func(name string, cfg config.SensorConfig, sensors map[string]sensor.Sensor) (sensor.Sensor, error) {
+			if cfg.Type == "w1" {
+				cfg.Type = "push"
+			}
+			return sensor.New(name, cfg, sensors)
+		}
+
+		cfg = config.New()
+		cfg.Sensors["foo"] = config.SensorConfig{Type: "w1", ID: "28-0001"}
+		ctrl = New()
+		Expect(ctrl.Setup(cfg)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		discoverW1Devices = sensor.DiscoverW1Devices
+		sensorNew = sensor.New
+		os.RemoveAll(DataDir)
+	})
+
+	It("lists the devices that aren't set up", func() {
+		Expect(ctrl.DiscoverW1Sensors()).To(Equal([]sensor.W1Device{
+			{ID: "28-0002", Temperature: 21500},
+		}))
+	})
+
+	Describe("adopting a device", func() {
+		It("adds a sensor for the device", func() {
+			Expect(ctrl.AdoptW1Sensor("bar", "28-0002")).To(Succeed())
+
+			Expect(ctrl.sensorsByName).To(HaveKey("bar"))
+			Expect(ctrl.sensorsByID).To(HaveKey("28-0002"))
+			Expect(ctrl.DiscoverW1Sensors()).To(BeEmpty())
+		})
+
+		It("saves the sensor so that it's set up on restart", func() {
+			Expect(ctrl.AdoptW1Sensor("bar", "28-0002")).To(Succeed())
+			Expect(readFile(filepath.Join(DataDir, "adopted_sensors.json"))).To(ContainSubstring(`"28-0002"`))
+
+			ctrl2 := New()
+			Expect(ctrl2.Setup(cfg)).To(Succeed())
+			Expect(ctrl2.sensorsByName).To(HaveKey("bar"))
+			Expect(ctrl2.sensorsByID).To(HaveKey("28-0002"))
+			Expect(filepath.Join(DataDir, "adopted_sensors.json.tmp")).NotTo(BeAnExistingFile())
+		})
+
+		It("ignores saved sensors that can't be parsed", func() {
+			Expect(ioutil.WriteFile(filepath.Join(DataDir, "adopted_sensors.json"), []byte(`{"bar":`), 0644)).To(Succeed())
+
+			ctrl2 := New()
+			Expect(ctrl2.Setup(cfg)).To(Succeed())
+			Expect(ctrl2.sensorsByName).To(HaveKey("foo"))
+			Expect(ctrl2.sensorsByName).NotTo(HaveKey("bar"))
+		})
+
+		It("prefers the config for a sensor that's since been configured", func() {
+			Expect(ctrl.AdoptW1Sensor("bar", "28-0002")).To(Succeed())
+			cfg.Sensors["bar"] = config.SensorConfig{Type: "push", ID: "something"}
+
+			ctrl2 := New()
+			Expect(ctrl2.Setup(cfg)).To(Succeed())
+			Expect(ctrl2.sensorsByName["bar"].ID()).To(Equal("something"))
+		})
+
+		It("errors with a missing or existing name", func() {
+			Expect(ctrl.AdoptW1Sensor("", "28-0002")).To(Equal(ErrInvalidSensorName))
+			Expect(ctrl.AdoptW1Sensor("foo", "28-0002")).To(Equal(ErrSensorExists))
+		})
+
+		It("errors if the name is taken while the sensor is being created", func() {
+			sensorNew = func(name string, cfg config.SensorConfig, sensors map[string]sensor.Sensor) (sensor.Sensor, error) {
+				ctrl.AddSensor("bar", sensor.NewPushSensor("bar", "something"))
+				cfg.Type = "push"
+				return sensor.New(name, cfg, sensors)
+			}
+
+			Expect(ctrl.AdoptW1Sensor("bar", "28-0002")).To(Equal(ErrSensorExists))
+			Expect(ctrl.sensorsByName["bar"].ID()).To(Equal("something"))
+			Expect(ctrl.sensorsByID).NotTo(HaveKey("28-0002"))
+		})
+
+		It("errors with a device that isn't discovered or is already set up", func() {
+			Expect(ctrl.AdoptW1Sensor("bar", "28-0003")).To(Equal(ErrUnknownW1Device))
+			Expect(ctrl.AdoptW1Sensor("bar", "28-0001")).To(Equal(ErrUnknownW1Device))
+		})
+	})
+})
@@ -12,6 +12,7 @@ import (
 
 type Event struct {
 	Time        units.TimeOfDay   `json:"time"`
+	Days        units.Weekdays    `json:"days,omitempty"`
 	Action      Action            `json:"action"`
 	ThermAction *ThermostatAction `json:"therm_action,omitempty"`
 }
@@ -21,11 +22,14 @@ func (e Event) Valid() bool {
 }
 
 func (e Event) NextOccurance() time.Time {
-	return e.Time.NextOccuranceAfter(timeNow().Local())
+	return e.buildSchedulerJob(nil).NextOccuranceAfter(timeNow().Local())
 }
 
 func (e Event) String() string {
 	var b strings.Builder
+	if e.Days != 0 {
+		fmt.Fprintf(&b, "%s ", e.Days)
+	}
 	fmt.Fprintf(&b, "%s %s", e.Time, e.Action)
 	if e.ThermAction != nil {
 		fmt.Fprintf(&b, " %s", e.ThermAction)
@@ -36,13 +40,14 @@ func (e Event) String() string {
 func (e Event) buildSchedulerJob(demand func(Event)) scheduler.Job {
 	return scheduler.Job{
 		Time:   e.Time,
+		Days:   e.Days,
 		Label:  e.Action.String(),
 		Action: func() { demand(e) },
 	}
 }
 
 func sortEvents(events []Event) {
-	sort.Slice(events, func(i, j int) bool {
+	sort.SliceStable(events, func(i, j int) bool {
 		return events[i].Time < events[j].Time
 	})
 }
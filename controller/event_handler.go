@@ -10,37 +10,50 @@ import (
 )
 
 var (
-	ErrInvalidEvent  = errors.New("invalid event")
-	ErrEventNotFound = errors.New("event not found")
+	ErrInvalidEvent     = errors.New("invalid event")
+	ErrEventNotFound    = errors.New("event not found")
+	ErrEventConflict    = errors.New("another event is already at that time on the same days")
+	ErrInvalidException = errors.New("invalid exception")
+	ErrCannotStopEarly  = errors.New("next event can't be brought forward")
+	ErrAlreadyStopping  = errors.New("next event has already been brought forward")
 )
 
 //go:generate counterfeiter . EventHandler
 type EventHandler interface {
 	AddEvent(Event) error
-	ReplaceEvent(units.TimeOfDay, Event) error
-	RemoveEvent(units.TimeOfDay) error
-	FindEvent(units.TimeOfDay) (Event, bool)
+	ReplaceEvent(units.TimeOfDay, units.Weekdays, Event) error
+	RemoveEvent(units.TimeOfDay, units.Weekdays) error
+	FindEvent(units.TimeOfDay, units.Weekdays) (Event, bool)
 	ReadEvents() []Event
 	NextEvent() *Event
+	NextScheduledEvent() (*Event, time.Time)
+
+	AddException(Exception) error
+	RemoveException(units.Date) error
+	ReadExceptions() []Exception
 
 	Boost(time.Duration)
 	CancelBoost()
 	Boosted() bool
+	StopEarly(time.Time) error
 }
 
 type eventHandler struct {
-	lock    sync.RWMutex
-	events  []Event
-	demand  func(Event)
-	sched   scheduler.Scheduler
-	boosted bool
+	lock       sync.RWMutex
+	events     []Event
+	exceptions []Exception
+	demand     func(Event)
+	sched      scheduler.Scheduler
+	boosted    bool
+	earlyStop  *Event
 }
 
 func NewEventHandler(s scheduler.Scheduler, demand func(Event)) EventHandler {
 	return &eventHandler{
-		sched:  s,
-		demand: demand,
-		events: make([]Event, 0),
+		sched:      s,
+		demand:     demand,
+		events:     make([]Event, 0),
+		exceptions: make([]Exception, 0),
 	}
 }
 
@@ -48,6 +61,7 @@ func (eh *eventHandler) trigger(e Event) {
 	eh.lock.Lock()
 	defer eh.lock.Unlock()
 	eh.boosted = false
+	eh.earlyStop = nil
 	eh.demand(e)
 }
 
@@ -55,43 +69,111 @@ func (eh *eventHandler) buildSchedulerJob(e Event) scheduler.Job {
 	return e.buildSchedulerJob(eh.trigger)
 }
 
+// buildRegularJob builds the scheduler job for a regular event, which won't
+// run on any dates that have an exception.
+func (eh *eventHandler) buildRegularJob(e Event) scheduler.Job {
+	j := eh.buildSchedulerJob(e)
+	j.Except = eh.exceptionDates()
+	return j
+}
+
+func (eh *eventHandler) buildExceptionJob(ex Exception, e Event) scheduler.Job {
+	j := eh.buildSchedulerJob(e)
+	j.Date = ex.Date
+	return j
+}
+
+// eachJob calls the given function with every regular and exception event
+// along with its corresponding scheduler job.
+func (eh *eventHandler) eachJob(f func(*Event, scheduler.Job)) {
+	except := eh.exceptionDates()
+	for i := range eh.events {
+		j := eh.buildSchedulerJob(eh.events[i])
+		j.Except = except
+		f(&eh.events[i], j)
+	}
+	for _, ex := range eh.exceptions {
+		if ex.Expired() {
+			continue
+		}
+		for i := range ex.Events {
+			f(&ex.Events[i], eh.buildExceptionJob(ex, ex.Events[i]))
+		}
+	}
+}
+
 func (eh *eventHandler) buildSchedulerJobs() []scheduler.Job {
 	jobs := make([]scheduler.Job, 0, len(eh.events))
-	for _, e := range eh.events {
-		jobs = append(jobs, eh.buildSchedulerJob(e))
-	}
+	eh.eachJob(func(_ *Event, j scheduler.Job) {
+		jobs = append(jobs, j)
+	})
 	return jobs
 }
 
-// nextEvent returns the next regular event, ignoring any overrides. This is
-// different from NextEvent, which queries the scheduler.
+func (eh *eventHandler) exceptionDates() []units.Date {
+	var dates []units.Date
+	for _, ex := range eh.exceptions {
+		if !ex.Expired() {
+			dates = append(dates, ex.Date)
+		}
+	}
+	return dates
+}
+
+// nextEvent returns the next regular or exception event, ignoring any
+// overrides. This is different from NextEvent, which queries the scheduler.
 func (eh *eventHandler) nextEvent() *Event {
-	if len(eh.events) < 1 {
-		return nil
+	e, _ := eh.nextEventWithTime()
+	return e
+}
+
+// NextScheduledEvent returns the next regular or exception event along with
+// the time it's due, ignoring any boost or early stop.
+func (eh *eventHandler) NextScheduledEvent() (*Event, time.Time) {
+	eh.lock.RLock()
+	defer eh.lock.RUnlock()
+	e, at := eh.nextEventWithTime()
+	if e == nil {
+		return nil, at
 	}
-	currentToD := units.NewTimeOfDay(timeNow().Local().Clock())
-	for _, e := range eh.events {
-		if e.Time > currentToD {
-			return &e
+	next := *e
+	return &next, at
+}
+
+func (eh *eventHandler) nextEventWithTime() (*Event, time.Time) {
+	now := timeNow().Local()
+	var (
+		next   *Event
+		nextAt time.Time
+	)
+	eh.eachJob(func(e *Event, j scheduler.Job) {
+		at := j.NextOccuranceAfter(now)
+		if at.IsZero() {
+			return
 		}
-	}
-	return &eh.events[0]
+		if next == nil || at.Before(nextAt) {
+			next, nextAt = e, at
+		}
+	})
+	return next, nextAt
 }
 
 func (eh *eventHandler) previousEvent() *Event {
-	if len(eh.events) < 1 {
-		return nil
-	}
-	currentToD := units.NewTimeOfDay(timeNow().Local().Clock())
-	for i, e := range eh.events {
-		if e.Time > currentToD {
-			if i > 0 {
-				return &eh.events[i-1]
-			}
-			break
+	now := timeNow().Local()
+	var (
+		previous   *Event
+		previousAt time.Time
+	)
+	eh.eachJob(func(e *Event, j scheduler.Job) {
+		at := j.PreviousOccuranceBefore(now)
+		if at.IsZero() {
+			return
 		}
-	}
-	return &eh.events[len(eh.events)-1]
+		if previous == nil || !at.Before(previousAt) {
+			previous, previousAt = e, at
+		}
+	})
+	return previous
 }
 
 func (eh *eventHandler) AddEvent(e Event) error {
@@ -100,42 +182,70 @@ func (eh *eventHandler) AddEvent(e Event) error {
 	}
 	eh.lock.Lock()
 	defer eh.lock.Unlock()
+	if eh.conflictingEvent(e, -1) {
+		return ErrEventConflict
+	}
 
 	eh.events = append(eh.events, e)
 	sortEvents(eh.events)
 
-	return eh.sched.AddJob(eh.buildSchedulerJob(e))
+	return eh.sched.AddJob(eh.buildRegularJob(e))
 }
 
-func (eh *eventHandler) ReplaceEvent(t units.TimeOfDay, e Event) error {
+// conflictingEvent returns whether e is at the same time on any of the same
+// days as an existing event, other than the one at index skip.
+//
+// Must be called with the lock held.
+func (eh *eventHandler) conflictingEvent(e Event, skip int) bool {
+	for i, ee := range eh.events {
+		if i != skip && ee.Time == e.Time && ee.Days.Overlaps(e.Days) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOfEvent returns the index of the event at the given time on the given
+// days, or -1 if there isn't one.
+//
+// Must be called with the lock held.
+func (eh *eventHandler) indexOfEvent(t units.TimeOfDay, days units.Weekdays) int {
+	for i, e := range eh.events {
+		if e.Time == t && e.Days == days {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReplaceEvent replaces the event at the given time on the given days.
+func (eh *eventHandler) ReplaceEvent(t units.TimeOfDay, days units.Weekdays, e Event) error {
 	if !e.Valid() {
 		return ErrInvalidEvent
 	}
 	eh.lock.Lock()
 	defer eh.lock.Unlock()
 
-	found := false
-	for i, ee := range eh.events {
-		if ee.Time == t {
-			eh.events[i] = e
-			found = true
-			break
-		}
-	}
-	if !found {
+	i := eh.indexOfEvent(t, days)
+	if i < 0 {
 		return ErrEventNotFound
 	}
+	if eh.conflictingEvent(e, i) {
+		return ErrEventConflict
+	}
+	eh.events[i] = e
 	sortEvents(eh.events)
 	return eh.sched.SetJobs(eh.buildSchedulerJobs())
 }
 
-func (eh *eventHandler) RemoveEvent(t units.TimeOfDay) error {
+// RemoveEvent removes the event at the given time on the given days.
+func (eh *eventHandler) RemoveEvent(t units.TimeOfDay, days units.Weekdays) error {
 	eh.lock.Lock()
 	defer eh.lock.Unlock()
 
 	newEvents := make([]Event, 0)
 	for _, ee := range eh.events {
-		if ee.Time != t {
+		if ee.Time != t || ee.Days != days {
 			newEvents = append(newEvents, ee)
 		}
 	}
@@ -150,25 +260,32 @@ func (eh *eventHandler) NextEvent() *Event {
 	}
 	eh.lock.RLock()
 	defer eh.lock.RUnlock()
-	for _, e := range eh.events {
-		if j.Time == e.Time {
-			return &e
+	if eh.earlyStop != nil && j.Time == eh.earlyStop.Time {
+		early := *eh.earlyStop
+		return &early
+	}
+	var next *Event
+	eh.eachJob(func(e *Event, ej scheduler.Job) {
+		if next == nil && j.Time == ej.Time && j.Days == ej.Days && j.Date == ej.Date {
+			found := *e
+			next = &found
 		}
+	})
+	if next != nil {
+		return next
 	}
-
 	// scheduler is boosted, construct event representing end.
 	return &Event{
 		Time: j.Time,
 	}
 }
 
-func (eh *eventHandler) FindEvent(t units.TimeOfDay) (Event, bool) {
-	eh.lock.Lock()
-	defer eh.lock.Unlock()
-	for _, e := range eh.events {
-		if e.Time == t {
-			return e, true
-		}
+// FindEvent returns the event at the given time on the given days.
+func (eh *eventHandler) FindEvent(t units.TimeOfDay, days units.Weekdays) (Event, bool) {
+	eh.lock.RLock()
+	defer eh.lock.RUnlock()
+	if i := eh.indexOfEvent(t, days); i >= 0 {
+		return eh.events[i], true
 	}
 	return Event{}, false
 }
@@ -184,6 +301,63 @@ func (eh *eventHandler) ReadEvents() []Event {
 	return events
 }
 
+func (eh *eventHandler) AddException(ex Exception) error {
+	if !ex.Valid() || ex.Expired() {
+		return ErrInvalidException
+	}
+	events := make([]Event, 0, len(ex.Events))
+	for _, e := range ex.Events {
+		e.Days = 0 // Not applicable to an event on a specific date.
+		events = append(events, e)
+	}
+	sortEvents(events)
+	ex.Events = events
+
+	eh.lock.Lock()
+	defer eh.lock.Unlock()
+	replaced := false
+	for i, existing := range eh.exceptions {
+		if existing.Date == ex.Date {
+			eh.exceptions[i] = ex
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		eh.exceptions = append(eh.exceptions, ex)
+		sortExceptions(eh.exceptions)
+	}
+	return eh.sched.SetJobs(eh.buildSchedulerJobs())
+}
+
+func (eh *eventHandler) RemoveException(d units.Date) error {
+	eh.lock.Lock()
+	defer eh.lock.Unlock()
+	newExceptions := make([]Exception, 0)
+	for _, ex := range eh.exceptions {
+		if ex.Date != d {
+			newExceptions = append(newExceptions, ex)
+		}
+	}
+	eh.exceptions = newExceptions
+	return eh.sched.SetJobs(eh.buildSchedulerJobs())
+}
+
+// ReadExceptions returns all exceptions that haven't yet expired.
+func (eh *eventHandler) ReadExceptions() []Exception {
+	eh.lock.RLock()
+	defer eh.lock.RUnlock()
+	exceptions := make([]Exception, 0, len(eh.exceptions))
+	for _, ex := range eh.exceptions {
+		if ex.Expired() {
+			continue
+		}
+		ex.Events = append([]Event(nil), ex.Events...)
+		exceptions = append(exceptions, ex)
+	}
+	return exceptions
+}
+
 func (eh *eventHandler) Boosted() bool {
 	eh.lock.RLock()
 	defer eh.lock.RUnlock()
@@ -194,6 +368,7 @@ func (eh *eventHandler) Boost(d time.Duration) {
 	eh.lock.Lock()
 	defer eh.lock.Unlock()
 	eh.boosted = true
+	eh.earlyStop = nil
 	eh.demand(Event{Action: On})
 
 	if d == 0 {
@@ -206,9 +381,9 @@ func (eh *eventHandler) Boost(d time.Duration) {
 		Action: Off,
 	}
 
-	nextEvent := eh.nextEvent()
+	nextEvent, nextAt := eh.nextEventWithTime()
 
-	if nextEvent == nil || endEvent.NextOccurance().Before(nextEvent.NextOccurance()) {
+	if nextEvent == nil || endEvent.NextOccurance().Before(nextAt) {
 		eh.sched.Override(eh.buildSchedulerJob(endEvent))
 	}
 }
@@ -229,3 +404,27 @@ func (eh *eventHandler) CancelBoost() {
 		eh.demand(Event{Action: Off})
 	}
 }
+
+// StopEarly brings the next event forward to the given time. This is only
+// possible if the next event is an Off event due after the given time, and the
+// schedule isn't boosted.
+func (eh *eventHandler) StopEarly(at time.Time) error {
+	eh.lock.Lock()
+	defer eh.lock.Unlock()
+	if eh.boosted {
+		return ErrCannotStopEarly
+	}
+	if eh.earlyStop != nil {
+		return ErrAlreadyStopping
+	}
+	next, nextAt := eh.nextEventWithTime()
+	if next == nil || next.Action != Off || !at.Before(nextAt) || !at.After(timeNow()) {
+		return ErrCannotStopEarly
+	}
+	e := *next
+	e.Time = units.NewTimeOfDay(at.Local().Clock())
+	e.Days = 0
+	eh.earlyStop = &e
+	eh.sched.Override(eh.buildSchedulerJob(e))
+	return nil
+}
@@ -12,12 +12,13 @@ import (
 
 var _ = Describe("EventHandler internals", func() {
 	var (
-		eh      *eventHandler
-		mockNow time.Time
+		eh *eventHandler
+
+		weekdays = units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+		weekends = units.NewWeekdays(time.Saturday, time.Sunday)
 	)
 
 	BeforeEach(func() {
-		timeNow = func() time.Time { return mockNow }
 		eh = &eventHandler{
 			sched:  new(schedulerfakes.FakeScheduler),
 			demand: func(Event) {},
@@ -38,14 +39,50 @@ var _ = Describe("EventHandler internals", func() {
 			})
 
 			It("returns the next event after now", func() {
-				mockNow = todayAt(7, 15, 0)
+				clock.Set(todayAt(7, 15, 0))
 				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(8, 30), Action: Off}))
-				mockNow = todayAt(10, 30, 0)
+				clock.Set(todayAt(10, 30, 0))
 				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(18, 0), Action: On}))
 			})
 
 			It("returns the first event if there are no more events today", func() {
-				mockNow = todayAt(22, 5, 0)
+				clock.Set(todayAt(22, 5, 0))
+				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(6, 15), Action: On}))
+			})
+		})
+
+		Context("with events restricted to certain days", func() {
+			BeforeEach(func() {
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Days: weekdays, Action: On})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(8, 30), Days: weekdays, Action: Off})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: weekends, Action: On})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(22, 0), Action: Off})
+			})
+
+			It("skips events that don't apply to the day", func() {
+				clock.Set(fridayAt(7, 15, 0))
+				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(8, 30), Days: weekdays, Action: Off}))
+				clock.Set(fridayAt(23, 0, 0))
+				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(9, 0), Days: weekends, Action: On}))
+				clock.Set(fridayAt(23, 0, 0).AddDate(0, 0, 2))
+				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(6, 15), Days: weekdays, Action: On}))
+			})
+		})
+		Context("with an exception", func() {
+			BeforeEach(func() {
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Action: On})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(22, 0), Action: Off})
+				clock.Set(fridayAt(0, 0, 0))
+				// Saturday
+				eh.AddException(Exception{Date: units.NewDate(2018, 9, 29), Events: []Event{
+					{Time: units.NewTimeOfDay(9, 0), Action: On},
+				}})
+			})
+
+			It("uses the exception's events instead of the regular events on that date", func() {
+				clock.Set(fridayAt(23, 0, 0))
+				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(9, 0), Action: On}))
+				clock.Set(fridayAt(10, 0, 0).AddDate(0, 0, 1))
 				Expect(eh.nextEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(6, 15), Action: On}))
 			})
 		})
@@ -65,15 +102,52 @@ var _ = Describe("EventHandler internals", func() {
 			})
 
 			It("returns the event before now", func() {
-				mockNow = todayAt(7, 15, 0)
+				clock.Set(todayAt(7, 15, 0))
 				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(6, 15), Action: On}))
-				mockNow = todayAt(10, 30, 0)
+				clock.Set(todayAt(10, 30, 0))
 				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(8, 30), Action: Off}))
 			})
 
 			It("returns the last event if there are no earlier events today", func() {
-				mockNow = todayAt(6, 5, 0)
+				clock.Set(todayAt(6, 5, 0))
+				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(22, 0), Action: Off}))
+			})
+		})
+
+		Context("with events restricted to certain days", func() {
+			BeforeEach(func() {
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Days: weekdays, Action: On})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(8, 30), Days: weekdays, Action: Off})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: weekends, Action: On})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(22, 0), Action: Off})
+			})
+
+			It("skips events that don't apply to the day", func() {
+				clock.Set(fridayAt(7, 15, 0))
+				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(6, 15), Days: weekdays, Action: On}))
+				// Saturday morning
+				clock.Set(fridayAt(7, 15, 0).AddDate(0, 0, 1))
+				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(22, 0), Action: Off}))
+				clock.Set(fridayAt(10, 0, 0).AddDate(0, 0, 1))
+				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(9, 0), Days: weekends, Action: On}))
+			})
+		})
+		Context("with an exception", func() {
+			BeforeEach(func() {
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Action: On})
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(22, 0), Action: Off})
+				clock.Set(fridayAt(0, 0, 0))
+				// Saturday
+				eh.AddException(Exception{Date: units.NewDate(2018, 9, 29), Events: []Event{
+					{Time: units.NewTimeOfDay(9, 0), Action: On},
+				}})
+			})
+
+			It("uses the exception's events instead of the regular events on that date", func() {
+				clock.Set(fridayAt(8, 0, 0).AddDate(0, 0, 1))
 				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(22, 0), Action: Off}))
+				clock.Set(fridayAt(23, 0, 0).AddDate(0, 0, 1))
+				Expect(eh.previousEvent()).To(Equal(&Event{Time: units.NewTimeOfDay(9, 0), Action: On}))
 			})
 		})
 	})
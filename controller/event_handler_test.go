@@ -60,6 +60,29 @@ var _ = Describe("EventHandler", func() {
 			).NotTo(Succeed())
 		})
 
+		It("should allow events at the same time on different days", func() {
+			Expect(
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(7, 0), Days: units.NewWeekdays(time.Monday, time.Tuesday), Action: On}),
+			).To(Succeed())
+			Expect(
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(7, 0), Days: units.NewWeekdays(time.Saturday), Action: Off}),
+			).To(Succeed())
+			Expect(eh.ReadEvents()).To(HaveLen(2))
+		})
+
+		It("should reject an event at the same time as another on any of the same days", func() {
+			Expect(
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(7, 0), Days: units.NewWeekdays(time.Monday, time.Tuesday), Action: On}),
+			).To(Succeed())
+			Expect(
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(7, 0), Days: units.NewWeekdays(time.Tuesday), Action: Off}),
+			).To(MatchError(ErrEventConflict))
+			Expect(
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(7, 0), Action: Off}),
+			).To(MatchError(ErrEventConflict))
+			Expect(eh.ReadEvents()).To(HaveLen(1))
+		})
+
 		Describe("finding an event by time", func() {
 			BeforeEach(func() {
 				Expect(eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Action: On})).To(Succeed())
@@ -68,13 +91,28 @@ var _ = Describe("EventHandler", func() {
 			})
 
 			It("returns the event with the given time and true", func() {
-				e, ok := eh.FindEvent(units.NewTimeOfDay(8, 30))
+				e, ok := eh.FindEvent(units.NewTimeOfDay(8, 30), 0)
 				Expect(ok).To(BeTrue())
 				Expect(e).To(Equal(Event{Time: units.NewTimeOfDay(8, 30), Action: Off}))
 			})
 
 			It("returns false if no event matches the given time", func() {
-				_, ok := eh.FindEvent(units.NewTimeOfDay(8, 45))
+				_, ok := eh.FindEvent(units.NewTimeOfDay(8, 45), 0)
+				Expect(ok).To(BeFalse())
+			})
+
+			It("matches the days as well as the time", func() {
+				weekend := units.NewWeekdays(time.Saturday, time.Sunday)
+				Expect(eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: weekend, Action: On})).To(Succeed())
+				Expect(eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Monday), Action: Off})).To(Succeed())
+
+				e, ok := eh.FindEvent(units.NewTimeOfDay(9, 0), weekend)
+				Expect(ok).To(BeTrue())
+				Expect(e.Action).To(Equal(On))
+
+				_, ok = eh.FindEvent(units.NewTimeOfDay(9, 0), units.NewWeekdays(time.Saturday))
+				Expect(ok).To(BeFalse())
+				_, ok = eh.FindEvent(units.NewTimeOfDay(8, 30), weekend)
 				Expect(ok).To(BeFalse())
 			})
 		})
@@ -88,7 +126,7 @@ var _ = Describe("EventHandler", func() {
 
 			It("should allow replacing an event", func() {
 				Expect(
-					eh.ReplaceEvent(units.NewTimeOfDay(8, 30), Event{Time: units.NewTimeOfDay(8, 45), Action: Off}),
+					eh.ReplaceEvent(units.NewTimeOfDay(8, 30), 0, Event{Time: units.NewTimeOfDay(8, 45), Action: Off}),
 				).To(Succeed())
 				events := eh.ReadEvents()
 				Expect(events).To(HaveLen(3))
@@ -98,7 +136,7 @@ var _ = Describe("EventHandler", func() {
 
 			It("should re-sort the events after replacing", func() {
 				Expect(
-					eh.ReplaceEvent(units.NewTimeOfDay(8, 30), Event{Time: units.NewTimeOfDay(19, 45), Action: Off}),
+					eh.ReplaceEvent(units.NewTimeOfDay(8, 30), 0, Event{Time: units.NewTimeOfDay(19, 45), Action: Off}),
 				).To(Succeed())
 				events := eh.ReadEvents()
 				Expect(events).To(HaveLen(3))
@@ -107,13 +145,31 @@ var _ = Describe("EventHandler", func() {
 
 			It("should error if the target event doesn't exist", func() {
 				Expect(
-					eh.ReplaceEvent(units.NewTimeOfDay(9, 30), Event{Time: units.NewTimeOfDay(8, 45), Action: Off}),
+					eh.ReplaceEvent(units.NewTimeOfDay(9, 30), 0, Event{Time: units.NewTimeOfDay(8, 45), Action: Off}),
 				).NotTo(Succeed())
 			})
 
+			It("should only replace the event on the given days", func() {
+				Expect(eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Saturday), Action: On})).To(Succeed())
+				Expect(eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Sunday), Action: On})).To(Succeed())
+				Expect(
+					eh.ReplaceEvent(units.NewTimeOfDay(9, 0), units.NewWeekdays(time.Sunday), Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Sunday), Action: Off}),
+				).To(Succeed())
+				events := eh.ReadEvents()
+				Expect(events).To(ContainElement(Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Saturday), Action: On}))
+				Expect(events).To(ContainElement(Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Sunday), Action: Off}))
+			})
+
+			It("should error if the replacement conflicts with another event", func() {
+				Expect(
+					eh.ReplaceEvent(units.NewTimeOfDay(8, 30), 0, Event{Time: units.NewTimeOfDay(18, 0), Action: On}),
+				).To(MatchError(ErrEventConflict))
+				Expect(eh.ReadEvents()).To(ContainElement(Event{Time: units.NewTimeOfDay(8, 30), Action: Off}))
+			})
+
 			It("should send the updated events list to the scheduler", func() {
 				Expect(
-					eh.ReplaceEvent(units.NewTimeOfDay(8, 30), Event{Time: units.NewTimeOfDay(8, 45), Action: Off}),
+					eh.ReplaceEvent(units.NewTimeOfDay(8, 30), 0, Event{Time: units.NewTimeOfDay(8, 45), Action: Off}),
 				).To(Succeed())
 				Expect(sched.SetJobsCallCount()).To(Equal(1))
 				jobs := sched.SetJobsArgsForCall(0)
@@ -135,13 +191,28 @@ var _ = Describe("EventHandler", func() {
 				eh.AddEvent(Event{Time: units.NewTimeOfDay(18, 0), Action: Off}),
 			).To(Succeed())
 
-			Expect(eh.RemoveEvent(units.NewTimeOfDay(8, 30))).To(Succeed())
+			Expect(eh.RemoveEvent(units.NewTimeOfDay(8, 30), 0)).To(Succeed())
 
 			events := eh.ReadEvents()
 			Expect(events).To(HaveLen(2))
 			Expect(events).NotTo(ContainElement(Event{Time: units.NewTimeOfDay(8, 30), Action: Off}))
 		})
 
+		It("should only remove the event on the given days", func() {
+			Expect(
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Saturday), Action: On}),
+			).To(Succeed())
+			Expect(
+				eh.AddEvent(Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Sunday), Action: On}),
+			).To(Succeed())
+
+			Expect(eh.RemoveEvent(units.NewTimeOfDay(9, 0), units.NewWeekdays(time.Saturday))).To(Succeed())
+
+			Expect(eh.ReadEvents()).To(Equal([]Event{
+				{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Sunday), Action: On},
+			}))
+		})
+
 		It("should return a copy of the events list", func() {
 			Expect(
 				eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Action: On}),
@@ -165,6 +236,109 @@ var _ = Describe("EventHandler", func() {
 		})
 	})
 
+	Describe("adding, removing and reading exceptions", func() {
+		var (
+			sched *schedulerfakes.FakeScheduler
+			eh    EventHandler
+		)
+
+		BeforeEach(func() {
+			clock.Set(fridayAt(12, 0, 0))
+			sched = &schedulerfakes.FakeScheduler{}
+			eh = NewEventHandler(sched, func(Event) {})
+			eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 30), Action: On})
+		})
+
+		It("should allow adding and reading exceptions", func() {
+			Expect(eh.AddException(Exception{
+				Date:   units.NewDate(2018, 12, 25),
+				Name:   "Christmas",
+				Events: []Event{{Time: units.NewTimeOfDay(8, 0), Action: On}},
+			})).To(Succeed())
+
+			Expect(eh.ReadExceptions()).To(Equal([]Exception{{
+				Date:   units.NewDate(2018, 12, 25),
+				Name:   "Christmas",
+				Events: []Event{{Time: units.NewTimeOfDay(8, 0), Action: On}},
+			}}))
+		})
+
+		It("should sort the exceptions by date, and their events by time", func() {
+			eh.AddException(Exception{Date: units.NewDate(2018, 12, 26), Events: []Event{}})
+			eh.AddException(Exception{Date: units.NewDate(2018, 12, 25), Events: []Event{
+				{Time: units.NewTimeOfDay(18, 0), Action: Off},
+				{Time: units.NewTimeOfDay(8, 0), Action: On},
+			}})
+
+			exceptions := eh.ReadExceptions()
+			Expect(exceptions).To(HaveLen(2))
+			Expect(exceptions[0].Date).To(Equal(units.NewDate(2018, 12, 25)))
+			Expect(exceptions[0].Events[0].Time).To(Equal(units.NewTimeOfDay(8, 0)))
+			Expect(exceptions[1].Date).To(Equal(units.NewDate(2018, 12, 26)))
+		})
+
+		It("should replace an existing exception for the same date", func() {
+			eh.AddException(Exception{Date: units.NewDate(2018, 12, 25), Name: "first", Events: []Event{}})
+			eh.AddException(Exception{Date: units.NewDate(2018, 12, 25), Name: "second", Events: []Event{}})
+
+			exceptions := eh.ReadExceptions()
+			Expect(exceptions).To(HaveLen(1))
+			Expect(exceptions[0].Name).To(Equal("second"))
+		})
+
+		It("should clear any days from the exception's events", func() {
+			eh.AddException(Exception{Date: units.NewDate(2018, 12, 25), Events: []Event{
+				{Time: units.NewTimeOfDay(8, 0), Days: units.NewWeekdays(time.Monday), Action: On},
+			}})
+
+			Expect(eh.ReadExceptions()[0].Events[0].Days).To(BeZero())
+		})
+
+		It("should return an error for an invalid or past exception", func() {
+			Expect(eh.AddException(Exception{})).To(Equal(ErrInvalidException))
+			Expect(eh.AddException(Exception{
+				Date:   units.NewDate(2018, 12, 25),
+				Events: []Event{{Time: units.NewTimeOfDay(25, 0), Action: On}},
+			})).To(Equal(ErrInvalidException))
+			Expect(eh.AddException(Exception{Date: units.NewDate(2018, 9, 27), Events: []Event{}})).To(Equal(ErrInvalidException))
+
+			Expect(eh.ReadExceptions()).To(BeEmpty())
+		})
+
+		It("should allow removing an exception", func() {
+			eh.AddException(Exception{Date: units.NewDate(2018, 12, 25), Events: []Event{}})
+			eh.AddException(Exception{Date: units.NewDate(2018, 12, 26), Events: []Event{}})
+
+			Expect(eh.RemoveException(units.NewDate(2018, 12, 25))).To(Succeed())
+
+			exceptions := eh.ReadExceptions()
+			Expect(exceptions).To(HaveLen(1))
+			Expect(exceptions[0].Date).To(Equal(units.NewDate(2018, 12, 26)))
+		})
+
+		It("should not return exceptions once their date has passed", func() {
+			eh.AddException(Exception{Date: units.NewDate(2018, 9, 29), Events: []Event{}})
+
+			clock.Set(fridayAt(12, 0, 0).AddDate(0, 0, 2))
+			Expect(eh.ReadExceptions()).To(BeEmpty())
+		})
+
+		It("should send the exception events to the scheduler, and skip the regular events on that date", func() {
+			eh.AddException(Exception{
+				Date:   units.NewDate(2018, 12, 25),
+				Events: []Event{{Time: units.NewTimeOfDay(8, 0), Action: On}},
+			})
+
+			Expect(sched.SetJobsCallCount()).To(Equal(1))
+			jobs := sched.SetJobsArgsForCall(0)
+			Expect(jobs).To(HaveLen(2))
+			Expect(jobs[0].Time).To(Equal(units.NewTimeOfDay(6, 30)))
+			Expect(jobs[0].Except).To(Equal([]units.Date{units.NewDate(2018, 12, 25)}))
+			Expect(jobs[1].Time).To(Equal(units.NewTimeOfDay(8, 0)))
+			Expect(jobs[1].Date).To(Equal(units.NewDate(2018, 12, 25)))
+		})
+	})
+
 	Describe("querying the next event", func() {
 		var (
 			sched *schedulerfakes.FakeScheduler
@@ -202,6 +376,17 @@ var _ = Describe("EventHandler", func() {
 				sched.NextJobReturns(&job)
 				Expect(*eh.NextEvent()).To(Equal(e1))
 			})
+			It("returns the exception event corresponding to the next scheduler job", func() {
+				clock.Set(fridayAt(12, 0, 0))
+				e3 := Event{Time: units.NewTimeOfDay(8, 15), Action: On}
+				Expect(eh.AddException(Exception{Date: units.NewDate(2018, 12, 25), Events: []Event{e3}})).To(Succeed())
+
+				job := e3.buildSchedulerJob(func(e Event) {})
+				job.Date = units.NewDate(2018, 12, 25)
+				sched.NextJobReturns(&job)
+				Expect(*eh.NextEvent()).To(Equal(e3))
+			})
+
 			It("when boosted it returns a dummy event representing the end of the boost", func() {
 				job := Event{Time: units.NewTimeOfDay(16, 12)}.buildSchedulerJob(func(e Event) {})
 				sched.NextJobReturns(&job)
@@ -212,16 +397,12 @@ var _ = Describe("EventHandler", func() {
 
 	Describe("bost function", func() {
 		var (
-			mockNow     time.Time
 			sched       *schedulerfakes.FakeScheduler
 			eh          EventHandler
 			activations []Event
 		)
 
 		BeforeEach(func() {
-			timeNow = func() time.Time {
-				return mockNow
-			}
 			activations = nil
 			sched = new(schedulerfakes.FakeScheduler)
 			eh = NewEventHandler(sched, func(e Event) {
@@ -235,7 +416,7 @@ var _ = Describe("EventHandler", func() {
 
 		Describe("boosting", func() {
 			It("activates and schedules a deactivation", func() {
-				mockNow = todayAt(9, 0, 0)
+				clock.Set(todayAt(9, 0, 0))
 				eh.Boost(30 * time.Minute)
 
 				Expect(activations).To(HaveLen(1))
@@ -252,7 +433,7 @@ var _ = Describe("EventHandler", func() {
 			})
 
 			It("does not schedule a deactivation if there's already an activation within the duration", func() {
-				mockNow = todayAt(15, 0, 0)
+				clock.Set(todayAt(15, 0, 0))
 				eh.Boost(time.Hour)
 
 				Expect(activations).To(HaveLen(1))
@@ -262,7 +443,7 @@ var _ = Describe("EventHandler", func() {
 			})
 
 			It("activates and does not schedule a deactivation if called with 0 duration", func() {
-				mockNow = todayAt(12, 0, 0)
+				clock.Set(todayAt(12, 0, 0))
 				eh.Boost(0)
 
 				Expect(activations).To(HaveLen(1))
@@ -274,22 +455,22 @@ var _ = Describe("EventHandler", func() {
 
 		Describe("cancelling the boost", func() {
 			It("cancels the scheduler override", func() {
-				mockNow = todayAt(14, 0, 0)
+				clock.Set(todayAt(14, 0, 0))
 				eh.Boost(30 * time.Minute)
 				activations = nil
 
-				mockNow = todayAt(14, 10, 0)
+				clock.Set(todayAt(14, 10, 0))
 				eh.CancelBoost()
 
 				Expect(sched.CancelOverrideCallCount()).To(Equal(1))
 			})
 
 			It("restores the initial zone state", func() {
-				mockNow = todayAt(14, 0, 0)
+				clock.Set(todayAt(14, 0, 0))
 				eh.Boost(30 * time.Minute)
 				activations = nil
 
-				mockNow = todayAt(14, 10, 0)
+				clock.Set(todayAt(14, 10, 0))
 				eh.CancelBoost()
 
 				Expect(activations).To(HaveLen(1))
@@ -300,11 +481,11 @@ var _ = Describe("EventHandler", func() {
 				eh = NewEventHandler(sched, func(e Event) {
 					activations = append(activations, e)
 				})
-				mockNow = todayAt(14, 0, 0)
+				clock.Set(todayAt(14, 0, 0))
 				eh.Boost(30 * time.Minute)
 				activations = nil
 
-				mockNow = todayAt(14, 10, 0)
+				clock.Set(todayAt(14, 10, 0))
 				eh.CancelBoost()
 
 				Expect(activations).To(HaveLen(1))
@@ -325,17 +506,17 @@ var _ = Describe("EventHandler", func() {
 			})
 
 			It("is boosted once boosted", func() {
-				mockNow = todayAt(13, 0, 0)
+				clock.Set(todayAt(13, 0, 0))
 				eh.Boost(30 * time.Minute)
 
 				Expect(eh.Boosted()).To(BeTrue())
 			})
 
 			It("is not boosted once the scheduleld deactivation has triggered", func() {
-				mockNow = todayAt(13, 0, 0)
+				clock.Set(todayAt(13, 0, 0))
 				eh.Boost(30 * time.Minute)
 
-				mockNow = todayAt(13, 30, 0)
+				clock.Set(todayAt(13, 30, 0))
 				Expect(sched.OverrideCallCount()).To(Equal(1))
 				se := sched.OverrideArgsForCall(0)
 				se.Action()
@@ -344,10 +525,10 @@ var _ = Describe("EventHandler", func() {
 			})
 
 			It("is not boosted once the next non-override event has fired", func() {
-				mockNow = todayAt(15, 0, 0)
+				clock.Set(todayAt(15, 0, 0))
 				eh.Boost(45 * time.Minute)
 
-				mockNow = todayAt(15, 30, 0)
+				clock.Set(todayAt(15, 30, 0))
 				Expect(sched.AddJobCallCount()).To(Equal(4)) // From BeforeEach
 				nextJob := sched.AddJobArgsForCall(2)
 				nextJob.Action()
@@ -356,14 +537,90 @@ var _ = Describe("EventHandler", func() {
 			})
 
 			It("is not boosted when the boost has been cancelled", func() {
-				mockNow = todayAt(13, 0, 0)
+				clock.Set(todayAt(13, 0, 0))
 				eh.Boost(30 * time.Minute)
 
-				mockNow = todayAt(13, 10, 0)
+				clock.Set(todayAt(13, 10, 0))
 				eh.CancelBoost()
 
 				Expect(eh.Boosted()).To(BeFalse())
 			})
 		})
 	})
+
+	Describe("stopping early", func() {
+		var (
+			sched       *schedulerfakes.FakeScheduler
+			eh          EventHandler
+			activations []Event
+			off         Event
+		)
+
+		BeforeEach(func() {
+			clock.Set(todayAt(21, 0, 0))
+			activations = nil
+			sched = new(schedulerfakes.FakeScheduler)
+			eh = NewEventHandler(sched, func(e Event) {
+				activations = append(activations, e)
+			})
+			off = Event{Time: units.NewTimeOfDay(22, 0), Action: Off, ThermAction: &ThermostatAction{Action: SetTarget, Param: 16000}}
+			eh.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Action: On})
+			eh.AddEvent(off)
+		})
+
+		It("reports the next scheduled event and its time", func() {
+			e, at := eh.NextScheduledEvent()
+			Expect(*e).To(Equal(off))
+			Expect(at).To(Equal(todayAt(22, 0, 0)))
+		})
+
+		It("brings the next Off event forward", func() {
+			Expect(eh.StopEarly(todayAt(21, 20, 0))).To(Succeed())
+
+			Expect(sched.OverrideCallCount()).To(Equal(1))
+			j := sched.OverrideArgsForCall(0)
+			Expect(j.Time).To(Equal(units.NewTimeOfDay(21, 20)))
+			j.Action()
+			Expect(activations).To(HaveLen(1))
+			Expect(activations[0].Action).To(Equal(Off))
+			Expect(activations[0].ThermAction).To(Equal(off.ThermAction))
+		})
+
+		It("reports the adjusted time as the next event", func() {
+			Expect(eh.StopEarly(todayAt(21, 20, 0))).To(Succeed())
+			j := sched.OverrideArgsForCall(0)
+			sched.NextJobReturns(&j)
+
+			next := eh.NextEvent()
+			Expect(next.Time).To(Equal(units.NewTimeOfDay(21, 20)))
+			Expect(next.Action).To(Equal(Off))
+
+			e, at := eh.NextScheduledEvent()
+			Expect(e.Time).To(Equal(off.Time))
+			Expect(at).To(Equal(todayAt(22, 0, 0)))
+		})
+
+		It("can't bring forward an On event", func() {
+			clock.Set(todayAt(5, 0, 0))
+			Expect(eh.StopEarly(todayAt(5, 30, 0))).To(Equal(ErrCannotStopEarly))
+			Expect(sched.OverrideCallCount()).To(Equal(0))
+		})
+
+		It("can't move the event later, or into the past", func() {
+			Expect(eh.StopEarly(todayAt(22, 30, 0))).To(Equal(ErrCannotStopEarly))
+			Expect(eh.StopEarly(todayAt(20, 30, 0))).To(Equal(ErrCannotStopEarly))
+			Expect(sched.OverrideCallCount()).To(Equal(0))
+		})
+
+		It("only brings the event forward once", func() {
+			Expect(eh.StopEarly(todayAt(21, 20, 0))).To(Succeed())
+			Expect(eh.StopEarly(todayAt(21, 10, 0))).To(Equal(ErrAlreadyStopping))
+			Expect(sched.OverrideCallCount()).To(Equal(1))
+		})
+
+		It("can't stop early while boosted", func() {
+			eh.Boost(0)
+			Expect(eh.StopEarly(todayAt(21, 20, 0))).To(Equal(ErrCannotStopEarly))
+		})
+	})
 })
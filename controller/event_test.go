@@ -1,6 +1,8 @@
 package controller_test
 
 import (
+	"time"
+
 	"github.com/alext/heating-controller/controller"
 	"github.com/alext/heating-controller/units"
 
@@ -18,4 +20,16 @@ var _ = Describe("Event", func() {
 			Expect(controller.Event{Time: units.NewTimeOfDay(25, 0)}.Valid()).To(BeFalse())
 		})
 	})
+
+	Describe("string representation", func() {
+		It("includes the time and action", func() {
+			e := controller.Event{Time: units.NewTimeOfDay(6, 30), Action: controller.On}
+			Expect(e.String()).To(Equal("6:30 On"))
+		})
+
+		It("includes the days for an event restricted to certain days", func() {
+			e := controller.Event{Time: units.NewTimeOfDay(6, 30), Days: units.NewWeekdays(time.Saturday, time.Sunday), Action: controller.On}
+			Expect(e.String()).To(Equal("Sat,Sun 6:30 On"))
+		})
+	})
 })
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alext/heating-controller/units"
+)
+
+// Exception replaces the regular events for a specific date.
+type Exception struct {
+	Date   units.Date `json:"date"`
+	Name   string     `json:"name,omitempty"`
+	Events []Event    `json:"events"`
+}
+
+func (ex Exception) Valid() bool {
+	if ex.Date.IsZero() {
+		return false
+	}
+	for _, e := range ex.Events {
+		if !e.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+// Expired returns true once the exception's date has passed.
+func (ex Exception) Expired() bool {
+	return ex.Date.Before(units.DateOf(timeNow().Local()))
+}
+
+func (ex Exception) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s", ex.Date)
+	if ex.Name != "" {
+		fmt.Fprintf(&b, " (%s)", ex.Name)
+	}
+	return b.String()
+}
+
+func sortExceptions(exceptions []Exception) {
+	sort.Slice(exceptions, func(i, j int) bool {
+		return exceptions[i].Date.Before(exceptions[j].Date)
+	})
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alext/heating-controller/sensor"
+)
+
+// How often the thermostat sensor is checked for stale readings. Zones using
+// the duty cycle policy are also checked whenever the duty cycle switches.
+const staleCheckInterval = 30 * time.Second
+
+var ErrInvalidStalePolicy = errors.New("invalid stale sensor policy")
+
+// StaleMode is what a zone does in place of following its thermostat while
+// the thermostat's sensor is stale.
+type StaleMode string
+
+const (
+	// StaleOff keeps the zone off.
+	StaleOff StaleMode = "off"
+	// StaleDutyCycle switches the zone on for a fixed fraction of each cycle
+	// while the schedule calls for heat.
+	StaleDutyCycle StaleMode = "duty_cycle"
+	// StaleSchedule follows the schedule alone.
+	StaleSchedule StaleMode = "schedule"
+)
+
+// StalePolicy configures the fallback used while a zone's thermostat sensor is
+// stale.
+type StalePolicy struct {
+	Mode StaleMode
+	// The fraction of each cycle to be on for in StaleDutyCycle mode.
+	DutyCycle   float64
+	CycleLength time.Duration
+}
+
+var DefaultStalePolicy = StalePolicy{
+	Mode:        StaleOff,
+	DutyCycle:   0.3,
+	CycleLength: 10 * time.Minute,
+}
+
+func (p StalePolicy) Valid() bool {
+	switch p.Mode {
+	case StaleOff, StaleSchedule:
+		return true
+	case StaleDutyCycle:
+		return p.DutyCycle > 0 && p.DutyCycle <= 1 && p.CycleLength > 0
+	}
+	return false
+}
+
+func (p StalePolicy) String() string {
+	if p.Mode == StaleDutyCycle {
+		return fmt.Sprintf("%s %.0f%% of %s", p.Mode, p.DutyCycle*100, p.CycleLength)
+	}
+	return string(p.Mode)
+}
+
+// demandAt returns the thermostat demand to use at the given time.
+func (p StalePolicy) demandAt(t time.Time) bool {
+	switch p.Mode {
+	case StaleSchedule:
+		return true
+	case StaleDutyCycle:
+		intoCycle := t.Sub(t.Truncate(p.CycleLength))
+		return intoCycle < time.Duration(p.DutyCycle*float64(p.CycleLength))
+	}
+	return false
+}
+
+// untilChange returns how long after the given time demandAt next changes, or
+// zero if it never does.
+func (p StalePolicy) untilChange(t time.Time) time.Duration {
+	if p.Mode != StaleDutyCycle {
+		return 0
+	}
+	intoCycle := t.Sub(t.Truncate(p.CycleLength))
+	onFor := time.Duration(p.DutyCycle * float64(p.CycleLength))
+	if intoCycle < onFor {
+		return onFor - intoCycle
+	}
+	return p.CycleLength - intoCycle
+}
+
+// SetupStaleFailsafe watches the age of the readings from the given sensor.
+// Whenever the latest reading is older than maxAge, or the sensor reports
+// itself stale, the zone stops following its thermostat and falls back to the
+// given policy. Frost protection is suspended too, as it would otherwise act
+// on the last reading.
+func (z *Zone) SetupStaleFailsafe(source sensor.Sensor, maxAge time.Duration, policy StalePolicy) error {
+	if !policy.Valid() || maxAge <= 0 {
+		return ErrInvalidStalePolicy
+	}
+	z.lock.Lock()
+	z.staleSource = source
+	z.staleMaxAge = maxAge
+	z.stalePolicy = &policy
+	z.staleWatchedSince = timeNow()
+	z.lock.Unlock()
+
+	z.checkStale()
+	return nil
+}
+
+// StalePolicy returns the stale sensor policy, or nil if the zone doesn't
+// watch for stale readings.
+func (z *Zone) StalePolicy() *StalePolicy {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if z.stalePolicy == nil {
+		return nil
+	}
+	p := *z.stalePolicy
+	return &p
+}
+
+// SensorStale returns whether the zone is falling back to its stale sensor
+// policy.
+func (z *Zone) SensorStale() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.sensorStale
+}
+
+// stopStaleFailsafe stops checking for stale readings.
+func (z *Zone) stopStaleFailsafe() {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.staleStopped = true
+	if z.staleTimer != nil {
+		z.staleTimer.Stop()
+	}
+}
+
+func (z *Zone) checkStale() {
+	_, updatedAt := z.staleSource.Read()
+	now := timeNow()
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if z.staleStopped {
+		return
+	}
+	if updatedAt.IsZero() {
+		// Allow a newly started sensor time to get its first reading.
+		updatedAt = z.staleWatchedSince
+	}
+	stale := now.Sub(updatedAt) > z.staleMaxAge
+	if sr, ok := z.staleSource.(sensor.StaleReporter); ok && sr.Stale() {
+		stale = true
+	}
+	if stale && !z.sensorStale {
+		log.Printf("[Zone:%s] Sensor stale, last reading at %s, falling back to %s", z.ID, updatedAt.Format(time.RFC3339), z.stalePolicy)
+	} else if !stale && z.sensorStale {
+		log.Printf("[Zone:%s] Sensor readings resumed, following thermostat", z.ID)
+	}
+	z.sensorStale = stale
+	z.staleDemand = stale && z.stalePolicy.demandAt(now)
+	z.updateDemand()
+
+	next := staleCheckInterval
+	if d := z.stalePolicy.untilChange(now); stale && d > 0 && d < next {
+		next = d
+	}
+	z.staleTimer = afterFunc(next, z.checkStale)
+}
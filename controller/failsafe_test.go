@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/sensor"
+)
+
+type staleReportingSensor struct {
+	sensor.SettableSensor
+	stale bool
+}
+
+func (s *staleReportingSensor) Stale() bool { return s.stale }
+
+var _ = Describe("Stale sensor failsafe", func() {
+	var (
+		z    *Zone
+		sens sensor.SettableSensor
+
+		timerDuration time.Duration
+		timerFunc     func()
+	)
+
+	BeforeEach(func() {
+		clock.Set(fridayAt(12, 0, 0))
+		timerDuration, timerFunc = 0, nil
+		afterFunc = func(d time.Duration, f func()) *time.Timer {
+			timerDuration, timerFunc = d, f
+			return nil
+		}
+
+		sens = sensor.NewPushSensor("foo", "something")
+		sens.Set(19000, clock.Now())
+		z = NewZone("one", output.Virtual("one"))
+		z.schedulerDemand(true)
+		z.thermostatDemand(true)
+	})
+
+	AfterEach(func() {
+		afterFunc = time.AfterFunc
+	})
+
+	It("rejects an invalid policy", func() {
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, StalePolicy{Mode: "fuzzy"})).To(Equal(ErrInvalidStalePolicy))
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, StalePolicy{Mode: StaleDutyCycle, DutyCycle: 1.5, CycleLength: time.Minute})).To(Equal(ErrInvalidStalePolicy))
+		Expect(z.SetupStaleFailsafe(sens, 0, DefaultStalePolicy)).To(Equal(ErrInvalidStalePolicy))
+		Expect(z.StalePolicy()).To(BeNil())
+	})
+
+	It("follows the thermostat while the readings are fresh", func() {
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, DefaultStalePolicy)).To(Succeed())
+		Expect(timerDuration).To(Equal(staleCheckInterval))
+
+		clock.Add(10 * time.Minute)
+		timerFunc()
+		Expect(z.SensorStale()).To(BeFalse())
+		Expect(z.Active()).To(BeTrue())
+	})
+
+	It("switches off once the readings are stale with the off policy", func() {
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, DefaultStalePolicy)).To(Succeed())
+
+		clock.Add(11 * time.Minute)
+		timerFunc()
+		Expect(z.SensorStale()).To(BeTrue())
+		Expect(z.Active()).To(BeFalse())
+	})
+
+	It("resumes following the thermostat when readings resume", func() {
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, DefaultStalePolicy)).To(Succeed())
+		clock.Add(11 * time.Minute)
+		timerFunc()
+
+		sens.Set(19500, clock.Now())
+		timerFunc()
+		Expect(z.SensorStale()).To(BeFalse())
+		Expect(z.Active()).To(BeTrue())
+	})
+
+	It("treats a sensor reporting itself stale as stale", func() {
+		stale := &staleReportingSensor{SettableSensor: sens, stale: true}
+		Expect(z.SetupStaleFailsafe(stale, 10*time.Minute, DefaultStalePolicy)).To(Succeed())
+		Expect(z.SensorStale()).To(BeTrue())
+		Expect(z.Active()).To(BeFalse())
+
+		stale.stale = false
+		timerFunc()
+		Expect(z.SensorStale()).To(BeFalse())
+		Expect(z.Active()).To(BeTrue())
+	})
+
+	It("follows the schedule alone with the schedule policy", func() {
+		z.thermostatDemand(false)
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, StalePolicy{Mode: StaleSchedule})).To(Succeed())
+
+		clock.Add(11 * time.Minute)
+		timerFunc()
+		Expect(z.Active()).To(BeTrue())
+
+		z.schedulerDemand(false)
+		Expect(z.Active()).To(BeFalse())
+	})
+
+	It("switches on for a fraction of each cycle with the duty cycle policy", func() {
+		z.thermostatDemand(false)
+		policy := StalePolicy{Mode: StaleDutyCycle, DutyCycle: 0.3, CycleLength: 10 * time.Minute}
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, policy)).To(Succeed())
+
+		clock.Set(fridayAt(12, 20, 0))
+		timerFunc()
+		Expect(z.Active()).To(BeTrue())
+
+		clock.Set(fridayAt(12, 22, 30))
+		timerFunc()
+		Expect(z.Active()).To(BeTrue())
+
+		clock.Set(fridayAt(12, 23, 0))
+		timerFunc()
+		Expect(z.Active()).To(BeFalse())
+
+		clock.Set(fridayAt(12, 30, 0))
+		timerFunc()
+		Expect(z.Active()).To(BeTrue())
+	})
+
+	It("checks whenever the duty cycle switches with the duty cycle policy", func() {
+		policy := StalePolicy{Mode: StaleDutyCycle, DutyCycle: 0.25, CycleLength: time.Minute}
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, policy)).To(Succeed())
+		Expect(timerDuration).To(Equal(staleCheckInterval))
+
+		clock.Set(fridayAt(12, 20, 0))
+		timerFunc()
+		Expect(z.Active()).To(BeTrue())
+		Expect(timerDuration).To(Equal(15 * time.Second))
+
+		clock.Add(timerDuration)
+		timerFunc()
+		Expect(z.Active()).To(BeFalse())
+		Expect(timerDuration).To(Equal(staleCheckInterval))
+
+		clock.Add(timerDuration)
+		timerFunc()
+		Expect(z.Active()).To(BeFalse())
+		Expect(timerDuration).To(Equal(15 * time.Second))
+
+		clock.Add(timerDuration)
+		timerFunc()
+		Expect(z.Active()).To(BeTrue())
+	})
+
+	It("suspends frost protection while the readings are stale", func() {
+		z.schedulerDemand(false)
+		sens.Set(4000, clock.Now())
+		z.SetupFrostProtection(sens, 5000)
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, DefaultStalePolicy)).To(Succeed())
+		Expect(z.FrostProtectionActive()).To(BeTrue())
+		Expect(z.Active()).To(BeTrue())
+
+		clock.Add(11 * time.Minute)
+		timerFunc()
+		Expect(z.FrostProtectionActive()).To(BeFalse())
+		Expect(z.Active()).To(BeFalse())
+
+		sens.Set(4500, clock.Now())
+		timerFunc()
+		Expect(z.FrostProtectionActive()).To(BeTrue())
+		Expect(z.Active()).To(BeTrue())
+	})
+
+	It("stops checking once stopped", func() {
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, DefaultStalePolicy)).To(Succeed())
+		z.stopStaleFailsafe()
+
+		f := timerFunc
+		timerFunc = nil
+		clock.Add(11 * time.Minute)
+		f()
+		Expect(timerFunc).To(BeNil())
+		Expect(z.SensorStale()).To(BeFalse())
+	})
+
+	It("allows a sensor time to get its first reading", func() {
+		sens = sensor.NewPushSensor("bar", "something")
+		Expect(z.SetupStaleFailsafe(sens, 10*time.Minute, DefaultStalePolicy)).To(Succeed())
+		Expect(z.SensorStale()).To(BeFalse())
+
+		clock.Add(11 * time.Minute)
+		timerFunc()
+		Expect(z.SensorStale()).To(BeTrue())
+	})
+})
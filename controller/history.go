@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/sensor"
+)
+
+// historySaveInterval is how often the persisted sensor histories are saved.
+const historySaveInterval = 10 * time.Minute
+
+func historyFilename() string {
+	return filepath.Join(DataDir, "sensor_history.json")
+}
+
+// persistedHistorySensors returns the names of the sensors whose history is
+// configured to be persisted.
+func persistedHistorySensors(sensors map[string]config.SensorConfig) []string {
+	var names []string
+	for name, cfg := range sensors {
+		if cfg.History != nil && cfg.History.Persist {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setupHistoryPersistence restores the saved history of the given sensors,
+// and then saves it periodically.
+func (c *Controller) setupHistoryPersistence(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	c.historyLock.Lock()
+	c.persistHistory = names
+	c.historyLock.Unlock()
+
+	err := c.restoreHistory()
+	if err != nil {
+		return err
+	}
+	c.scheduleHistorySave()
+	return nil
+}
+
+func (c *Controller) scheduleHistorySave() {
+	c.timersLock.Lock()
+	defer c.timersLock.Unlock()
+	if c.closed {
+		return
+	}
+	c.historyTimer = afterFunc(historySaveInterval, c.periodicSaveHistory)
+}
+
+func (c *Controller) periodicSaveHistory() {
+	c.SaveHistory()
+	c.scheduleHistorySave()
+}
+
+// SaveHistory saves the history of the sensors configured to persist it.
+func (c *Controller) SaveHistory() error {
+	c.historyLock.Lock()
+	defer c.historyLock.Unlock()
+	if len(c.persistHistory) == 0 {
+		return nil
+	}
+
+	histories := make(map[string][]sensor.Reading, len(c.persistHistory))
+	for _, name := range c.persistHistory {
+		s, _ := c.Sensor(name)
+		if hs, ok := s.(sensor.HistorySensor); ok {
+			histories[name] = hs.History().Readings()
+		}
+	}
+
+	err := saveJSONFile(historyFilename(), histories)
+	if err != nil {
+		log.Printf("[Controller] Error saving sensor history: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) restoreHistory() error {
+	file, err := os.Open(historyFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var histories map[string][]sensor.Reading
+	err = json.NewDecoder(file).Decode(&histories)
+	if err != nil {
+		// Carry on without it rather than failing to start.
+		log.Printf("[Controller] Discarding saved sensor history, error parsing: %s", err.Error())
+		return nil
+	}
+
+	c.historyLock.Lock()
+	defer c.historyLock.Unlock()
+	for _, name := range c.persistHistory {
+		s, _ := c.Sensor(name)
+		hs, ok := s.(sensor.HistorySensor)
+		if !ok {
+			continue
+		}
+		hs.History().Restore(histories[name])
+	}
+	return nil
+}
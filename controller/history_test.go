@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/sensor"
+)
+
+var _ = Describe("Sensor history persistence", func() {
+	var (
+		ctrl          *Controller
+		cfg           *config.Config
+		timerDuration time.Duration
+		timerFunc     func()
+		start         time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		DataDir, err = ioutil.TempDir("", "history_test")
+		Expect(err).NotTo(HaveOccurred())
+
+		timerDuration, timerFunc = 0, nil
+		afterFunc = func(d time.Duration, f func()) *time.Timer {
+			timerDuration, timerFunc = d, f
+			return time.NewTimer(time.Hour)
+		}
+		start = time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		cfg = config.New()
+		cfg.Sensors["foo"] = config.SensorConfig{Type: "push", ID: "1234", History: &config.HistoryConfig{Persist: true}}
+		cfg.Sensors["bar"] = config.SensorConfig{Type: "push", ID: "2345"}
+		ctrl = New()
+		Expect(ctrl.Setup(cfg)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		afterFunc = time.AfterFunc
+		os.RemoveAll(DataDir)
+	})
+
+	var setTemps = func(c *Controller) {
+		c.sensorsByName["foo"].(sensor.SettableSensor).Set(19000, start)
+		c.sensorsByName["foo"].(sensor.SettableSensor).Set(19500, start.Add(time.Minute))
+		c.sensorsByName["bar"].(sensor.SettableSensor).Set(18000, start)
+	}
+
+	var historyOf = func(c *Controller, name string) []sensor.Reading {
+		return c.sensorsByName[name].(sensor.HistorySensor).History().Readings()
+	}
+
+	It("saves the history of sensors configured to persist it", func() {
+		setTemps(ctrl)
+		Expect(ctrl.SaveHistory()).To(Succeed())
+
+		data := readFile(filepath.Join(DataDir, "sensor_history.json"))
+		Expect(data).To(ContainSubstring(`"foo"`))
+		Expect(data).NotTo(ContainSubstring(`"bar"`))
+	})
+
+	It("restores the saved history on setup", func() {
+		setTemps(ctrl)
+		Expect(ctrl.SaveHistory()).To(Succeed())
+
+		ctrl2 := New()
+		Expect(ctrl2.Setup(cfg)).To(Succeed())
+		Expect(historyOf(ctrl2, "foo")).To(HaveLen(2))
+		Expect(historyOf(ctrl2, "foo")[1].Temperature).To(BeEquivalentTo(19500))
+		Expect(historyOf(ctrl2, "foo")[1].At.Equal(start.Add(time.Minute))).To(BeTrue())
+		Expect(historyOf(ctrl2, "bar")).To(BeEmpty())
+	})
+
+	It("discards an invalid saved history on setup", func() {
+		writeJSONToFile(filepath.Join(DataDir, "sensor_history.json"), []string{"foo"})
+		timerFunc = nil
+
+		ctrl2 := New()
+		Expect(ctrl2.Setup(cfg)).To(Succeed())
+		Expect(historyOf(ctrl2, "foo")).To(BeEmpty())
+		Expect(timerFunc).NotTo(BeNil())
+	})
+
+	It("saves the history periodically", func() {
+		Expect(timerDuration).To(Equal(historySaveInterval))
+		Expect(timerFunc).NotTo(BeNil())
+
+		setTemps(ctrl)
+		f := timerFunc
+		timerFunc = nil
+		f()
+		Expect(readFile(filepath.Join(DataDir, "sensor_history.json"))).To(ContainSubstring(`"foo"`))
+		Expect(timerFunc).NotTo(BeNil())
+	})
+
+	It("doesn't save anything if no sensors persist their history", func() {
+		cfg.Sensors["foo"] = config.SensorConfig{Type: "push", ID: "1234"}
+		timerFunc = nil
+		ctrl = New()
+		Expect(ctrl.Setup(cfg)).To(Succeed())
+		Expect(timerFunc).To(BeNil())
+
+		setTemps(ctrl)
+		Expect(ctrl.SaveHistory()).To(Succeed())
+		_, err := os.Stat(filepath.Join(DataDir, "sensor_history.json"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/units"
+)
+
+const (
+	// The longest a zone will be switched on ahead of its scheduled time.
+	maxPreheat = 3 * time.Hour
+	// The smallest warm up that will be used to learn the heating rate.
+	minWarmupRise units.Temperature = 500
+	// The width of the starting temperature bands that rates are learned for.
+	warmupRateBand units.Temperature = 1000
+	// The number of samples the learned rates are averaged over.
+	learnedRateSamples = 5
+)
+
+// WarmupRate is the learned heating rate of a zone when starting from a
+// temperature in the band beginning at StartTemperature.
+type WarmupRate struct {
+	StartTemperature units.Temperature `json:"start_temperature"`
+	// Rate is the temperature rise per hour.
+	Rate    units.Temperature `json:"rate"`
+	Samples int               `json:"samples"`
+}
+
+func (r WarmupRate) String() string {
+	return fmt.Sprintf("from %s: %s/hour", r.StartTemperature, r.Rate)
+}
+
+type warmup struct {
+	start  units.Temperature
+	target units.Temperature
+	at     time.Time
+}
+
+// SetupOptimumStart enables optimum start for the zone. The zone learns how
+// quickly it warms up from the readings of the given sensor, and switches on
+// early so that the target is reached by the time of the next On event.
+func (z *Zone) SetupOptimumStart(source sensor.Sensor) {
+	z.lock.Lock()
+	z.optimumStart = true
+	z.lock.Unlock()
+
+	sub := z.subscribe(source)
+	go func() {
+		for temp := range sub.C {
+			z.optimumStartUpdate(temp)
+		}
+	}()
+}
+
+func (z *Zone) OptimumStart() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.optimumStart
+}
+
+// Preheating returns whether the zone has been switched on ahead of its next
+// scheduled On event.
+func (z *Zone) Preheating() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.preheating != nil
+}
+
+// WarmupRates returns the learned heating rates, ordered by starting
+// temperature.
+func (z *Zone) WarmupRates() []WarmupRate {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if len(z.warmupRates) == 0 {
+		return nil
+	}
+	return append([]WarmupRate(nil), z.warmupRates...)
+}
+
+func (z *Zone) setWarmupRates(rates []WarmupRate) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.warmupRates = append([]WarmupRate(nil), rates...)
+	sortWarmupRates(z.warmupRates)
+}
+
+func (z *Zone) optimumStartUpdate(temp units.Temperature) {
+	if z.completeWarmup(temp) {
+		z.Save()
+	}
+	if e := z.preheatDue(temp); e != nil {
+		log.Printf("[Zone:%s] Optimum start, applying event '%s' early", z.ID, e)
+		z.doApplyEvent(*e)
+		z.lock.Lock()
+		z.preheating = e
+		z.lock.Unlock()
+	}
+}
+
+// consumePreheated returns whether the given event has already been applied
+// early by optimum start. Any record of a preheated event is cleared.
+func (z *Zone) consumePreheated(e Event) bool {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	p := z.preheating
+	z.preheating = nil
+	return p != nil && p.Time == e.Time && p.Action == e.Action
+}
+
+// preheatDue returns the next event if it's an On event that should be
+// applied now to reach its target on time.
+func (z *Zone) preheatDue(temp units.Temperature) *Event {
+	z.lock.RLock()
+	skip := !z.optimumStart || z.Thermostat == nil || z.away || z.schedDemand || z.preheating != nil
+	z.lock.RUnlock()
+	if skip || z.Boosted() {
+		return nil
+	}
+
+	e, at := z.NextScheduledEvent()
+	if e == nil || e.Action != On {
+		return nil
+	}
+	now := timeNow().Local()
+
+	target := z.thermostatTarget()
+	if e.ThermAction != nil {
+		target = e.ThermAction.ResultingTarget(target)
+	}
+	if temp >= target {
+		return nil
+	}
+	lead := z.preheatTime(temp, target)
+	if lead == 0 || now.Before(at.Add(-lead)) {
+		return nil
+	}
+	return e
+}
+
+// preheatTime returns how long it's expected to take to warm up from the
+// current temperature to the target, or 0 if no rate has been learned yet.
+func (z *Zone) preheatTime(current, target units.Temperature) time.Duration {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	var rate *WarmupRate
+	for i, r := range z.warmupRates {
+		if rate == nil || absTemp(warmupBand(current)-r.StartTemperature) < absTemp(warmupBand(current)-rate.StartTemperature) {
+			rate = &z.warmupRates[i]
+		}
+	}
+	if rate == nil || rate.Rate <= 0 {
+		return 0
+	}
+	d := time.Duration(float64(target-current) / float64(rate.Rate) * float64(time.Hour))
+	if d > maxPreheat {
+		d = maxPreheat
+	}
+	return d
+}
+
+// startWarmup starts timing a warm up if the zone is sufficiently below its
+// target.
+func (z *Zone) startWarmup() {
+	if z.Thermostat == nil {
+		return
+	}
+	current, target := z.Thermostat.Current(), z.Thermostat.Target()
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.warmup = nil
+	if !z.optimumStart || z.away || target-current < minWarmupRise {
+		return
+	}
+	z.warmup = &warmup{start: current, target: target, at: timeNow()}
+}
+
+func (z *Zone) cancelWarmup() {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.warmup = nil
+}
+
+// completeWarmup records the heating rate if the given temperature completes
+// a warm up in progress. Returns whether a rate was recorded.
+func (z *Zone) completeWarmup(temp units.Temperature) bool {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	w := z.warmup
+	if w == nil || temp < w.target {
+		return false
+	}
+	z.warmup = nil
+	elapsed := timeNow().Sub(w.at)
+	if elapsed <= 0 {
+		return false
+	}
+	rate := units.Temperature(float64(w.target-w.start) / elapsed.Hours())
+	log.Printf("[Zone:%s] Warmed up from %s to %s in %s, rate %s/hour", z.ID, w.start, w.target, elapsed, rate)
+	z.recordWarmupRate(warmupBand(w.start), rate)
+	return true
+}
+
+// Must be called with the lock held for writing.
+func (z *Zone) recordWarmupRate(band, rate units.Temperature) {
+	for i := range z.warmupRates {
+		r := &z.warmupRates[i]
+		if r.StartTemperature != band {
+			continue
+		}
+		n := r.Samples + 1
+		if n > learnedRateSamples {
+			n = learnedRateSamples
+		}
+		r.Rate += (rate - r.Rate) / units.Temperature(n)
+		r.Samples++
+		return
+	}
+	z.warmupRates = append(z.warmupRates, WarmupRate{StartTemperature: band, Rate: rate, Samples: 1})
+	sortWarmupRates(z.warmupRates)
+}
+
+// warmupBand returns the start of the band containing the given temperature.
+func warmupBand(t units.Temperature) units.Temperature {
+	band := t - t%warmupRateBand
+	if t < 0 && t%warmupRateBand != 0 {
+		band -= warmupRateBand
+	}
+	return band
+}
+
+func absTemp(t units.Temperature) units.Temperature {
+	if t < 0 {
+		return -t
+	}
+	return t
+}
+
+func sortWarmupRates(rates []WarmupRate) {
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].StartTemperature < rates[j].StartTemperature
+	})
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/scheduler"
+	"github.com/alext/heating-controller/scheduler/schedulerfakes"
+	"github.com/alext/heating-controller/thermostat/thermostatfakes"
+)
+
+var _ = Describe("Optimum start", func() {
+	var (
+		z     *Zone
+		therm *thermostatfakes.FakeThermostat
+		sched *schedulerfakes.FakeScheduler
+	)
+
+	BeforeEach(func() {
+		var err error
+		DataDir, err = ioutil.TempDir("", "optimum_start_test")
+		Expect(err).NotTo(HaveOccurred())
+
+		clock.Set(fridayAt(6, 0, 0))
+
+		z = NewZone("one", output.Virtual("one"))
+		sched = new(schedulerfakes.FakeScheduler)
+		z.Scheduler = sched
+		z.EventHandler = NewEventHandler(sched, z.applyEvent)
+		therm = new(thermostatfakes.FakeThermostat)
+		therm.CurrentReturns(17000)
+		therm.TargetReturns(20000)
+		z.Thermostat = therm
+		z.optimumStart = true
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(DataDir)
+	})
+
+	Describe("learning the warm up rate", func() {
+		It("records the rate once the target is reached after an On event", func() {
+			z.applyEvent(Event{Action: On})
+
+			clock.Add(45 * time.Minute)
+			z.optimumStartUpdate(19500)
+			Expect(z.WarmupRates()).To(BeEmpty())
+
+			clock.Add(45 * time.Minute)
+			z.optimumStartUpdate(20000)
+			Expect(z.WarmupRates()).To(Equal([]WarmupRate{
+				{StartTemperature: 17000, Rate: 2000, Samples: 1},
+			}))
+		})
+
+		It("saves the learned rates", func() {
+			z.applyEvent(Event{Action: On})
+			clock.Add(90 * time.Minute)
+			z.optimumStartUpdate(20000)
+
+			data := readFile(filepath.Join(DataDir, "one.json"))
+			Expect(data).To(ContainSubstring(`"warmup_rates"`))
+
+			z2 := NewZone("one", output.Virtual("one"))
+			Expect(z2.Restore()).To(Succeed())
+			Expect(z2.WarmupRates()).To(Equal(z.WarmupRates()))
+		})
+
+		It("groups the rates by starting temperature band, averaging within each", func() {
+			therm.CurrentReturns(17400)
+			z.applyEvent(Event{Action: On})
+			clock.Add(time.Hour)
+			z.optimumStartUpdate(20000)
+
+			therm.CurrentReturns(17000)
+			z.applyEvent(Event{Action: On})
+			clock.Add(time.Hour)
+			z.optimumStartUpdate(20000)
+
+			therm.CurrentReturns(15000)
+			z.applyEvent(Event{Action: On})
+			clock.Add(time.Hour)
+			z.optimumStartUpdate(20000)
+
+			Expect(z.WarmupRates()).To(Equal([]WarmupRate{
+				{StartTemperature: 15000, Rate: 5000, Samples: 1},
+				{StartTemperature: 17000, Rate: 2800, Samples: 2},
+			}))
+		})
+
+		It("doesn't learn from a zone that starts close to its target", func() {
+			therm.CurrentReturns(19600)
+			z.applyEvent(Event{Action: On})
+			clock.Add(10 * time.Minute)
+			z.optimumStartUpdate(20000)
+
+			Expect(z.WarmupRates()).To(BeEmpty())
+		})
+
+		It("abandons a warm up when the zone is switched off", func() {
+			z.applyEvent(Event{Action: On})
+			clock.Add(30 * time.Minute)
+			z.applyEvent(Event{Action: Off})
+			clock.Add(time.Hour)
+			z.optimumStartUpdate(20000)
+
+			Expect(z.WarmupRates()).To(BeEmpty())
+		})
+
+		It("doesn't learn when optimum start isn't enabled", func() {
+			z.optimumStart = false
+			z.applyEvent(Event{Action: On})
+			clock.Add(time.Hour)
+			z.optimumStartUpdate(20000)
+
+			Expect(z.WarmupRates()).To(BeEmpty())
+		})
+	})
+
+	Describe("pre-heating", func() {
+		var e Event
+
+		BeforeEach(func() {
+			therm.TargetReturns(18000)
+			z.setWarmupRates([]WarmupRate{{StartTemperature: 17000, Rate: 2000, Samples: 1}})
+			e = Event{Time: 7 * 3600, Action: On, ThermAction: &ThermostatAction{Action: SetTarget, Param: 20000}}
+			Expect(z.AddEvent(e)).To(Succeed())
+			j := e.buildSchedulerJob(nil)
+			sched.NextJobReturns(&j)
+		})
+
+		It("applies the next On event early enough to reach its target on time", func() {
+			clock.Set(fridayAt(5, 29, 0))
+			z.optimumStartUpdate(17000)
+			Expect(z.SDemand()).To(BeFalse())
+			Expect(z.Preheating()).To(BeFalse())
+
+			clock.Set(fridayAt(5, 31, 0))
+			z.optimumStartUpdate(17000)
+			Expect(z.SDemand()).To(BeTrue())
+			Expect(z.Preheating()).To(BeTrue())
+			Expect(therm.SetCallCount()).To(Equal(1))
+			Expect(therm.SetArgsForCall(0)).To(BeEquivalentTo(20000))
+		})
+
+		It("doesn't apply the event again at the scheduled time", func() {
+			clock.Set(fridayAt(5, 31, 0))
+			z.optimumStartUpdate(17000)
+
+			clock.Set(fridayAt(7, 0, 0))
+			z.applyEvent(e)
+			Expect(therm.SetCallCount()).To(Equal(1))
+			Expect(z.Preheating()).To(BeFalse())
+			Expect(z.SDemand()).To(BeTrue())
+		})
+
+		It("limits how early the zone is switched on", func() {
+			clock.Set(fridayAt(3, 59, 0))
+			z.optimumStartUpdate(10000)
+			Expect(z.SDemand()).To(BeFalse())
+
+			clock.Set(fridayAt(4, 1, 0))
+			z.optimumStartUpdate(10000)
+			Expect(z.SDemand()).To(BeTrue())
+		})
+
+		It("does nothing until a rate has been learned", func() {
+			z.setWarmupRates(nil)
+			clock.Set(fridayAt(6, 59, 0))
+			z.optimumStartUpdate(17000)
+			Expect(z.SDemand()).To(BeFalse())
+		})
+
+		It("does nothing if the next event isn't an On event", func() {
+			off := scheduler.Job{Time: 7 * 3600, Label: "Off"}
+			sched.NextJobReturns(&off)
+			Expect(z.ReplaceEvent(e.Time, e.Days, Event{Time: e.Time, Action: Off})).To(Succeed())
+
+			clock.Set(fridayAt(6, 59, 0))
+			z.optimumStartUpdate(17000)
+			Expect(z.SDemand()).To(BeFalse())
+		})
+
+		It("does nothing while away", func() {
+			z.setAway(true, nil)
+			clock.Set(fridayAt(6, 59, 0))
+			z.optimumStartUpdate(17000)
+			Expect(z.SDemand()).To(BeFalse())
+		})
+	})
+})
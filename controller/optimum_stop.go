@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/units"
+)
+
+// How long after switching off the temperature is sampled to learn the
+// cooling rate.
+const coolingSamplePeriod = time.Hour
+
+// CoolingRate is the learned rate at which a zone cools once switched off.
+type CoolingRate struct {
+	// Rate is the temperature drop per hour.
+	Rate    units.Temperature `json:"rate"`
+	Samples int               `json:"samples"`
+}
+
+func (r CoolingRate) String() string {
+	return fmt.Sprintf("%s/hour", r.Rate)
+}
+
+type cooldown struct {
+	start units.Temperature
+	at    time.Time
+}
+
+// SetupOptimumStop enables optimum stop for the zone. The zone learns how
+// quickly it cools from the readings of the given sensor, and switches off up
+// to maxEarly ahead of an Off event if the temperature is expected to stay
+// within tolerance of the target until then.
+func (z *Zone) SetupOptimumStop(source sensor.Sensor, maxEarly time.Duration, tolerance units.Temperature) {
+	z.lock.Lock()
+	z.optimumStop = true
+	z.optimumStopMaxEarly = maxEarly
+	z.optimumStopTolerance = tolerance
+	z.lock.Unlock()
+
+	sub := z.subscribe(source)
+	go func() {
+		for temp := range sub.C {
+			z.optimumStopUpdate(temp)
+		}
+	}()
+}
+
+func (z *Zone) OptimumStop() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.optimumStop
+}
+
+// CoolingRate returns the learned cooling rate, or nil if none has been
+// learned yet.
+func (z *Zone) CoolingRate() *CoolingRate {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if z.coolingRate == nil {
+		return nil
+	}
+	r := *z.coolingRate
+	return &r
+}
+
+func (z *Zone) setCoolingRate(r CoolingRate) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.coolingRate = &r
+}
+
+func (z *Zone) optimumStopUpdate(temp units.Temperature) {
+	if z.completeCooldown(temp) {
+		z.Save()
+	}
+	if at, ok := z.earlyStopDue(temp); ok {
+		err := z.StopEarly(at)
+		if err == nil {
+			log.Printf("[Zone:%s] Optimum stop, switching off at %s", z.ID, at.Format("15:04:05"))
+		}
+	}
+}
+
+// earlyStopDue returns the time the zone can be switched off if the next event
+// is an Off event that can be brought forward.
+func (z *Zone) earlyStopDue(temp units.Temperature) (time.Time, bool) {
+	z.lock.RLock()
+	skip := !z.optimumStop || z.Thermostat == nil || z.away || !z.schedDemand || z.coolingRate == nil || z.coolingRate.Rate <= 0
+	var rate units.Temperature
+	if !skip {
+		rate = z.coolingRate.Rate
+	}
+	maxEarly, tolerance := z.optimumStopMaxEarly, z.optimumStopTolerance
+	z.lock.RUnlock()
+	if skip || z.Boosted() {
+		return time.Time{}, false
+	}
+
+	e, at := z.NextScheduledEvent()
+	if e == nil || e.Action != Off {
+		return time.Time{}, false
+	}
+	now := timeNow().Local()
+	earliest := at.Add(-maxEarly)
+	if now.Before(earliest) {
+		return time.Time{}, false
+	}
+
+	// How long the temperature can be left to fall before going out of
+	// tolerance.
+	margin := temp - (z.Thermostat.Target() - tolerance)
+	if margin <= 0 {
+		return time.Time{}, false
+	}
+	stopAt := at.Add(-time.Duration(float64(margin) / float64(rate) * float64(time.Hour)))
+	if stopAt.Before(earliest) {
+		stopAt = earliest
+	}
+	if !stopAt.After(now) {
+		stopAt = now.Add(time.Second)
+	}
+	if !stopAt.Before(at) {
+		return time.Time{}, false
+	}
+	return stopAt.Truncate(time.Second), true
+}
+
+// startCooldown starts timing a cool down after the zone has switched off.
+func (z *Zone) startCooldown() {
+	if z.Thermostat == nil {
+		return
+	}
+	current := z.Thermostat.Current()
+
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.cooldown = nil
+	if !z.optimumStop || z.frostDemand {
+		return
+	}
+	z.cooldown = &cooldown{start: current, at: timeNow()}
+}
+
+func (z *Zone) cancelCooldown() {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.cooldown = nil
+}
+
+// completeCooldown records the cooling rate once the cool down has been
+// sampled for long enough. Returns whether a rate was recorded.
+func (z *Zone) completeCooldown(temp units.Temperature) bool {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	c := z.cooldown
+	if c == nil || z.frostDemand {
+		return false
+	}
+	elapsed := timeNow().Sub(c.at)
+	if elapsed < coolingSamplePeriod {
+		return false
+	}
+	z.cooldown = nil
+	rate := units.Temperature(float64(c.start-temp) / elapsed.Hours())
+	if rate < 0 {
+		rate = 0
+	}
+	log.Printf("[Zone:%s] Cooled from %s to %s in %s, rate %s/hour", z.ID, c.start, temp, elapsed, rate)
+	if z.coolingRate == nil {
+		z.coolingRate = &CoolingRate{Rate: rate, Samples: 1}
+		return true
+	}
+	n := z.coolingRate.Samples + 1
+	if n > learnedRateSamples {
+		n = learnedRateSamples
+	}
+	z.coolingRate.Rate += (rate - z.coolingRate.Rate) / units.Temperature(n)
+	z.coolingRate.Samples++
+	return true
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/scheduler/schedulerfakes"
+	"github.com/alext/heating-controller/thermostat/thermostatfakes"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("Optimum stop", func() {
+	var (
+		z     *Zone
+		therm *thermostatfakes.FakeThermostat
+		sched *schedulerfakes.FakeScheduler
+	)
+
+	BeforeEach(func() {
+		var err error
+		DataDir, err = ioutil.TempDir("", "optimum_stop_test")
+		Expect(err).NotTo(HaveOccurred())
+
+		clock.Set(fridayAt(22, 0, 0))
+
+		z = NewZone("one", output.Virtual("one"))
+		sched = new(schedulerfakes.FakeScheduler)
+		z.Scheduler = sched
+		z.EventHandler = NewEventHandler(sched, z.applyEvent)
+		therm = new(thermostatfakes.FakeThermostat)
+		therm.CurrentReturns(20000)
+		therm.TargetReturns(20000)
+		z.Thermostat = therm
+		z.optimumStop = true
+		z.optimumStopMaxEarly = time.Hour
+		z.optimumStopTolerance = 500
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(DataDir)
+	})
+
+	Describe("learning the cooling rate", func() {
+		It("records the rate an hour after an Off event", func() {
+			z.applyEvent(Event{Action: Off})
+
+			clock.Add(30 * time.Minute)
+			z.optimumStopUpdate(19500)
+			Expect(z.CoolingRate()).To(BeNil())
+
+			clock.Add(30 * time.Minute)
+			z.optimumStopUpdate(19200)
+			Expect(z.CoolingRate()).To(Equal(&CoolingRate{Rate: 800, Samples: 1}))
+		})
+
+		It("averages the rate over several samples", func() {
+			z.setCoolingRate(CoolingRate{Rate: 800, Samples: 1})
+
+			z.applyEvent(Event{Action: Off})
+			clock.Add(time.Hour)
+			z.optimumStopUpdate(18800)
+
+			Expect(z.CoolingRate()).To(Equal(&CoolingRate{Rate: 1000, Samples: 2}))
+		})
+
+		It("saves the learned rate", func() {
+			z.applyEvent(Event{Action: Off})
+			clock.Add(time.Hour)
+			z.optimumStopUpdate(19000)
+
+			data := readFile(filepath.Join(DataDir, "one.json"))
+			Expect(data).To(ContainSubstring(`"cooling_rate"`))
+
+			z2 := NewZone("one", output.Virtual("one"))
+			Expect(z2.Restore()).To(Succeed())
+			Expect(z2.CoolingRate()).To(Equal(z.CoolingRate()))
+		})
+
+		It("abandons the sample when the zone is switched on", func() {
+			z.applyEvent(Event{Action: Off})
+			clock.Add(30 * time.Minute)
+			z.applyEvent(Event{Action: On})
+			clock.Add(time.Hour)
+			z.optimumStopUpdate(19000)
+
+			Expect(z.CoolingRate()).To(BeNil())
+		})
+	})
+
+	Describe("stopping early", func() {
+		BeforeEach(func() {
+			z.setCoolingRate(CoolingRate{Rate: 1000, Samples: 1})
+			Expect(z.AddEvent(Event{Time: units.NewTimeOfDay(6, 15), Action: On})).To(Succeed())
+			Expect(z.AddEvent(Event{Time: units.NewTimeOfDay(22, 0), Action: Off})).To(Succeed())
+			z.applyEvent(Event{Action: On})
+		})
+
+		It("brings the Off event forward while the temperature will stay within tolerance", func() {
+			clock.Set(fridayAt(21, 10, 0))
+			z.optimumStopUpdate(20000)
+
+			Expect(sched.OverrideCallCount()).To(Equal(1))
+			Expect(sched.OverrideArgsForCall(0).Time).To(Equal(units.NewTimeOfDay(21, 30)))
+		})
+
+		It("doesn't move the Off event again on later readings", func() {
+			clock.Set(fridayAt(21, 10, 0))
+			z.optimumStopUpdate(20000)
+			clock.Set(fridayAt(21, 11, 0))
+			z.optimumStopUpdate(20500)
+
+			Expect(sched.OverrideCallCount()).To(Equal(1))
+		})
+
+		It("does nothing until within the maximum early stop", func() {
+			clock.Set(fridayAt(20, 50, 0))
+			z.optimumStopUpdate(22000)
+
+			Expect(sched.OverrideCallCount()).To(Equal(0))
+		})
+
+		It("stops straight away if the temperature has plenty of margin", func() {
+			clock.Set(fridayAt(21, 10, 0))
+			z.optimumStopUpdate(21500)
+
+			Expect(sched.OverrideCallCount()).To(Equal(1))
+			Expect(sched.OverrideArgsForCall(0).Time).To(Equal(units.NewTimeOfDay(21, 10, 1)))
+		})
+
+		It("does nothing if the temperature is already outside the tolerance", func() {
+			clock.Set(fridayAt(21, 30, 0))
+			z.optimumStopUpdate(19400)
+
+			Expect(sched.OverrideCallCount()).To(Equal(0))
+		})
+
+		It("does nothing until a cooling rate has been learned", func() {
+			z.coolingRate = nil
+			clock.Set(fridayAt(21, 30, 0))
+			z.optimumStopUpdate(20000)
+
+			Expect(sched.OverrideCallCount()).To(Equal(0))
+		})
+
+		It("does nothing when the zone is already off", func() {
+			z.applyEvent(Event{Action: Off})
+			clock.Set(fridayAt(21, 30, 0))
+			z.optimumStopUpdate(20000)
+
+			Expect(sched.OverrideCallCount()).To(Equal(0))
+		})
+	})
+})
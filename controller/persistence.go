@@ -7,14 +7,42 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/alext/heating-controller/thermostat"
 	"github.com/alext/heating-controller/units"
 )
 
 var DataDir string
 
+// saveJSONFile saves the value as JSON in the given file. It's written to a
+// temporary file first and then renamed into place, so that a failure part
+// way through doesn't lose the previously saved state.
+func saveJSONFile(filename string, v interface{}) error {
+	tmpFilename := filename + ".tmp"
+	file, err := os.Create(tmpFilename)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(v)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, filename)
+}
+
 type zoneData struct {
-	Events           []Event            `json:"events"`
-	ThermostatTarget *units.Temperature `json:"thermostat_target,omitempty"`
+	Events               []Event                `json:"events"`
+	Exceptions           []Exception            `json:"exceptions,omitempty"`
+	ThermostatTarget     *units.Temperature     `json:"thermostat_target,omitempty"`
+	ThermostatHysteresis *thermostat.Hysteresis `json:"thermostat_hysteresis,omitempty"`
+	WarmupRates          []WarmupRate           `json:"warmup_rates,omitempty"`
+	CoolingRate          *CoolingRate           `json:"cooling_rate,omitempty"`
+	RunTime              *runTimeData           `json:"run_time,omitempty"`
 }
 
 func (z *Zone) Restore() error {
@@ -47,9 +75,31 @@ func (z *Zone) Restore() error {
 			log.Printf("[Zone:%s] Error restoring event '%v': %s", z.ID, e, err.Error())
 		}
 	}
+	for _, ex := range data.Exceptions {
+		if ex.Expired() {
+			// Nothing to restore for dates that have passed.
+			continue
+		}
+		err = z.AddException(ex)
+		if err != nil {
+			log.Printf("[Zone:%s] Error restoring exception '%v': %s", z.ID, ex, err.Error())
+		}
+	}
 	if data.ThermostatTarget != nil && z.Thermostat != nil {
 		z.Thermostat.Set(*data.ThermostatTarget)
 	}
+	if data.ThermostatHysteresis != nil && z.Thermostat != nil {
+		z.SetThermostatHysteresis(*data.ThermostatHysteresis)
+	}
+	if len(data.WarmupRates) > 0 {
+		z.setWarmupRates(data.WarmupRates)
+	}
+	if data.CoolingRate != nil {
+		z.setCoolingRate(*data.CoolingRate)
+	}
+	if data.RunTime != nil {
+		z.restoreRunTime(*data.RunTime)
+	}
 	return nil
 }
 
@@ -100,24 +150,27 @@ func handleLegacyFormat(data *zoneData, file io.ReadSeeker) error {
 }
 
 func (z *Zone) Save() error {
-	filename := filepath.Join(DataDir, z.ID+".json")
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Printf("[Zone:%s] Error saving zone state: %s", z.ID, err.Error())
-		return err
+	// Saves can happen periodically as well as on changes.
+	z.saveLock.Lock()
+	defer z.saveLock.Unlock()
+
+	data := zoneData{
+		Events:      z.ReadEvents(),
+		Exceptions:  z.ReadExceptions(),
+		WarmupRates: z.WarmupRates(),
+		CoolingRate: z.CoolingRate(),
+		RunTime:     z.runTimeData(),
 	}
-	defer file.Close()
-
-	data := zoneData{Events: z.ReadEvents()}
 	if z.Thermostat != nil {
 		// temporary variable needed so we can take the address of it.
-		target := z.Thermostat.Target()
+		target := z.thermostatTarget()
 		data.ThermostatTarget = &target
+		// Only a hysteresis set at runtime is saved, so that changes to the
+		// config take effect otherwise.
+		data.ThermostatHysteresis = z.thermostatHysteresisOverride()
 	}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	err = encoder.Encode(data)
+	err := saveJSONFile(filepath.Join(DataDir, z.ID+".json"), data)
 	if err != nil {
 		log.Printf("[Zone:%s] Error saving zone state: %s", z.ID, err.Error())
 		return err
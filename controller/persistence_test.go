@@ -5,11 +5,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/thermostat"
 	"github.com/alext/heating-controller/thermostat/thermostatfakes"
 	"github.com/alext/heating-controller/units"
 )
@@ -38,6 +40,21 @@ var _ = Describe("persisting a zone's state", func() {
 			Expect(data).To(MatchJSON(`{"events":[]}`))
 		})
 
+		It("should keep the previous state if saving fails part way through", func() {
+			z.AddEvent(Event{Time: units.NewTimeOfDay(6, 30), Action: On})
+			Expect(z.Save()).To(Succeed())
+			Expect(filepath.Join(tempDataDir, "ch.json.tmp")).NotTo(BeAnExistingFile())
+
+			// Make the temporary file impossible to create.
+			Expect(os.Mkdir(filepath.Join(tempDataDir, "ch.json.tmp"), 0755)).To(Succeed())
+			z.AddEvent(Event{Time: units.NewTimeOfDay(7, 45), Action: Off})
+			Expect(z.Save()).NotTo(Succeed())
+
+			data := readFile(filepath.Join(tempDataDir, "ch.json"))
+			Expect(data).To(ContainSubstring(`"6:30"`))
+			Expect(data).NotTo(ContainSubstring(`"7:45"`))
+		})
+
 		It("should save the scheduler events to the file", func() {
 			z.AddEvent(Event{Time: units.NewTimeOfDay(6, 30), Action: On, ThermAction: &ThermostatAction{SetTarget, 19000}})
 			z.AddEvent(Event{Time: units.NewTimeOfDay(7, 45), Action: Off})
@@ -54,14 +71,76 @@ var _ = Describe("persisting a zone's state", func() {
 			Expect(data).To(MatchJSON(expected))
 		})
 
+		It("should save the days for events restricted to certain days", func() {
+			z.AddEvent(Event{Time: units.NewTimeOfDay(6, 30), Days: units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday), Action: On})
+			z.AddEvent(Event{Time: units.NewTimeOfDay(7, 45), Action: Off})
+
+			Expect(z.Save()).To(Succeed())
+
+			data := readFile(filepath.Join(tempDataDir, "ch.json"))
+			expected, _ := json.Marshal(map[string]interface{}{
+				"events": []map[string]interface{}{
+					{"time": "6:30", "days": "Mon-Wed", "action": "On"},
+					{"time": "7:45", "action": "Off"},
+				},
+			})
+			Expect(data).To(MatchJSON(expected))
+		})
+
+		It("should save any upcoming exceptions", func() {
+			clock.Set(fridayAt(12, 0, 0))
+			tomorrow := units.NewDate(2018, 9, 29)
+			z.AddEvent(Event{Time: units.NewTimeOfDay(6, 30), Action: On})
+			z.AddException(Exception{
+				Date:   tomorrow,
+				Name:   "Bank holiday",
+				Events: []Event{{Time: units.NewTimeOfDay(8, 0), Action: On}},
+			})
+
+			Expect(z.Save()).To(Succeed())
+
+			data := readFile(filepath.Join(tempDataDir, "ch.json"))
+			expected, _ := json.Marshal(map[string]interface{}{
+				"events": []map[string]interface{}{
+					{"time": "6:30", "action": "On"},
+				},
+				"exceptions": []map[string]interface{}{
+					{
+						"date":   tomorrow.String(),
+						"name":   "Bank holiday",
+						"events": []map[string]interface{}{{"time": "8:00", "action": "On"}},
+					},
+				},
+			})
+			Expect(data).To(MatchJSON(expected))
+		})
+
 		It("should save the thermostat target", func() {
 			t := new(thermostatfakes.FakeThermostat)
 			t.TargetReturns(18500)
+			t.HysteresisReturns(thermostat.Hysteresis{Lower: 200, Upper: 100})
 			z.Thermostat = t
 			Expect(z.Save()).To(Succeed())
 			data := readFile(filepath.Join(tempDataDir, "ch.json"))
 			Expect(data).To(MatchJSON(`{"events":[],"thermostat_target":18500}`))
 		})
+
+		It("should save the thermostat hysteresis only once it's been set at runtime", func() {
+			t := new(thermostatfakes.FakeThermostat)
+			t.TargetReturns(18500)
+			z.Thermostat = t
+			z.setConfiguredHysteresis(thermostat.Hysteresis{Lower: 100, Upper: 100})
+			z.SetThermostatHysteresis(thermostat.Hysteresis{Lower: 200, Upper: 100})
+			Expect(z.Save()).To(Succeed())
+			data := readFile(filepath.Join(tempDataDir, "ch.json"))
+			Expect(data).To(MatchJSON(`{"events":[],"thermostat_target":18500,"thermostat_hysteresis":{"lower":200,"upper":100}}`))
+
+			z.ResetThermostatHysteresis()
+			Expect(t.SetHysteresisArgsForCall(t.SetHysteresisCallCount() - 1)).To(Equal(thermostat.Hysteresis{Lower: 100, Upper: 100}))
+			Expect(z.Save()).To(Succeed())
+			data = readFile(filepath.Join(tempDataDir, "ch.json"))
+			Expect(data).To(MatchJSON(`{"events":[],"thermostat_target":18500}`))
+		})
 	})
 
 	Describe("restoring zone state", func() {
@@ -88,6 +167,45 @@ var _ = Describe("persisting a zone's state", func() {
 			Expect(events[1]).To(Equal(Event{Time: units.NewTimeOfDay(7, 45), Action: Off, ThermAction: nil}))
 		})
 
+		It("should load the days for events restricted to certain days", func() {
+			writeJSONToFile(filepath.Join(tempDataDir, "ch.json"), map[string]interface{}{
+				"events": []map[string]interface{}{
+					{"time": "6:30", "days": "Mon-Fri", "action": "On"},
+					{"time": "8:00", "days": "Sat,Sun", "action": "On"},
+					{"time": "22:00", "action": "Off"},
+				},
+			})
+
+			Expect(z.Restore()).To(Succeed())
+
+			events := z.ReadEvents()
+			Expect(events).To(HaveLen(3))
+			Expect(events[0].Days).To(Equal(units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)))
+			Expect(events[1].Days).To(Equal(units.NewWeekdays(time.Saturday, time.Sunday)))
+			Expect(events[2].Days).To(BeZero(), "events without days should apply every day")
+		})
+
+		It("should load upcoming exceptions and discard expired ones", func() {
+			clock.Set(fridayAt(12, 0, 0))
+			today := units.NewDate(2018, 9, 28)
+			writeJSONToFile(filepath.Join(tempDataDir, "ch.json"), map[string]interface{}{
+				"events": []map[string]interface{}{
+					{"time": "6:30", "action": "On"},
+				},
+				"exceptions": []map[string]interface{}{
+					{"date": today.AddDays(-1).String(), "events": []map[string]interface{}{{"time": "8:00", "action": "On"}}},
+					{"date": today.AddDays(2).String(), "name": "Holiday", "events": []map[string]interface{}{{"time": "9:00", "action": "On"}}},
+				},
+			})
+
+			Expect(z.Restore()).To(Succeed())
+
+			Expect(z.ReadEvents()).To(HaveLen(1))
+			Expect(z.ReadExceptions()).To(Equal([]Exception{
+				{Date: today.AddDays(2), Name: "Holiday", Events: []Event{{Time: units.NewTimeOfDay(9, 0), Action: On}}},
+			}))
+		})
+
 		It("should treat a non-existent data file the same as a file with an empty scheduler event list", func() {
 			Expect(z.Restore()).To(Succeed())
 
@@ -126,6 +244,24 @@ var _ = Describe("persisting a zone's state", func() {
 			Expect(t.SetArgsForCall(0)).To(BeNumerically("==", 19000))
 		})
 
+		It("should restore the thermostat hysteresis", func() {
+			t := new(thermostatfakes.FakeThermostat)
+			z.Thermostat = t
+			writeJSONToFile(filepath.Join(tempDataDir, "ch.json"), map[string]interface{}{
+				"thermostat_hysteresis": map[string]interface{}{"lower": 300, "upper": 50},
+			})
+
+			Expect(z.Restore()).To(Succeed())
+
+			Expect(t.SetHysteresisCallCount()).To(Equal(1))
+			Expect(t.SetHysteresisArgsForCall(0)).To(Equal(thermostat.Hysteresis{Lower: 300, Upper: 50}))
+			Expect(z.HysteresisOverridden()).To(BeTrue())
+
+			// The restored hysteresis takes precedence over the config.
+			z.setConfiguredHysteresis(thermostat.Hysteresis{Lower: 100, Upper: 100})
+			Expect(t.SetHysteresisCallCount()).To(Equal(1))
+		})
+
 		It("should leave the thermostat target unchanged if not present in the file", func() {
 			t := new(thermostatfakes.FakeThermostat)
 			z.Thermostat = t
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// How often the zones are saved to persist their run time.
+	runTimeSaveInterval = 5 * time.Minute
+	// The number of days of daily run time kept.
+	runTimeDays = 62
+)
+
+const runTimeDateFormat = "2006-01-02"
+
+// DailyRunTime is how long a zone's output was active on a day.
+type DailyRunTime struct {
+	Date     time.Time
+	Duration time.Duration
+}
+
+func (d DailyRunTime) String() string {
+	return fmt.Sprintf("%s: %s", d.Date.Format("Mon 2 Jan"), formatRunTime(d.Duration))
+}
+
+// formatRunTime formats the duration in hours and minutes, e.g. "2h05m".
+func formatRunTime(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
+
+// runTimeData is the persisted form of a zone's run time.
+type runTimeData struct {
+	TotalSeconds float64            `json:"total_seconds"`
+	Days         map[string]float64 `json:"days,omitempty"`
+}
+
+// RunTime returns the total time the zone's output has been active.
+func (z *Zone) RunTime() time.Duration {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	total := z.runTotal
+	if !z.activeSince.IsZero() {
+		total += timeNow().Sub(z.activeSince)
+	}
+	return total
+}
+
+// DailyRunTimes returns how long the zone's output was active on each of the
+// given number of days up to and including today, most recent first.
+func (z *Zone) DailyRunTimes(days int) []DailyRunTime {
+	now := timeNow().Local()
+	z.lock.RLock()
+	byDay := z.runDaysUntil(now)
+	z.lock.RUnlock()
+
+	result := make([]DailyRunTime, 0, days)
+	y, m, d := now.Date()
+	for i := 0; i < days; i++ {
+		date := time.Date(y, m, d-i, 0, 0, 0, 0, now.Location())
+		result = append(result, DailyRunTime{Date: date, Duration: byDay[date.Format(runTimeDateFormat)]})
+	}
+	return result
+}
+
+// runDaysUntil returns the run time by day, including any time active up to
+// the given time.
+//
+// Must be called with the lock held.
+func (z *Zone) runDaysUntil(now time.Time) map[string]time.Duration {
+	byDay := make(map[string]time.Duration, len(z.runDays)+1)
+	for day, d := range z.runDays {
+		byDay[day] = d
+	}
+	if !z.activeSince.IsZero() {
+		addRunTimeByDay(byDay, z.activeSince, now)
+	}
+	return byDay
+}
+
+// recordRunTime adds the time the output was active between the given times.
+//
+// Must be called with the lock held for writing.
+func (z *Zone) recordRunTime(from, to time.Time) {
+	if z.runDays == nil {
+		z.runDays = make(map[string]time.Duration)
+	}
+	z.runTotal += addRunTimeByDay(z.runDays, from, to)
+
+	y, m, d := to.Local().Date()
+	cutoff := time.Date(y, m, d-runTimeDays+1, 0, 0, 0, 0, time.Local).Format(runTimeDateFormat)
+	for day := range z.runDays {
+		if day < cutoff {
+			delete(z.runDays, day)
+		}
+	}
+}
+
+// addRunTimeByDay adds the time between from and to onto the local day it
+// falls in, splitting it at midnight. Returns the total added.
+func addRunTimeByDay(byDay map[string]time.Duration, from, to time.Time) time.Duration {
+	var total time.Duration
+	from = from.Local()
+	for from.Before(to) {
+		y, m, d := from.Date()
+		end := time.Date(y, m, d+1, 0, 0, 0, 0, from.Location())
+		if to.Before(end) {
+			end = to
+		}
+		byDay[from.Format(runTimeDateFormat)] += end.Sub(from)
+		total += end.Sub(from)
+		from = end
+	}
+	return total
+}
+
+// runTimeData returns the run time to persist, including the time active so
+// far if the output is currently active. Returns nil if it's never been
+// active.
+func (z *Zone) runTimeData() *runTimeData {
+	now := timeNow()
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if z.runTotal == 0 && z.activeSince.IsZero() {
+		return nil
+	}
+	data := &runTimeData{TotalSeconds: z.runTotal.Seconds()}
+	if !z.activeSince.IsZero() {
+		data.TotalSeconds += now.Sub(z.activeSince).Seconds()
+	}
+	byDay := z.runDaysUntil(now)
+	if len(byDay) > 0 {
+		data.Days = make(map[string]float64, len(byDay))
+		for day, d := range byDay {
+			data.Days[day] = d.Seconds()
+		}
+	}
+	return data
+}
+
+// restoreRunTime replaces the run time recorded so far with the saved one.
+func (z *Zone) restoreRunTime(data runTimeData) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.runTotal = secondsDuration(data.TotalSeconds)
+	z.runDays = make(map[string]time.Duration, len(data.Days))
+	for day, seconds := range data.Days {
+		z.runDays[day] = secondsDuration(seconds)
+	}
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// saveZonesPeriodically saves all the zones periodically so that their run
+// time is persisted.
+func (c *Controller) saveZonesPeriodically() {
+	c.timersLock.Lock()
+	defer c.timersLock.Unlock()
+	if c.closed {
+		return
+	}
+	c.saveTimer = afterFunc(runTimeSaveInterval, func() {
+		for _, z := range c.Zones {
+			z.Save()
+		}
+		c.saveZonesPeriodically()
+	})
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/output"
+)
+
+var _ = Describe("Zone run time", func() {
+	var z *Zone
+
+	BeforeEach(func() {
+		var err error
+		DataDir, err = ioutil.TempDir("", "run_time_test")
+		Expect(err).NotTo(HaveOccurred())
+
+		clock.Set(fridayAt(9, 0, 0))
+		z = NewZone("one", output.Virtual("one"))
+	})
+
+	AfterEach(func() {
+		afterFunc = time.AfterFunc
+		os.RemoveAll(DataDir)
+	})
+
+	var activeBetween = func(from, to time.Time) {
+		clock.Set(from)
+		z.applyEvent(Event{Action: On})
+		clock.Set(to)
+		z.applyEvent(Event{Action: Off})
+	}
+
+	It("accumulates the time the output is active", func() {
+		activeBetween(fridayAt(9, 0, 0), fridayAt(10, 30, 0))
+		activeBetween(fridayAt(18, 0, 0), fridayAt(18, 15, 0))
+		Expect(z.RunTime()).To(Equal(105 * time.Minute))
+	})
+
+	It("includes the time active so far", func() {
+		activeBetween(fridayAt(9, 0, 0), fridayAt(10, 0, 0))
+		clock.Set(fridayAt(18, 0, 0))
+		z.applyEvent(Event{Action: On})
+		clock.Set(fridayAt(18, 20, 0))
+		Expect(z.RunTime()).To(Equal(80 * time.Minute))
+	})
+
+	It("doesn't count an event that leaves the output unchanged", func() {
+		clock.Set(fridayAt(9, 0, 0))
+		z.applyEvent(Event{Action: On})
+		clock.Set(fridayAt(9, 30, 0))
+		z.applyEvent(Event{Action: On})
+		clock.Set(fridayAt(10, 0, 0))
+		z.applyEvent(Event{Action: Off})
+		Expect(z.RunTime()).To(Equal(time.Hour))
+	})
+
+	Describe("daily run times", func() {
+		It("returns the time active each day, most recent first", func() {
+			activeBetween(fridayAt(9, 0, 0).AddDate(0, 0, -2), fridayAt(11, 0, 0).AddDate(0, 0, -2))
+			activeBetween(fridayAt(9, 0, 0), fridayAt(10, 30, 0))
+			clock.Set(fridayAt(12, 0, 0))
+
+			days := z.DailyRunTimes(3)
+			Expect(days).To(Equal([]DailyRunTime{
+				{Date: fridayAt(0, 0, 0), Duration: 90 * time.Minute},
+				{Date: fridayAt(0, 0, 0).AddDate(0, 0, -1), Duration: 0},
+				{Date: fridayAt(0, 0, 0).AddDate(0, 0, -2), Duration: 2 * time.Hour},
+			}))
+			Expect(days[0].String()).To(Equal("Fri 28 Sep: 1h30m"))
+		})
+
+		It("splits time active across midnight", func() {
+			clock.Set(fridayAt(23, 0, 0))
+			z.applyEvent(Event{Action: On})
+			clock.Set(fridayAt(1, 30, 0).AddDate(0, 0, 1))
+
+			days := z.DailyRunTimes(2)
+			Expect(days[0].Duration).To(Equal(90 * time.Minute))
+			Expect(days[1].Duration).To(Equal(time.Hour))
+		})
+
+		It("drops days older than those kept", func() {
+			activeBetween(fridayAt(9, 0, 0), fridayAt(10, 0, 0))
+			activeBetween(fridayAt(9, 0, 0).AddDate(0, 0, runTimeDays), fridayAt(10, 0, 0).AddDate(0, 0, runTimeDays))
+			Expect(z.runDays).To(HaveLen(1))
+			Expect(z.RunTime()).To(Equal(2 * time.Hour))
+		})
+	})
+
+	Describe("persistence", func() {
+		It("saves and restores the run time", func() {
+			activeBetween(fridayAt(9, 0, 0), fridayAt(10, 0, 0))
+			clock.Set(fridayAt(18, 0, 0))
+			z.applyEvent(Event{Action: On})
+			clock.Set(fridayAt(18, 30, 0))
+			Expect(z.Save()).To(Succeed())
+
+			data := readFile(filepath.Join(DataDir, "one.json"))
+			Expect(data).To(ContainSubstring(`"total_seconds": 5400`))
+			Expect(data).To(ContainSubstring(`"2018-09-28": 5400`))
+
+			z2 := NewZone("one", output.Virtual("one"))
+			Expect(z2.Restore()).To(Succeed())
+			Expect(z2.RunTime()).To(Equal(90 * time.Minute))
+			Expect(z2.DailyRunTimes(1)[0].Duration).To(Equal(90 * time.Minute))
+		})
+
+		It("saves the zones periodically once set up", func() {
+			var timerFuncs []func()
+			afterFunc = func(d time.Duration, f func()) *time.Timer {
+				if d == runTimeSaveInterval {
+					timerFuncs = append(timerFuncs, f)
+				}
+				return time.NewTimer(time.Hour)
+			}
+			cfg := config.New()
+			cfg.Zones["one"] = config.ZoneConfig{Virtual: true}
+			ctrl := New()
+			Expect(ctrl.Setup(cfg)).To(Succeed())
+			defer ctrl.Zones["one"].Scheduler.Stop()
+			Expect(timerFuncs).To(HaveLen(1))
+
+			ctrl.Zones["one"].Boost(0)
+			clock.Add(10 * time.Minute)
+			timerFuncs[0]()
+			Expect(readFile(filepath.Join(DataDir, "one.json"))).To(ContainSubstring(`"total_seconds": 600`))
+			Expect(timerFuncs).To(HaveLen(2))
+		})
+
+		It("stops saving the zones once closed", func() {
+			var timerFuncs []func()
+			afterFunc = func(d time.Duration, f func()) *time.Timer {
+				if d == runTimeSaveInterval {
+					timerFuncs = append(timerFuncs, f)
+				}
+				return time.NewTimer(time.Hour)
+			}
+			cfg := config.New()
+			cfg.Zones["one"] = config.ZoneConfig{Virtual: true}
+			ctrl := New()
+			Expect(ctrl.Setup(cfg)).To(Succeed())
+			Expect(timerFuncs).To(HaveLen(1))
+
+			ctrl.Close()
+			Expect(ctrl.Zones["one"].Scheduler.Running()).To(BeFalse())
+			timerFuncs[0]()
+			Expect(timerFuncs).To(HaveLen(1))
+		})
+	})
+})
@@ -2,4 +2,8 @@ package controller
 
 import "time"
 
-var timeNow = time.Now // variable indirection to facilitate testing
+// variable indirection to facilitate testing
+var (
+	timeNow   = time.Now
+	afterFunc = time.AfterFunc
+)
@@ -3,6 +3,7 @@ package controller
 import (
 	"log"
 	"sync"
+	"time"
 
 	"github.com/alext/heating-controller/output"
 	"github.com/alext/heating-controller/scheduler"
@@ -18,10 +19,50 @@ type Zone struct {
 	EventHandler
 
 	lock          sync.RWMutex
+	saveLock      sync.Mutex
 	out           output.Output
+	thermSource   sensor.Sensor
+	subs          []*sensor.Subscription
+	ownedSensors  []sensor.Sensor
 	schedDemand   bool
 	thermDemand   bool
 	currentDemand bool
+	away          bool
+	awayTarget    *units.Temperature
+	savedTarget   *units.Temperature
+
+	configHysteresis   thermostat.Hysteresis
+	hysteresisOverride *thermostat.Hysteresis
+
+	frostProtection  units.Temperature
+	frostDemand      bool
+	frostEngagements uint64
+
+	optimumStart bool
+	warmupRates  []WarmupRate
+	warmup       *warmup
+	preheating   *Event
+
+	optimumStop          bool
+	optimumStopMaxEarly  time.Duration
+	optimumStopTolerance units.Temperature
+	coolingRate          *CoolingRate
+	cooldown             *cooldown
+
+	staleSource       sensor.Sensor
+	staleMaxAge       time.Duration
+	stalePolicy       *StalePolicy
+	staleWatchedSince time.Time
+	sensorStale       bool
+	staleDemand       bool
+	staleTimer        *time.Timer
+	staleStopped      bool
+
+	history []ZoneSample
+
+	activeSince time.Time
+	runTotal    time.Duration
+	runDays     map[string]time.Duration
 }
 
 func NewZone(id string, out output.Output) *Zone {
@@ -29,6 +70,8 @@ func NewZone(id string, out output.Output) *Zone {
 		ID:          id,
 		out:         out,
 		thermDemand: true, // always on until a thermostat is added
+
+		configHysteresis: thermostat.DefaultHysteresis,
 	}
 	z.Scheduler = scheduler.New(z.ID)
 	z.EventHandler = NewEventHandler(z.Scheduler, z.applyEvent)
@@ -36,7 +79,168 @@ func NewZone(id string, out output.Output) *Zone {
 }
 
 func (z *Zone) SetupThermostat(source sensor.Sensor, initialTarget units.Temperature) {
-	z.Thermostat = thermostat.New(z.ID, source, initialTarget, z.thermostatDemand)
+	t := thermostat.New(z.ID, source, initialTarget, z.thermostatDemand)
+	// The thermostat may already be calling back with its initial demand.
+	z.lock.Lock()
+	z.thermSource = source
+	z.Thermostat = t
+	z.lock.Unlock()
+	z.recordHistory(source)
+}
+
+func (z *Zone) SetupTPIThermostat(source sensor.Sensor, initialTarget units.Temperature, settings thermostat.TPISettings) {
+	t := thermostat.NewTPI(z.ID, source, initialTarget, settings, z.thermostatDemand)
+	z.lock.Lock()
+	z.thermSource = source
+	z.Thermostat = t
+	z.updateThermostatIdle()
+	z.lock.Unlock()
+	z.recordHistory(source)
+}
+
+func (z *Zone) SetupPIDThermostat(source sensor.Sensor, initialTarget units.Temperature, settings thermostat.PIDSettings) {
+	t := thermostat.NewPID(z.ID, source, initialTarget, settings, z.thermostatDemand)
+	z.lock.Lock()
+	z.thermSource = source
+	z.Thermostat = t
+	z.updateThermostatIdle()
+	z.lock.Unlock()
+	z.recordHistory(source)
+}
+
+// setConfiguredHysteresis sets the hysteresis given in the config. It's used
+// unless the hysteresis has been set at runtime.
+func (z *Zone) setConfiguredHysteresis(h thermostat.Hysteresis) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.configHysteresis = h
+	if z.hysteresisOverride == nil {
+		z.Thermostat.SetHysteresis(h)
+	}
+}
+
+// SetThermostatHysteresis sets the thermostat hysteresis at runtime. This is
+// saved with the zone state, and takes precedence over the configured
+// hysteresis until ResetThermostatHysteresis is called.
+func (z *Zone) SetThermostatHysteresis(h thermostat.Hysteresis) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.hysteresisOverride = &h
+	z.Thermostat.SetHysteresis(h)
+}
+
+// ResetThermostatHysteresis discards any hysteresis set at runtime, reverting
+// to the configured hysteresis.
+func (z *Zone) ResetThermostatHysteresis() {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.hysteresisOverride = nil
+	z.Thermostat.SetHysteresis(z.configHysteresis)
+}
+
+// UsesHysteresis returns whether the zone has a thermostat that switches using
+// its hysteresis, rather than a duty cycle.
+func (z *Zone) UsesHysteresis() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if z.Thermostat == nil {
+		return false
+	}
+	_, ok := z.Thermostat.(thermostat.DutyCycler)
+	return !ok
+}
+
+// HysteresisOverridden returns whether the thermostat hysteresis has been set
+// at runtime.
+func (z *Zone) HysteresisOverridden() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.hysteresisOverride != nil
+}
+
+func (z *Zone) thermostatHysteresisOverride() *thermostat.Hysteresis {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if z.hysteresisOverride == nil {
+		return nil
+	}
+	h := *z.hysteresisOverride
+	return &h
+}
+
+// ThermostatInputs returns the readings of each of the sensors used by the
+// thermostat when it combines several, or nil otherwise.
+func (z *Zone) ThermostatInputs() []sensor.InputReading {
+	z.lock.RLock()
+	source := z.thermSource
+	z.lock.RUnlock()
+	if ms, ok := source.(sensor.MultiSensor); ok {
+		return ms.Inputs()
+	}
+	return nil
+}
+
+// How far above the frost protection temperature the zone needs to get before
+// frost protection disengages.
+const frostProtectionHysteresis = 500
+
+// SetupFrostProtection forces the zone on whenever the temperature from the
+// given sensor falls below min, regardless of any other demand.
+func (z *Zone) SetupFrostProtection(source sensor.Sensor, min units.Temperature) {
+	z.lock.Lock()
+	z.frostProtection = min
+	z.lock.Unlock()
+
+	if temp, updatedAt := source.Read(); !updatedAt.IsZero() {
+		z.temperatureUpdate(temp)
+	}
+	sub := z.subscribe(source)
+	go func() {
+		for temp := range sub.C {
+			z.temperatureUpdate(temp)
+		}
+	}()
+}
+
+// subscribe subscribes to the given sensor, keeping the subscription so that
+// it's cancelled when the zone is closed.
+func (z *Zone) subscribe(source sensor.Sensor) *sensor.Subscription {
+	sub := source.Subscribe()
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.subs = append(z.subs, sub)
+	return sub
+}
+
+// ownSensor makes the zone responsible for closing the given sensor, for
+// sensors that were created just for the zone.
+func (z *Zone) ownSensor(s sensor.Sensor) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	z.ownedSensors = append(z.ownedSensors, s)
+}
+
+// Close stops the zone following its sensors and schedule, and stops its
+// timers. The zone shouldn't be used afterwards.
+func (z *Zone) Close() {
+	z.lock.Lock()
+	subs := z.subs
+	z.subs = nil
+	owned := z.ownedSensors
+	z.ownedSensors = nil
+	t := z.Thermostat
+	z.lock.Unlock()
+	for _, sub := range subs {
+		sub.Cancel()
+	}
+	z.stopStaleFailsafe()
+	if t != nil {
+		t.Close()
+	}
+	for _, s := range owned {
+		s.Close()
+	}
+	z.Scheduler.Stop()
 }
 
 func (z *Zone) Active() bool {
@@ -57,11 +261,110 @@ func (z *Zone) TDemand() bool {
 	return z.thermDemand
 }
 
+// FrostProtection returns the frost protection temperature, or zero if frost
+// protection isn't configured.
+func (z *Zone) FrostProtection() units.Temperature {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.frostProtection
+}
+
+// FrostProtectionActive returns whether frost protection is forcing the zone
+// on. It's suspended while the sensor is stale.
+func (z *Zone) FrostProtectionActive() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.frostDemand && !z.sensorStale
+}
+
+// FrostProtectionEngagements returns the number of times frost protection has
+// engaged since startup.
+func (z *Zone) FrostProtectionEngagements() uint64 {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.frostEngagements
+}
+
+// Away returns whether the zone's schedule is currently suspended by away
+// mode.
+func (z *Zone) Away() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.away
+}
+
 func (z *Zone) applyEvent(e Event) {
+	if z.consumePreheated(e) {
+		log.Printf("[Zone:%s] event '%s' already applied by optimum start", z.ID, e)
+		return
+	}
+	z.doApplyEvent(e)
+}
+
+func (z *Zone) doApplyEvent(e Event) {
 	z.schedulerDemand(e.Action == On)
 	if e.ThermAction != nil && z.Thermostat != nil {
+		if z.holdingAwayTarget() {
+			log.Printf("[Zone:%s] ignoring thermostat action %s while away", z.ID, e.ThermAction)
+			return
+		}
 		e.ThermAction.Apply(z.Thermostat)
 	}
+	if e.Action == On {
+		z.cancelCooldown()
+		z.startWarmup()
+	} else {
+		z.cancelWarmup()
+		z.startCooldown()
+	}
+}
+
+func (z *Zone) holdingAwayTarget() bool {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	return z.awayTarget != nil
+}
+
+// setAway puts the zone into, or takes it out of away mode. While away the
+// scheduler demand is ignored, and if a target is given the zone's thermostat
+// holds that target instead. The original target is restored on return.
+func (z *Zone) setAway(away bool, target *units.Temperature) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if away != z.away {
+		log.Printf("[Zone:%s] away mode : %t", z.ID, away)
+	}
+	z.away = away
+	z.awayTarget = nil
+	if away {
+		z.warmup = nil
+		z.cooldown = nil
+	}
+	if z.Thermostat != nil {
+		if away && target != nil {
+			if z.savedTarget == nil {
+				t := z.Thermostat.Target()
+				z.savedTarget = &t
+			}
+			z.awayTarget = target
+			z.Thermostat.Set(*target)
+		} else if z.savedTarget != nil {
+			z.Thermostat.Set(*z.savedTarget)
+			z.savedTarget = nil
+		}
+	}
+	z.updateDemand()
+}
+
+// thermostatTarget returns the thermostat target ignoring any away mode
+// target. Must only be called for a zone with a thermostat.
+func (z *Zone) thermostatTarget() units.Temperature {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	if z.savedTarget != nil {
+		return *z.savedTarget
+	}
+	return z.Thermostat.Target()
 }
 
 func (z *Zone) schedulerDemand(demand bool) {
@@ -80,9 +383,50 @@ func (z *Zone) thermostatDemand(demand bool) {
 	z.updateDemand()
 }
 
+func (z *Zone) temperatureUpdate(temp units.Temperature) {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+	if !z.frostDemand && temp < z.frostProtection {
+		log.Printf("[Zone:%s] Frost protection engaged, temperature %s below %s", z.ID, temp, z.frostProtection)
+		z.frostDemand = true
+		z.frostEngagements++
+	} else if z.frostDemand && temp >= z.frostProtection+frostProtectionHysteresis {
+		log.Printf("[Zone:%s] Frost protection disengaged, temperature %s", z.ID, temp)
+		z.frostDemand = false
+	}
+	z.updateDemand()
+}
+
+// updateThermostatIdle tells the thermostat whether the schedule (or away
+// target) currently calls for heat, so that it doesn't accumulate error while
+// the output can't come on.
+//
+// Must be called with the lock held.
+func (z *Zone) updateThermostatIdle() {
+	if idler, ok := z.Thermostat.(thermostat.Idler); ok {
+		wanted := z.schedDemand
+		if z.away {
+			wanted = z.awayTarget != nil
+		}
+		idler.SetIdle(!wanted)
+	}
+}
+
 // Must be called with the lock held for writing.
 func (z *Zone) updateDemand() {
-	targetDemand := z.schedDemand && z.thermDemand
+	z.updateThermostatIdle()
+	thermDemand := z.thermDemand
+	if z.sensorStale {
+		thermDemand = z.staleDemand
+	}
+	targetDemand := z.schedDemand && thermDemand
+	if z.away {
+		// Only heat to hold the away target (if any).
+		targetDemand = z.awayTarget != nil && thermDemand
+	}
+	if z.frostDemand && !z.sensorStale {
+		targetDemand = true
+	}
 	if targetDemand == z.currentDemand {
 		// No change needed
 		return
@@ -100,4 +444,12 @@ func (z *Zone) updateDemand() {
 		log.Printf("[Zone:%s] Output error: %v", z.ID, err)
 	}
 	z.currentDemand = targetDemand
+	now := timeNow()
+	if targetDemand {
+		z.activeSince = now
+	} else if !z.activeSince.IsZero() {
+		z.recordRunTime(z.activeSince, now)
+		z.activeSince = time.Time{}
+	}
+	z.addSample(z.sample(), true)
 }
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/units"
+)
+
+const (
+	// How long a zone's history is kept for.
+	zoneHistoryPeriod = 24 * time.Hour
+	// The shortest interval between history samples taken from sensor
+	// readings, unless the target has changed since the last one. Changes to
+	// the output are always recorded immediately.
+	zoneHistoryInterval = time.Minute
+)
+
+// ZoneSample is the state of a zone at a point in time. Current and Target are
+// zero for a zone without a thermostat.
+type ZoneSample struct {
+	At      time.Time         `json:"at"`
+	Current units.Temperature `json:"current"`
+	Target  units.Temperature `json:"target"`
+	Active  bool              `json:"active"`
+	// NoReading is set, and Current left zero, when the thermostat's sensor
+	// had no reading yet.
+	NoReading bool `json:"no_reading,omitempty"`
+}
+
+// History returns the samples recorded since the given time, oldest first,
+// preceded by the latest one before it (if any) which gives the zone's state
+// at that time.
+func (z *Zone) History(since time.Time) []ZoneSample {
+	z.lock.RLock()
+	defer z.lock.RUnlock()
+	i := 0
+	for i < len(z.history)-1 && !z.history[i+1].At.After(since) {
+		i++
+	}
+	return append([]ZoneSample(nil), z.history[i:]...)
+}
+
+// recordHistory samples the zone's state whenever the given sensor has a new
+// reading.
+func (z *Zone) recordHistory(source sensor.Sensor) {
+	if _, updatedAt := source.Read(); !updatedAt.IsZero() {
+		z.lock.Lock()
+		z.addSample(z.sample(), false)
+		z.lock.Unlock()
+	}
+	sub := z.subscribe(source)
+	go func() {
+		for temp := range sub.C {
+			z.lock.Lock()
+			s := z.sample()
+			// The thermostat may not have seen this reading yet.
+			s.Current, s.NoReading = temp, false
+			z.addSample(s, false)
+			z.lock.Unlock()
+		}
+	}()
+}
+
+// sample returns the zone's current state.
+//
+// Must be called with the lock held.
+func (z *Zone) sample() ZoneSample {
+	s := ZoneSample{At: timeNow(), Active: z.currentDemand}
+	if z.Thermostat != nil {
+		s.Current = z.Thermostat.Current()
+		s.Target = z.Thermostat.Target()
+		if z.thermSource != nil {
+			if _, updatedAt := z.thermSource.Read(); updatedAt.IsZero() {
+				s.Current, s.NoReading = 0, true
+			}
+		}
+	}
+	return s
+}
+
+// addSample adds the sample to the history, unless force is false and it's
+// too soon after the last one.
+//
+// Must be called with the lock held for writing.
+func (z *Zone) addSample(s ZoneSample, force bool) {
+	if n := len(z.history); n > 0 && !force {
+		last := z.history[n-1]
+		if s.Target == last.Target && s.At.Sub(last.At) < zoneHistoryInterval {
+			return
+		}
+	}
+	z.history = append(z.history, s)
+
+	// Drop the samples that are no longer needed to give the state at the
+	// start of the period.
+	cutoff := s.At.Add(-zoneHistoryPeriod)
+	i := 0
+	for i < len(z.history)-1 && !z.history[i+1].At.After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		z.history = append(z.history[:0], z.history[i:]...)
+	}
+}
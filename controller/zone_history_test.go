@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("Zone history", func() {
+	var (
+		z     *Zone
+		s     sensor.SettableSensor
+		start time.Time
+	)
+
+	BeforeEach(func() {
+		start = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		clock.Set(start)
+
+		z = NewZone("one", output.Virtual("one"))
+		s = sensor.NewPushSensor("foo", "1234")
+		s.Set(19000, clock.Now())
+		z.SetupThermostat(s, 20000)
+	})
+
+	// setAt sets the sensor at the given offset from start, and waits for the
+	// zone to see it.
+	var setAt = func(d time.Duration, temp units.Temperature) {
+		clock.Set(start.Add(d))
+		s.Set(temp, clock.Now())
+		Eventually(func() units.Temperature {
+			return z.Thermostat.Current()
+		}).Should(Equal(temp))
+	}
+
+	It("records the initial state", func() {
+		Expect(z.History(start.Add(-time.Hour))).To(Equal([]ZoneSample{
+			{At: start, Current: 19000, Target: 20000},
+		}))
+	})
+
+	It("marks samples taken before the sensor has a reading", func() {
+		s = sensor.NewPushSensor("bar", "2345")
+		z = NewZone("two", output.Virtual("two"))
+		z.SetupThermostat(s, 20000)
+		sample := func() ZoneSample {
+			z.lock.Lock()
+			defer z.lock.Unlock()
+			return z.sample()
+		}
+		Expect(sample()).To(Equal(ZoneSample{At: start, Target: 20000, NoReading: true}))
+
+		setAt(time.Minute, 19000)
+		Expect(sample()).To(Equal(ZoneSample{At: start.Add(time.Minute), Current: 19000, Target: 20000}))
+	})
+
+	It("records a sample for readings at most once a minute", func() {
+		setAt(30*time.Second, 19100)
+		setAt(time.Minute, 19200)
+		Eventually(func() []ZoneSample { return z.History(start) }).Should(HaveLen(2))
+		Consistently(func() []ZoneSample { return z.History(start) }).Should(Equal([]ZoneSample{
+			{At: start, Current: 19000, Target: 20000},
+			{At: start.Add(time.Minute), Current: 19200, Target: 20000},
+		}))
+	})
+
+	It("records the target changing, and the output switching", func() {
+		z.Thermostat.Set(21000)
+		setAt(10*time.Second, 19100)
+		Eventually(func() []ZoneSample { return z.History(start) }).Should(HaveLen(2))
+
+		clock.Set(start.Add(20 * time.Second))
+		z.applyEvent(Event{Action: On})
+		Eventually(z.Active).Should(BeTrue())
+		Expect(z.History(start)).To(Equal([]ZoneSample{
+			{At: start, Current: 19000, Target: 20000},
+			{At: start.Add(10 * time.Second), Current: 19100, Target: 21000},
+			{At: start.Add(20 * time.Second), Current: 19100, Target: 21000, Active: true},
+		}))
+	})
+
+	It("returns the samples since the given time, preceded by the one in effect then", func() {
+		setAt(time.Minute, 19100)
+		setAt(2*time.Minute, 19200)
+		setAt(3*time.Minute, 19300)
+		Eventually(func() []ZoneSample { return z.History(start) }).Should(HaveLen(4))
+
+		samples := z.History(start.Add(90 * time.Second))
+		Expect(samples).To(HaveLen(3))
+		Expect(samples[0].At).To(Equal(start.Add(time.Minute)))
+	})
+
+	It("drops samples older than the history period", func() {
+		setAt(time.Hour, 19100)
+		setAt(25*time.Hour, 19200)
+		setAt(26*time.Hour, 19300)
+		Eventually(func() []ZoneSample { return z.History(start.Add(-time.Hour)) }).Should(HaveLen(3))
+
+		// The sample from 1 hour in is kept as it gives the state at the
+		// start of the period.
+		Expect(z.History(start.Add(-time.Hour))[0].At).To(Equal(start.Add(time.Hour)))
+	})
+})
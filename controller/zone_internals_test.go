@@ -7,6 +7,7 @@ import (
 
 	"github.com/alext/heating-controller/output"
 	"github.com/alext/heating-controller/thermostat/thermostatfakes"
+	"github.com/alext/heating-controller/units"
 )
 
 var _ = Describe("Zone demand handling", func() {
@@ -214,4 +215,176 @@ var _ = Describe("Zone demand handling", func() {
 			initialOutputState: true, expectedOutputState: true,
 		}),
 	)
+
+	Describe("away mode", func() {
+		var (
+			out   output.Output
+			therm *thermostatfakes.FakeThermostat
+			z     *Zone
+		)
+
+		BeforeEach(func() {
+			out = output.Virtual("something")
+			therm = &thermostatfakes.FakeThermostat{}
+			therm.TargetReturns(19000)
+			z = NewZone("something", out)
+			z.Thermostat = therm
+			z.thermDemand = true
+			z.schedulerDemand(true)
+		})
+
+		It("suspends the scheduler demand while away", func() {
+			z.setAway(true, nil)
+			Expect(z.Away()).To(BeTrue())
+			Expect(out.Active()).To(BeFalse())
+
+			z.applyEvent(Event{Action: On})
+			Expect(out.Active()).To(BeFalse())
+		})
+
+		It("restores the scheduler demand on return", func() {
+			z.setAway(true, nil)
+			z.applyEvent(Event{Action: Off})
+			z.applyEvent(Event{Action: On})
+
+			z.setAway(false, nil)
+			Expect(z.Away()).To(BeFalse())
+			Expect(out.Active()).To(BeTrue())
+		})
+
+		Context("with an away target", func() {
+			var target units.Temperature = 7000
+
+			It("holds the target, activating on thermostat demand", func() {
+				z.setAway(true, &target)
+				Expect(therm.SetCallCount()).To(Equal(1))
+				Expect(therm.SetArgsForCall(0)).To(Equal(target))
+				Expect(out.Active()).To(BeTrue())
+
+				z.thermostatDemand(false)
+				Expect(out.Active()).To(BeFalse())
+			})
+
+			It("does not hold a target for a zone without a thermostat", func() {
+				z.Thermostat = nil
+				z.setAway(true, &target)
+				Expect(out.Active()).To(BeFalse())
+			})
+
+			It("ignores thermostat actions from events while away", func() {
+				z.setAway(true, &target)
+				z.applyEvent(Event{Action: On, ThermAction: &ThermostatAction{Action: SetTarget, Param: 21000}})
+				Expect(therm.SetCallCount()).To(Equal(1))
+			})
+
+			It("restores the original target on return", func() {
+				z.setAway(true, &target)
+				z.setAway(false, nil)
+				Expect(therm.SetCallCount()).To(Equal(2))
+				Expect(therm.SetArgsForCall(1)).To(Equal(units.Temperature(19000)))
+			})
+
+			It("reports the original target for persisting", func() {
+				z.setAway(true, &target)
+				therm.TargetReturns(target)
+				Expect(z.thermostatTarget()).To(Equal(units.Temperature(19000)))
+			})
+		})
+	})
+
+	Describe("idling the thermostat", func() {
+		var (
+			therm *idlingThermostat
+			z     *Zone
+		)
+
+		BeforeEach(func() {
+			therm = &idlingThermostat{FakeThermostat: &thermostatfakes.FakeThermostat{}}
+			z = NewZone("something", output.Virtual("something"))
+			z.Thermostat = therm
+		})
+
+		It("idles the thermostat while there's no scheduler demand", func() {
+			z.schedulerDemand(true)
+			Expect(therm.idle).To(BeFalse())
+			z.schedulerDemand(false)
+			Expect(therm.idle).To(BeTrue())
+		})
+
+		It("idles the thermostat while away, unless holding an away target", func() {
+			z.schedulerDemand(true)
+			z.setAway(true, nil)
+			Expect(therm.idle).To(BeTrue())
+
+			target := units.Temperature(7000)
+			z.setAway(true, &target)
+			Expect(therm.idle).To(BeFalse())
+		})
+	})
+
+	Describe("frost protection", func() {
+		var (
+			out output.Output
+			z   *Zone
+		)
+
+		BeforeEach(func() {
+			out = output.Virtual("something")
+			z = NewZone("something", out)
+			z.frostProtection = 5000
+		})
+
+		It("activates the output when the temperature falls below the frost protection temperature", func() {
+			z.temperatureUpdate(4900)
+			Expect(z.FrostProtectionActive()).To(BeTrue())
+			Expect(out.Active()).To(BeTrue())
+		})
+
+		It("does nothing while above the frost protection temperature", func() {
+			z.temperatureUpdate(5000)
+			Expect(z.FrostProtectionActive()).To(BeFalse())
+			Expect(out.Active()).To(BeFalse())
+		})
+
+		It("overrides the thermostat demand and away mode", func() {
+			z.thermDemand = false
+			z.setAway(true, nil)
+			z.temperatureUpdate(3000)
+			Expect(out.Active()).To(BeTrue())
+		})
+
+		It("disengages once the temperature has risen above the hysteresis band", func() {
+			z.temperatureUpdate(4900)
+			z.temperatureUpdate(5400)
+			Expect(z.FrostProtectionActive()).To(BeTrue())
+
+			z.temperatureUpdate(5500)
+			Expect(z.FrostProtectionActive()).To(BeFalse())
+			Expect(out.Active()).To(BeFalse())
+		})
+
+		It("leaves the output active if there is other demand on disengaging", func() {
+			z.temperatureUpdate(4900)
+			z.schedulerDemand(true)
+			z.temperatureUpdate(6000)
+			Expect(out.Active()).To(BeTrue())
+		})
+
+		It("counts the number of times it has engaged", func() {
+			z.temperatureUpdate(4900)
+			z.temperatureUpdate(4800)
+			z.temperatureUpdate(6000)
+			z.temperatureUpdate(4900)
+			Expect(z.FrostProtectionEngagements()).To(BeEquivalentTo(2))
+		})
+	})
 })
+
+type idlingThermostat struct {
+	*thermostatfakes.FakeThermostat
+	idle bool
+}
+
+func (t *idlingThermostat) SetIdle(idle bool) {
+	t.idle = idle
+}
@@ -1,11 +1,14 @@
 package controller_test
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/alext/heating-controller/controller"
 	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/sensor"
 )
 
 var _ = Describe("A heating zone", func() {
@@ -29,4 +32,56 @@ var _ = Describe("A heating zone", func() {
 		})
 	})
 
+	Describe("setting up frost protection", func() {
+		var (
+			out  output.Output
+			sens sensor.SettableSensor
+			z    *controller.Zone
+		)
+
+		BeforeEach(func() {
+			out = output.Virtual("anything")
+			sens = sensor.NewPushSensor("foo", "1234")
+			z = controller.NewZone("foo", out)
+		})
+
+		It("should engage frost protection based on the current temperature", func() {
+			sens.Set(3000, time.Now())
+			z.SetupFrostProtection(sens, 5000)
+			Expect(z.FrostProtection()).To(BeEquivalentTo(5000))
+			Expect(z.FrostProtectionActive()).To(BeTrue())
+			Expect(out.Active()).To(BeTrue())
+		})
+
+		It("should engage frost protection when the temperature changes", func() {
+			sens.Set(10000, time.Now())
+			z.SetupFrostProtection(sens, 5000)
+			Expect(z.FrostProtectionActive()).To(BeFalse())
+
+			sens.Set(4500, time.Now())
+			Eventually(z.FrostProtectionActive).Should(BeTrue())
+			Expect(out.Active()).To(BeTrue())
+		})
+	})
+
+	Describe("closing a zone", func() {
+		It("should stop following its sensors", func() {
+			out := output.Virtual("anything")
+			sens := sensor.NewPushSensor("foo", "1234")
+			sens.Set(10000, time.Now())
+			z := controller.NewZone("foo", out)
+			z.SetupThermostat(sens, 19000)
+			z.SetupFrostProtection(sens, 5000)
+			z.SetupOptimumStart(sens)
+			z.Scheduler.Start()
+
+			z.Close()
+			Expect(z.Scheduler.Running()).To(BeFalse())
+
+			sens.Set(4500, time.Now())
+			Consistently(z.FrostProtectionActive).Should(BeFalse())
+			Expect(z.Thermostat.Current()).To(BeEquivalentTo(10000))
+			Expect(out.Active()).To(BeFalse())
+		})
+	})
 })
@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/alext/gpio v0.0.0-20170217131543-a971ac03fc91
+	github.com/eclipse/paho.mqtt.golang v1.2.0
 	github.com/gorilla/mux v1.8.0
 	github.com/onsi/ginkgo v1.16.5
 	github.com/onsi/gomega v1.10.1
@@ -4,6 +4,10 @@ import (
 	"log"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/thermostat"
 )
 
 func newDensorDesc() *prometheus.Desc {
@@ -14,6 +18,42 @@ func newDensorDesc() *prometheus.Desc {
 		nil,
 	)
 }
+func newHumidityDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "", "relative_humidity_percent"),
+		"Current relative humidity in percent",
+		[]string{"name"},
+		nil,
+	)
+}
+
+func newPressureDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "", "pressure_hpa"),
+		"Current atmospheric pressure in hectopascals",
+		[]string{"name"},
+		nil,
+	)
+}
+
+func newRejectedReadingsDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "", "sensor_rejected_readings_total"),
+		"Number of readings rejected by a sensor's filter",
+		[]string{"name", "reason"},
+		nil,
+	)
+}
+
+func newReadErrorsDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "", "sensor_read_errors_total"),
+		"Number of failed attempts to read a sensor",
+		[]string{"name", "reason"},
+		nil,
+	)
+}
+
 func newZoneDesc() *prometheus.Desc {
 	return prometheus.NewDesc(
 		prometheus.BuildFQName("house", "heating", "zone_active"),
@@ -23,9 +63,62 @@ func newZoneDesc() *prometheus.Desc {
 	)
 }
 
+func newZoneActiveSecondsDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "heating", "zone_active_seconds_total"),
+		"Total time the heating zone has been active",
+		[]string{"name"},
+		nil,
+	)
+}
+
+func newFrostActiveDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "heating", "zone_frost_protection_active"),
+		"Heating zone frost protection active state - 1 or 0",
+		[]string{"name"},
+		nil,
+	)
+}
+func newFrostEngagementsDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "heating", "zone_frost_protection_engagements_total"),
+		"Number of times heating zone frost protection has engaged",
+		[]string{"name"},
+		nil,
+	)
+}
+
+func newSensorStaleDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "heating", "zone_sensor_stale"),
+		"Heating zone thermostat sensor stale state - 1 or 0",
+		[]string{"name"},
+		nil,
+	)
+}
+
+func newPIDTermDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("house", "heating", "zone_pid_term"),
+		"Heating zone PID thermostat term contributions to the duty cycle",
+		[]string{"name", "term"},
+		nil,
+	)
+}
+
 func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
 	ch <- m.sensorDesc
+	ch <- m.humidityDesc
+	ch <- m.pressureDesc
+	ch <- m.rejectedReadingsDesc
+	ch <- m.readErrorsDesc
 	ch <- m.zoneDesc
+	ch <- m.zoneActiveSecondsDesc
+	ch <- m.frostActiveDesc
+	ch <- m.frostEngagementsDesc
+	ch <- m.pidTermDesc
+	ch <- m.sensorStaleDesc
 }
 
 func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
@@ -34,7 +127,17 @@ func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
 }
 
 func (m *Metrics) collectSensors(ch chan<- prometheus.Metric) {
-	for name, s := range m.ctrl.SensorsByName {
+	for name, s := range m.ctrl.Sensors() {
+		if fs, ok := s.(sensor.FilteredSensor); ok && fs.Filter() != nil {
+			m.collectRejectedReadings(ch, name, fs.RejectedReadings())
+		}
+		if es, ok := s.(sensor.ErrorCountingSensor); ok {
+			m.collectReadErrors(ch, name, es.ReadErrors())
+		}
+		if qs, ok := s.(sensor.QuantitySensor); ok {
+			m.collectQuantities(ch, name, qs)
+		}
+
 		temp, ts := s.Read()
 		if ts.IsZero() {
 			// sensor hasn't had a reading, so it returning initial values
@@ -51,6 +154,55 @@ func (m *Metrics) collectSensors(ch chan<- prometheus.Metric) {
 	}
 }
 
+func (m *Metrics) collectQuantities(ch chan<- prometheus.Metric, name string, s sensor.QuantitySensor) {
+	q, ts := s.ReadQuantities()
+	values := make(map[*prometheus.Desc]float64)
+	if q.Humidity != nil {
+		values[m.humidityDesc] = q.Humidity.Float()
+	}
+	if q.Pressure != nil {
+		values[m.pressureDesc] = q.Pressure.Float()
+	}
+	for desc, val := range values {
+		metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, val, name)
+		if err != nil {
+			log.Printf("[metrics] Error constructing quantity metric for %s: %s", name, err.Error())
+			continue
+		}
+		ch <- prometheus.NewMetricWithTimestamp(ts, metric)
+	}
+}
+
+func (m *Metrics) collectRejectedReadings(ch chan<- prometheus.Metric, name string, r sensor.RejectedReadings) {
+	counts := map[string]uint64{
+		"bad_value":      r.BadValue,
+		"rate_of_change": r.RateOfChange,
+	}
+	for reason, count := range counts {
+		metric, err := prometheus.NewConstMetric(m.rejectedReadingsDesc, prometheus.CounterValue, float64(count), name, reason)
+		if err != nil {
+			log.Printf("[metrics] Error constructing rejected readings metric for %s: %s", name, err.Error())
+			continue
+		}
+		ch <- metric
+	}
+}
+
+func (m *Metrics) collectReadErrors(ch chan<- prometheus.Metric, name string, e sensor.ReadErrors) {
+	counts := map[string]uint64{
+		"crc":  e.CRCFailures,
+		"read": e.ReadFailures,
+	}
+	for reason, count := range counts {
+		metric, err := prometheus.NewConstMetric(m.readErrorsDesc, prometheus.CounterValue, float64(count), name, reason)
+		if err != nil {
+			log.Printf("[metrics] Error constructing read errors metric for %s: %s", name, err.Error())
+			continue
+		}
+		ch <- metric
+	}
+}
+
 func (m *Metrics) collectZones(ch chan<- prometheus.Metric) {
 	for _, z := range m.ctrl.Zones {
 		var val float64 = 0
@@ -63,5 +215,72 @@ func (m *Metrics) collectZones(ch chan<- prometheus.Metric) {
 			continue
 		}
 		ch <- metric
+
+		metric, err = prometheus.NewConstMetric(m.zoneActiveSecondsDesc, prometheus.CounterValue, z.RunTime().Seconds(), z.ID)
+		if err != nil {
+			log.Printf("[metrics] Error constructing zone run time metric for %s: %s", z.ID, err.Error())
+			continue
+		}
+		ch <- metric
+
+		if z.FrostProtection() != 0 {
+			m.collectFrostProtection(ch, z)
+		}
+		if z.StalePolicy() != nil {
+			m.collectSensorStale(ch, z)
+		}
+		if t, ok := z.Thermostat.(thermostat.PIDThermostat); ok {
+			m.collectPIDTerms(ch, z.ID, t.PIDTerms())
+		}
+	}
+}
+
+func (m *Metrics) collectFrostProtection(ch chan<- prometheus.Metric, z *controller.Zone) {
+	var val float64 = 0
+	if z.FrostProtectionActive() {
+		val = 1
+	}
+	metric, err := prometheus.NewConstMetric(m.frostActiveDesc, prometheus.GaugeValue, val, z.ID)
+	if err != nil {
+		log.Printf("[metrics] Error constructing frost protection metric for %s: %s", z.ID, err.Error())
+		return
+	}
+	ch <- metric
+
+	metric, err = prometheus.NewConstMetric(m.frostEngagementsDesc, prometheus.CounterValue, float64(z.FrostProtectionEngagements()), z.ID)
+	if err != nil {
+		log.Printf("[metrics] Error constructing frost protection metric for %s: %s", z.ID, err.Error())
+		return
+	}
+	ch <- metric
+}
+
+func (m *Metrics) collectSensorStale(ch chan<- prometheus.Metric, z *controller.Zone) {
+	var val float64 = 0
+	if z.SensorStale() {
+		val = 1
+	}
+	metric, err := prometheus.NewConstMetric(m.sensorStaleDesc, prometheus.GaugeValue, val, z.ID)
+	if err != nil {
+		log.Printf("[metrics] Error constructing sensor stale metric for %s: %s", z.ID, err.Error())
+		return
+	}
+	ch <- metric
+}
+
+func (m *Metrics) collectPIDTerms(ch chan<- prometheus.Metric, name string, terms thermostat.PIDTerms) {
+	values := map[string]float64{
+		"p":      terms.P,
+		"i":      terms.I,
+		"d":      terms.D,
+		"output": terms.Output,
+	}
+	for term, val := range values {
+		metric, err := prometheus.NewConstMetric(m.pidTermDesc, prometheus.GaugeValue, val, name, term)
+		if err != nil {
+			log.Printf("[metrics] Error constructing PID term metric for %s: %s", name, err.Error())
+			continue
+		}
+		ch <- metric
 	}
 }
@@ -10,12 +10,22 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/alext/heating-controller/config"
 	"github.com/alext/heating-controller/controller"
 	"github.com/alext/heating-controller/metrics"
 	"github.com/alext/heating-controller/output"
 	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/thermostat"
+	"github.com/alext/heating-controller/units"
 )
 
+type erroringSensor struct {
+	sensor.SettableSensor
+	errors sensor.ReadErrors
+}
+
+func (s *erroringSensor) ReadErrors() sensor.ReadErrors { return s.errors }
+
 var _ = Describe("The custom collector", func() {
 	var (
 		ctrl    *controller.Controller
@@ -64,6 +74,49 @@ var _ = Describe("The custom collector", func() {
 			Expect(lines).To(ContainElement("# TYPE house_temperature_celcius gauge"))
 			Expect(lines).NotTo(ContainElement(ContainSubstring(`name="two"`)))
 		})
+
+		It("exposes the rejected reading counts for sensors with a filter", func() {
+			cfg := config.SensorConfig{Type: "push", ID: "1234", Filter: &config.FilterConfig{RejectValues: []units.Temperature{85000}}}
+			s1, err := sensor.New("one", cfg, nil)
+			Expect(err).NotTo(HaveOccurred())
+			ctrl.AddSensor("one", s1)
+			ctrl.AddSensor("two", sensor.NewPushSensor("two", "2345"))
+			s1.(sensor.SettableSensor).Set(85000, time.Now())
+
+			lines := getMetricsLines(handler)
+			Expect(lines).To(ContainElement("# TYPE house_sensor_rejected_readings_total counter"))
+			Expect(lines).To(ContainElement(`house_sensor_rejected_readings_total{name="one",reason="bad_value"} 1`))
+			Expect(lines).To(ContainElement(`house_sensor_rejected_readings_total{name="one",reason="rate_of_change"} 0`))
+			Expect(lines).NotTo(ContainElement(ContainSubstring(`house_sensor_rejected_readings_total{name="two"`)))
+		})
+
+		It("exposes the read error counts for sensors that record them", func() {
+			ctrl.AddSensor("one", &erroringSensor{
+				SettableSensor: sensor.NewPushSensor("one", "1234"),
+				errors:         sensor.ReadErrors{CRCFailures: 3, ReadFailures: 1},
+			})
+
+			lines := getMetricsLines(handler)
+			Expect(lines).To(ContainElement("# TYPE house_sensor_read_errors_total counter"))
+			Expect(lines).To(ContainElement(`house_sensor_read_errors_total{name="one",reason="crc"} 3`))
+			Expect(lines).To(ContainElement(`house_sensor_read_errors_total{name="one",reason="read"} 1`))
+		})
+
+		It("exposes the humidity and pressure of sensors that report them", func() {
+			s1 := sensor.NewPushSensor("one", "1234")
+			ctrl.AddSensor("one", s1)
+			ctrl.AddSensor("two", sensor.NewPushSensor("two", "2345"))
+			h, p := units.Humidity(48500), units.Pressure(101325)
+			t1 := time.Now().Add(-40 * time.Second)
+			s1.(sensor.SettableQuantitySensor).SetQuantities(sensor.Quantities{Humidity: &h, Pressure: &p}, t1)
+
+			lines := getMetricsLines(handler)
+			Expect(lines).To(ContainElement("# TYPE house_relative_humidity_percent gauge"))
+			Expect(lines).To(ContainElement(fmt.Sprintf(`house_relative_humidity_percent{name="one"} 48.5 %d`, timeMS(t1))))
+			Expect(lines).To(ContainElement("# TYPE house_pressure_hpa gauge"))
+			Expect(lines).To(ContainElement(fmt.Sprintf(`house_pressure_hpa{name="one"} 1013.25 %d`, timeMS(t1))))
+			Expect(lines).NotTo(ContainElement(ContainSubstring(`house_relative_humidity_percent{name="two"}`)))
+		})
 	})
 
 	Describe("exposing zones", func() {
@@ -92,5 +145,72 @@ var _ = Describe("The custom collector", func() {
 			Expect(lines).To(ContainElement(`house_heating_zone_active{name="one"} 1`))
 			Expect(lines).To(ContainElement(`house_heating_zone_active{name="two"} 0`))
 		})
+
+		It("exposes the time zones have been active", func() {
+			z1 := controller.NewZone("one", output.Virtual("one"))
+			z1.Scheduler.Start()
+			z2 := controller.NewZone("two", output.Virtual("two"))
+			z2.Scheduler.Start()
+			ctrl.AddZone(z1)
+			ctrl.AddZone(z2)
+			z1.Boost(time.Hour)
+			time.Sleep(10 * time.Millisecond)
+
+			lines := getMetricsLines(handler)
+			Expect(lines).To(ContainElement("# TYPE house_heating_zone_active_seconds_total counter"))
+			Expect(lines).To(ContainElement(MatchRegexp(`^house_heating_zone_active_seconds_total{name="one"} 0\.0[1-9]`)))
+			Expect(lines).To(ContainElement(`house_heating_zone_active_seconds_total{name="two"} 0`))
+		})
+
+		It("exposes frost protection state for zones with it configured", func() {
+			s := sensor.NewPushSensor("one", "1234")
+			s.Set(3000, time.Now())
+			z1 := controller.NewZone("one", output.Virtual("one"))
+			z1.SetupFrostProtection(s, 5000)
+			z2 := controller.NewZone("two", output.Virtual("two"))
+			ctrl.AddZone(z1)
+			ctrl.AddZone(z2)
+
+			lines := getMetricsLines(handler)
+			Expect(lines).To(ContainElement("# TYPE house_heating_zone_frost_protection_active gauge"))
+			Expect(lines).To(ContainElement(`house_heating_zone_frost_protection_active{name="one"} 1`))
+			Expect(lines).To(ContainElement("# TYPE house_heating_zone_frost_protection_engagements_total counter"))
+			Expect(lines).To(ContainElement(`house_heating_zone_frost_protection_engagements_total{name="one"} 1`))
+			Expect(lines).NotTo(ContainElement(ContainSubstring(`frost_protection_active{name="two"}`)))
+		})
+
+		It("exposes the sensor stale state for zones watching for it", func() {
+			s := sensor.NewPushSensor("one", "1234")
+			s.Set(19000, time.Now().Add(-time.Hour))
+			z1 := controller.NewZone("one", output.Virtual("one"))
+			Expect(z1.SetupStaleFailsafe(s, 10*time.Minute, controller.DefaultStalePolicy)).To(Succeed())
+			z2 := controller.NewZone("two", output.Virtual("two"))
+			ctrl.AddZone(z1)
+			ctrl.AddZone(z2)
+
+			lines := getMetricsLines(handler)
+			Expect(lines).To(ContainElement("# TYPE house_heating_zone_sensor_stale gauge"))
+			Expect(lines).To(ContainElement(`house_heating_zone_sensor_stale{name="one"} 1`))
+			Expect(lines).NotTo(ContainElement(ContainSubstring(`sensor_stale{name="two"}`)))
+		})
+
+		It("exposes the PID terms for zones with a PID thermostat", func() {
+			s := sensor.NewPushSensor("one", "1234")
+			s.Set(19000, time.Now())
+			z1 := controller.NewZone("one", output.Virtual("one"))
+			z1.SetupPIDThermostat(s, 20000, thermostat.PIDSettings{CycleLength: time.Hour, Kp: 0.5})
+			defer z1.Thermostat.Close()
+			z2 := controller.NewZone("two", output.Virtual("two"))
+			ctrl.AddZone(z1)
+			ctrl.AddZone(z2)
+
+			lines := getMetricsLines(handler)
+			Expect(lines).To(ContainElement("# TYPE house_heating_zone_pid_term gauge"))
+			Expect(lines).To(ContainElement(`house_heating_zone_pid_term{name="one",term="p"} 0.5`))
+			Expect(lines).To(ContainElement(`house_heating_zone_pid_term{name="one",term="i"} 0`))
+			Expect(lines).To(ContainElement(`house_heating_zone_pid_term{name="one",term="d"} 0`))
+			Expect(lines).To(ContainElement(`house_heating_zone_pid_term{name="one",term="output"} 0.5`))
+			Expect(lines).NotTo(ContainElement(ContainSubstring(`pid_term{name="two"`)))
+		})
 	})
 })
@@ -14,6 +14,19 @@ type Metrics struct {
 	registry   *prometheus.Registry
 	sensorDesc *prometheus.Desc
 	zoneDesc   *prometheus.Desc
+
+	zoneActiveSecondsDesc *prometheus.Desc
+
+	humidityDesc *prometheus.Desc
+	pressureDesc *prometheus.Desc
+
+	rejectedReadingsDesc *prometheus.Desc
+	readErrorsDesc       *prometheus.Desc
+
+	frostActiveDesc      *prometheus.Desc
+	frostEngagementsDesc *prometheus.Desc
+	pidTermDesc          *prometheus.Desc
+	sensorStaleDesc      *prometheus.Desc
 }
 
 func newRegistry() *prometheus.Registry {
@@ -29,6 +42,19 @@ func New(ctrl *controller.Controller) *Metrics {
 		registry:   newRegistry(),
 		sensorDesc: newDensorDesc(),
 		zoneDesc:   newZoneDesc(),
+
+		zoneActiveSecondsDesc: newZoneActiveSecondsDesc(),
+
+		humidityDesc: newHumidityDesc(),
+		pressureDesc: newPressureDesc(),
+
+		rejectedReadingsDesc: newRejectedReadingsDesc(),
+		readErrorsDesc:       newReadErrorsDesc(),
+
+		frostActiveDesc:      newFrostActiveDesc(),
+		frostEngagementsDesc: newFrostEngagementsDesc(),
+		pidTermDesc:          newPIDTermDesc(),
+		sensorStaleDesc:      newSensorStaleDesc(),
 	}
 	m.registry.MustRegister(m)
 	return m
@@ -3,12 +3,18 @@ package scheduler
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/alext/heating-controller/units"
 )
 
 type Job struct {
-	Time   units.TimeOfDay
+	Time units.TimeOfDay
+	Days units.Weekdays
+	// Date restricts the job to running once on the given date.
+	Date units.Date
+	// Except lists any dates on which the job should not run.
+	Except []units.Date
 	Label  string
 	Action func()
 }
@@ -18,11 +24,75 @@ func (j Job) Valid() bool {
 }
 
 func (j Job) String() string {
-	return fmt.Sprintf("%s %s", j.Time, j.Label)
+	switch {
+	case !j.Date.IsZero():
+		return fmt.Sprintf("%s %s %s", j.Date, j.Time, j.Label)
+	case j.Days != 0:
+		return fmt.Sprintf("%s %s %s", j.Days, j.Time, j.Label)
+	default:
+		return fmt.Sprintf("%s %s", j.Time, j.Label)
+	}
+}
+
+func (j Job) runsOn(day time.Time) bool {
+	if !j.Days.Includes(day.Weekday()) {
+		return false
+	}
+	date := units.DateOf(day)
+	for _, d := range j.Except {
+		if d == date {
+			return false
+		}
+	}
+	return true
+}
+
+// NextOccuranceAfter returns the first time strictly after the given time that
+// the job is due to run. The zero time is returned if the job will not run
+// again.
+func (j Job) NextOccuranceAfter(current time.Time) time.Time {
+	if !j.Date.IsZero() {
+		if next := j.Date.At(j.Time, current.Location()); next.After(current) {
+			return next
+		}
+		return time.Time{}
+	}
+	for i := 0; i <= 7+len(j.Except); i++ {
+		day := current.AddDate(0, 0, i)
+		if !j.runsOn(day) {
+			continue
+		}
+		if next := j.Time.OnDay(day); next.After(current) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// PreviousOccuranceBefore returns the most recent time at or before the given
+// time that the job was due to run. The zero time is returned if the job
+// hasn't run yet.
+func (j Job) PreviousOccuranceBefore(current time.Time) time.Time {
+	if !j.Date.IsZero() {
+		if previous := j.Date.At(j.Time, current.Location()); !previous.After(current) {
+			return previous
+		}
+		return time.Time{}
+	}
+	for i := 0; i <= 7+len(j.Except); i++ {
+		day := current.AddDate(0, 0, -i)
+		if !j.runsOn(day) {
+			continue
+		}
+		if previous := j.Time.OnDay(day); !previous.After(current) {
+			return previous
+		}
+	}
+	return time.Time{}
 }
 
 func sortJobs(jobs []*Job) {
-	sort.Slice(jobs, func(i, j int) bool {
+	sort.SliceStable(jobs, func(i, j int) bool {
 		return jobs[i].Time < jobs[j].Time
 	})
 }
@@ -1,6 +1,8 @@
 package scheduler
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -17,4 +19,112 @@ var _ = Describe("Job", func() {
 			Expect(Job{Time: units.NewTimeOfDay(25, 0)}.Valid()).To(BeFalse())
 		})
 	})
+
+	Describe("calculating occurances", func() {
+		var (
+			// 2018-09-25 is a Tuesday
+			tuesdayAt = func(hour, minute int) time.Time {
+				return time.Date(2018, 9, 25, hour, minute, 0, 0, time.Local)
+			}
+			weekdays = units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+			weekends = units.NewWeekdays(time.Saturday, time.Sunday)
+		)
+
+		Describe("NextOccuranceAfter", func() {
+			It("returns the time later the same day", func() {
+				j := Job{Time: units.NewTimeOfDay(14, 15)}
+				Expect(j.NextOccuranceAfter(tuesdayAt(11, 0))).To(Equal(tuesdayAt(14, 15)))
+			})
+
+			It("returns the following day if the time has passed", func() {
+				j := Job{Time: units.NewTimeOfDay(14, 15)}
+				Expect(j.NextOccuranceAfter(tuesdayAt(15, 0))).To(Equal(tuesdayAt(14, 15).AddDate(0, 0, 1)))
+			})
+
+			It("does not return the given time itself", func() {
+				j := Job{Time: units.NewTimeOfDay(14, 15)}
+				Expect(j.NextOccuranceAfter(tuesdayAt(14, 15))).To(Equal(tuesdayAt(14, 15).AddDate(0, 0, 1)))
+			})
+
+			It("skips days not included in the job", func() {
+				j := Job{Time: units.NewTimeOfDay(8, 0), Days: weekends}
+				Expect(j.NextOccuranceAfter(tuesdayAt(11, 0))).To(Equal(tuesdayAt(8, 0).AddDate(0, 0, 4)))
+			})
+
+			It("returns the same day next week if necessary", func() {
+				j := Job{Time: units.NewTimeOfDay(8, 0), Days: units.NewWeekdays(time.Tuesday)}
+				Expect(j.NextOccuranceAfter(tuesdayAt(11, 0))).To(Equal(tuesdayAt(8, 0).AddDate(0, 0, 7)))
+			})
+		})
+
+		Describe("PreviousOccuranceBefore", func() {
+			It("returns the time earlier the same day", func() {
+				j := Job{Time: units.NewTimeOfDay(6, 30)}
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(11, 0))).To(Equal(tuesdayAt(6, 30)))
+			})
+
+			It("includes the given time itself", func() {
+				j := Job{Time: units.NewTimeOfDay(6, 30)}
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(6, 30))).To(Equal(tuesdayAt(6, 30)))
+			})
+
+			It("returns the previous day if the time hasn't been reached", func() {
+				j := Job{Time: units.NewTimeOfDay(22, 0)}
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(11, 0))).To(Equal(tuesdayAt(22, 0).AddDate(0, 0, -1)))
+			})
+
+			It("skips days not included in the job", func() {
+				j := Job{Time: units.NewTimeOfDay(8, 0), Days: weekends}
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(11, 0))).To(Equal(tuesdayAt(8, 0).AddDate(0, 0, -2)))
+
+				j = Job{Time: units.NewTimeOfDay(8, 0), Days: weekdays}
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(7, 0))).To(Equal(tuesdayAt(8, 0).AddDate(0, 0, -1)))
+			})
+		})
+
+		Describe("with a date", func() {
+			It("returns the time on the given date", func() {
+				j := Job{Time: units.NewTimeOfDay(9, 0), Date: units.NewDate(2018, 9, 27)}
+				Expect(j.NextOccuranceAfter(tuesdayAt(11, 0))).To(Equal(tuesdayAt(9, 0).AddDate(0, 0, 2)))
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(11, 0).AddDate(0, 0, 3))).To(Equal(tuesdayAt(9, 0).AddDate(0, 0, 2)))
+			})
+
+			It("returns the zero time if the job will not run again", func() {
+				j := Job{Time: units.NewTimeOfDay(9, 0), Date: units.NewDate(2018, 9, 25)}
+				Expect(j.NextOccuranceAfter(tuesdayAt(11, 0))).To(BeZero())
+			})
+
+			It("returns the zero time if the job hasn't run yet", func() {
+				j := Job{Time: units.NewTimeOfDay(9, 0), Date: units.NewDate(2018, 9, 25)}
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(8, 0))).To(BeZero())
+			})
+		})
+
+		Describe("with excepted dates", func() {
+			var j Job
+
+			BeforeEach(func() {
+				j = Job{
+					Time:   units.NewTimeOfDay(8, 0),
+					Except: []units.Date{units.NewDate(2018, 9, 25), units.NewDate(2018, 9, 26)},
+				}
+			})
+
+			It("skips the excepted dates when finding the next occurance", func() {
+				Expect(j.NextOccuranceAfter(tuesdayAt(7, 0))).To(Equal(tuesdayAt(8, 0).AddDate(0, 0, 2)))
+			})
+
+			It("skips the excepted dates when finding the previous occurance", func() {
+				Expect(j.PreviousOccuranceBefore(tuesdayAt(9, 0).AddDate(0, 0, 1))).To(Equal(tuesdayAt(8, 0).AddDate(0, 0, -1)))
+			})
+
+			It("handles more than a week of excepted dates", func() {
+				j.Except = nil
+				for i := 0; i < 10; i++ {
+					j.Except = append(j.Except, units.NewDate(2018, 9, 25+i))
+				}
+				Expect(j.NextOccuranceAfter(tuesdayAt(7, 0))).To(Equal(tuesdayAt(8, 0).AddDate(0, 0, 10)))
+			})
+		})
+	})
 })
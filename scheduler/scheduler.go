@@ -5,8 +5,6 @@ import (
 	"log"
 	"sync"
 	"time"
-
-	"github.com/alext/heating-controller/units"
 )
 
 // variable indirection to enable testing
@@ -163,7 +161,13 @@ func (s *scheduler) ReadJobs() []Job {
 	return result
 }
 
+// Override has no effect while the scheduler is stopped.
 func (s *scheduler) Override(j Job) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.running {
+		return
+	}
 	s.commandCh <- func() {
 		now := timeNow().Local()
 		s.nextAt = j.Time.NextOccuranceAfter(now)
@@ -174,6 +178,11 @@ func (s *scheduler) Override(j Job) {
 }
 
 func (s *scheduler) CancelOverride() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.running {
+		return
+	}
 	s.commandCh <- func() {
 		s.nextJob = nil
 	}
@@ -186,7 +195,7 @@ func (s *scheduler) run() {
 			now := timeNow().Local()
 			s.nextJob = s.next(now)
 			if s.nextJob != nil {
-				s.nextAt = s.nextJob.Time.NextOccuranceAfter(now)
+				s.nextAt = s.nextJob.NextOccuranceAfter(now)
 			} else {
 				s.nextAt = now.Add(24 * time.Hour)
 			}
@@ -226,7 +235,7 @@ func (s *scheduler) setJobs(jobs []Job) {
 func (s *scheduler) removeJob(job *Job) {
 	newJobs := make([]*Job, 0)
 	for _, j := range s.jobs {
-		if j.Time != job.Time || j.Label != job.Label {
+		if j.Time != job.Time || j.Days != job.Days || j.Date != job.Date || j.Label != job.Label {
 			newJobs = append(newJobs, j)
 		}
 	}
@@ -237,29 +246,39 @@ func (s *scheduler) setCurrentState() {
 	if len(s.jobs) < 1 {
 		return
 	}
-	currentToD := units.NewTimeOfDay(timeNow().Local().Clock())
-	var previous *Job
+	now := timeNow().Local()
+	var (
+		previous   *Job
+		previousAt time.Time
+	)
 	for _, j := range s.jobs {
-		if j.Time > currentToD {
-			break
+		at := j.PreviousOccuranceBefore(now)
+		if at.IsZero() {
+			continue
+		}
+		// Jobs are sorted by time, so on a tie the later entry in the list wins.
+		if previous == nil || !at.Before(previousAt) {
+			previous, previousAt = j, at
 		}
-		previous = j
 	}
-	if previous == nil {
-		previous = s.jobs[len(s.jobs)-1]
+	if previous != nil {
+		go previous.Action()
 	}
-	go previous.Action()
 }
 
 func (s *scheduler) next(now time.Time) *Job {
-	if len(s.jobs) < 1 {
-		return nil
-	}
-	currentToD := units.NewTimeOfDay(now.Clock())
-	for _, job := range s.jobs {
-		if job.Time > currentToD {
-			return job
+	var (
+		next   *Job
+		nextAt time.Time
+	)
+	for _, j := range s.jobs {
+		at := j.NextOccuranceAfter(now)
+		if at.IsZero() {
+			continue
+		}
+		if next == nil || at.Before(nextAt) {
+			next, nextAt = j, at
 		}
 	}
-	return s.jobs[0]
+	return next
 }
@@ -314,6 +314,56 @@ var _ = Describe("a basic scheduler", func() {
 		})
 	})
 
+	Describe("jobs restricted to certain days", func() {
+		var (
+			// 2018-09-28 is a Friday
+			fridayAt = func(hour, minute, second int) time.Time {
+				return time.Date(2018, 9, 28, hour, minute, second, 0, time.Local)
+			}
+			weekdays = units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+			weekends = units.NewWeekdays(time.Saturday, time.Sunday)
+		)
+
+		BeforeEach(func() {
+			theScheduler.AddJob(Job{Time: units.NewTimeOfDay(6, 30), Days: weekdays, Action: thing.TurnOn, Label: "weekday on"})
+			theScheduler.AddJob(Job{Time: units.NewTimeOfDay(8, 30), Days: weekdays, Action: thing.TurnOff, Label: "weekday off"})
+			theScheduler.AddJob(Job{Time: units.NewTimeOfDay(8, 0), Days: weekends, Action: thing.TurnOn, Label: "weekend on"})
+			theScheduler.AddJob(Job{Time: units.NewTimeOfDay(22, 0), Action: thing.TurnOff, Label: "daily off"})
+		})
+
+		It("should skip jobs that don't apply to the next day", func() {
+			mockNow = fridayAt(23, 0, 0)
+
+			theScheduler.Start()
+			<-waitNotify
+			thing.ExpectState(false)
+
+			Expect(resetParam.String()).To(Equal("9h0m0s"))
+			Expect(theScheduler).To(HaveNextJobLabelled("weekend on"))
+
+			mockNow = fridayAt(8, 0, 0).AddDate(0, 0, 1)
+			timerCh <- mockNow
+			<-waitNotify
+			thing.ExpectState(true)
+
+			Expect(resetParam.String()).To(Equal("14h0m0s"))
+		})
+
+		It("should apply the most recent applicable job's state on starting", func() {
+			mockNow = fridayAt(7, 0, 0)
+			theScheduler.Start()
+			<-waitNotify
+			thing.ExpectState(true)
+			theScheduler.Stop()
+
+			// Saturday morning, before the weekend on job.
+			mockNow = fridayAt(7, 0, 0).AddDate(0, 0, 1)
+			theScheduler.Start()
+			<-waitNotify
+			thing.ExpectState(false)
+		})
+	})
+
 	It("should return an error when adding an invalid job", func() {
 		err := theScheduler.AddJob(Job{Time: units.NewTimeOfDay(25, 0)})
 		Expect(err).To(MatchError(ErrInvalidJob))
@@ -656,6 +706,14 @@ var _ = Describe("a basic scheduler", func() {
 			Expect(theScheduler.ReadJobs()).To(HaveLen(4))
 		})
 
+		It("ignores an override while stopped", func() {
+			theScheduler.Stop()
+			theScheduler.Override(Job{Time: units.NewTimeOfDay(16, 30), Action: thing.TurnOn, Label: "override"})
+			theScheduler.CancelOverride()
+
+			Expect(theScheduler).To(HaveNextJobLabelled("charlie"))
+		})
+
 		Describe("cancelling the override", func() {
 			BeforeEach(func() {
 				mockNow = todayAt(16, 0, 0)
@@ -0,0 +1,243 @@
+package sensor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alext/heating-controller/units"
+)
+
+// variable indirection to enable testing
+var timeNow = time.Now
+
+// Aggregation is a strategy for combining the readings of several sensors.
+type Aggregation string
+
+const (
+	AggregateMean     Aggregation = "mean"
+	AggregateMin      Aggregation = "min"
+	AggregateMax      Aggregation = "max"
+	AggregateMedian   Aggregation = "median"
+	AggregateWeighted Aggregation = "weighted"
+)
+
+func (a Aggregation) Valid() bool {
+	switch a {
+	case AggregateMean, AggregateMin, AggregateMax, AggregateMedian, AggregateWeighted:
+		return true
+	}
+	return false
+}
+
+// AggregateInput is one of the sensors combined by an aggregate sensor.
+type AggregateInput struct {
+	Name   string
+	Sensor Sensor
+	// Only used with AggregateWeighted.
+	Weight float64
+	// Readings older than this are excluded. Zero disables this.
+	MaxAge time.Duration
+}
+
+// InputReading is the latest reading of one of the inputs to an aggregate
+// sensor.
+type InputReading struct {
+	Name        string            `json:"name"`
+	Temperature units.Temperature `json:"temperature"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	// Excluded is set when the reading isn't included in the aggregate value
+	// because it's stale (too old, or the sensor reports it stale), or there's
+	// never been a reading.
+	Excluded bool `json:"excluded"`
+}
+
+// MultiSensor is a sensor whose value is calculated from several others.
+type MultiSensor interface {
+	Sensor
+	Inputs() []InputReading
+}
+
+// combineFunc calculates a sensor's value from the latest readings of its
+// inputs. It returns false if there aren't enough current readings to do so.
+type combineFunc func(inputs []AggregateInput, readings []InputReading) (units.Temperature, bool)
+
+// combinedSensor is a sensor whose value is calculated from other sensors,
+// recalculating whenever any of them updates.
+type combinedSensor struct {
+	baseSensor
+	inputs  []AggregateInput
+	combine combineFunc
+	subs    []*Subscription
+	updates chan struct{}
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newCombinedSensor(name string, inputs []AggregateInput, combine combineFunc) *combinedSensor {
+	s := &combinedSensor{
+		baseSensor: newBaseSensor(name, name),
+		inputs:     inputs,
+		combine:    combine,
+		updates:    make(chan struct{}, 1),
+		closeCh:    make(chan struct{}),
+	}
+	for _, in := range inputs {
+		sub := in.Sensor.Subscribe()
+		s.subs = append(s.subs, sub)
+		s.wg.Add(1)
+		go s.forward(sub)
+	}
+	s.recalculate()
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// NewAggregateSensor returns a sensor that combines the readings of the
+// given inputs using the given aggregation, recalculating whenever any of
+// them updates.
+func NewAggregateSensor(name string, aggregation Aggregation, inputs []AggregateInput) (MultiSensor, error) {
+	if !aggregation.Valid() {
+		return nil, fmt.Errorf("Unrecognised aggregation: '%s'", aggregation)
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("No inputs given for aggregate sensor '%s'", name)
+	}
+	return newCombinedSensor(name, inputs, func(inputs []AggregateInput, readings []InputReading) (units.Temperature, bool) {
+		var (
+			temps   []units.Temperature
+			weights []float64
+		)
+		for i, r := range readings {
+			if r.Excluded {
+				continue
+			}
+			temps = append(temps, r.Temperature)
+			weights = append(weights, inputs[i].Weight)
+		}
+		if len(temps) == 0 {
+			return 0, false
+		}
+		return aggregate(aggregation, temps, weights), true
+	}), nil
+}
+
+// Close stops following the inputs, but leaves them open.
+func (s *combinedSensor) Close() {
+	s.close(s.stop)
+}
+
+func (s *combinedSensor) stop() {
+	for _, sub := range s.subs {
+		sub.Cancel()
+	}
+	close(s.closeCh)
+	s.wg.Wait()
+}
+
+// forward signals an update for each reading from the input, until the
+// subscription is cancelled or the input is closed.
+func (s *combinedSensor) forward(sub *Subscription) {
+	defer s.wg.Done()
+	for range sub.C {
+		select {
+		case s.updates <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *combinedSensor) loop() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.updates:
+			s.recalculate()
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// Inputs returns the latest reading of each of the inputs.
+func (s *combinedSensor) Inputs() []InputReading {
+	readings := make([]InputReading, 0, len(s.inputs))
+	now := timeNow()
+	for _, in := range s.inputs {
+		temp, updatedAt := in.Sensor.Read()
+		excluded := updatedAt.IsZero() || (in.MaxAge > 0 && now.Sub(updatedAt) > in.MaxAge)
+		if sr, ok := in.Sensor.(StaleReporter); ok && sr.Stale() {
+			excluded = true
+		}
+		readings = append(readings, InputReading{
+			Name:        in.Name,
+			Temperature: temp,
+			UpdatedAt:   updatedAt,
+			Excluded:    excluded,
+		})
+	}
+	return readings
+}
+
+func (s *combinedSensor) recalculate() {
+	readings := s.Inputs()
+	temp, ok := s.combine(s.inputs, readings)
+	if !ok {
+		log.Printf("[Sensor:%s] Not enough current readings from inputs", s.name)
+		return
+	}
+	var latest time.Time
+	for _, r := range readings {
+		if !r.Excluded && r.UpdatedAt.After(latest) {
+			latest = r.UpdatedAt
+		}
+	}
+	s.set(temp, latest)
+}
+
+func aggregate(a Aggregation, temps []units.Temperature, weights []float64) units.Temperature {
+	switch a {
+	case AggregateMin:
+		min := temps[0]
+		for _, t := range temps[1:] {
+			if t < min {
+				min = t
+			}
+		}
+		return min
+	case AggregateMax:
+		max := temps[0]
+		for _, t := range temps[1:] {
+			if t > max {
+				max = t
+			}
+		}
+		return max
+	case AggregateMedian:
+		sorted := append([]units.Temperature(nil), temps...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	case AggregateWeighted:
+		var sum, total float64
+		for i, t := range temps {
+			sum += float64(t) * weights[i]
+			total += weights[i]
+		}
+		if total > 0 {
+			return units.Temperature(math.Round(sum / total))
+		}
+	}
+	var sum units.Temperature
+	for _, t := range temps {
+		sum += t
+	}
+	return sum / units.Temperature(len(temps))
+}
@@ -0,0 +1,124 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/units"
+)
+
+type staleSensor struct {
+	Sensor
+}
+
+func (staleSensor) Stale() bool { return true }
+
+var _ = Describe("an aggregate sensor", func() {
+	var (
+		one, two, three SettableSensor
+		inputs          []AggregateInput
+		s               MultiSensor
+		now             time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+		one = NewPushSensor("one", "1")
+		two = NewPushSensor("two", "2")
+		three = NewPushSensor("three", "3")
+		one.Set(19000, now.Add(-3*time.Minute))
+		two.Set(20000, now.Add(-2*time.Minute))
+		three.Set(22000, now.Add(-time.Minute))
+		inputs = []AggregateInput{
+			{Name: "one", Sensor: one, Weight: 2},
+			{Name: "two", Sensor: two, Weight: 1},
+			{Name: "three", Sensor: three, Weight: 1},
+		}
+	})
+
+	AfterEach(func() {
+		timeNow = time.Now
+		if s != nil {
+			s.Close()
+			s = nil
+		}
+	})
+
+	It("errors with an unrecognised aggregation or no inputs", func() {
+		_, err := NewAggregateSensor("agg", "fuzzy", inputs)
+		Expect(err).To(HaveOccurred())
+		_, err = NewAggregateSensor("agg", AggregateMean, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("combining the input readings",
+		func(a Aggregation, expected int) {
+			var err error
+			s, err = NewAggregateSensor("agg", a, inputs)
+			Expect(err).NotTo(HaveOccurred())
+			temp, updatedAt := s.Read()
+			Expect(temp).To(BeEquivalentTo(expected))
+			Expect(updatedAt).To(Equal(now.Add(-time.Minute)))
+		},
+		Entry("mean", AggregateMean, 20333),
+		Entry("min", AggregateMin, 19000),
+		Entry("max", AggregateMax, 22000),
+		Entry("median", AggregateMedian, 20000),
+		Entry("weighted", AggregateWeighted, 20000),
+	)
+
+	It("takes the mean of the middle two for the median of an even number", func() {
+		s, _ = NewAggregateSensor("agg", AggregateMedian, inputs[:2])
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(19500))
+	})
+
+	It("recalculates and notifies subscribers when an input updates", func() {
+		s, _ = NewAggregateSensor("agg", AggregateMax, inputs)
+		ch := s.Subscribe().C
+
+		two.Set(23000, now)
+		Eventually(ch).Should(Receive(Equal(units.Temperature(23000))))
+		temp, updatedAt := s.Read()
+		Expect(temp).To(BeEquivalentTo(23000))
+		Expect(updatedAt).To(Equal(now))
+	})
+
+	It("excludes stale inputs and inputs without a reading", func() {
+		inputs[2].MaxAge = 30 * time.Second
+		inputs = append(inputs, AggregateInput{Name: "four", Sensor: NewPushSensor("four", "4")})
+		s, _ = NewAggregateSensor("agg", AggregateMean, inputs)
+
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(19500))
+		Expect(s.Inputs()).To(Equal([]InputReading{
+			{Name: "one", Temperature: 19000, UpdatedAt: now.Add(-3 * time.Minute)},
+			{Name: "two", Temperature: 20000, UpdatedAt: now.Add(-2 * time.Minute)},
+			{Name: "three", Temperature: 22000, UpdatedAt: now.Add(-time.Minute), Excluded: true},
+			{Name: "four", Temperature: initialValue, Excluded: true},
+		}))
+	})
+
+	It("excludes inputs that report themselves stale", func() {
+		inputs[0].Sensor = staleSensor{one}
+		s, _ = NewAggregateSensor("agg", AggregateMean, inputs)
+
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(21000))
+		Expect(s.Inputs()[0].Excluded).To(BeTrue())
+	})
+
+	It("keeps the last value if all inputs are excluded", func() {
+		for i := range inputs {
+			inputs[i].MaxAge = 30 * time.Second
+		}
+		s, _ = NewAggregateSensor("agg", AggregateMean, inputs)
+
+		_, updatedAt := s.Read()
+		Expect(updatedAt.IsZero()).To(BeTrue())
+	})
+})
@@ -13,16 +13,25 @@ type baseSensor struct {
 	id            string
 	lock          sync.RWMutex
 	temp          units.Temperature
+	raw           units.Temperature
+	filtered      units.Temperature
+	filter        *readingFilter
+	calibration   Calibration
 	updatedAt     time.Time
+	quantities    Quantities
+	quantitiesAt  time.Time
+	history       *History
 	subscriptions []chan units.Temperature
+	closed        bool
+	closeOnce     sync.Once
 }
 
 func newBaseSensor(name, id string) baseSensor {
-	s := baseSensor{
-		name: name,
-		id:   id,
+	return baseSensor{
+		name:    name,
+		id:      id,
+		history: NewHistory(DefaultRetention),
 	}
-	return s
 }
 
 func (s *baseSensor) ID() string {
@@ -35,21 +44,142 @@ func (s *baseSensor) Read() (units.Temperature, time.Time) {
 	return s.temp, s.updatedAt
 }
 
-func (s *baseSensor) Subscribe() <-chan units.Temperature {
+// ReadQuantities returns a copy, so that callers can't modify the values
+// behind the lock.
+func (s *baseSensor) ReadQuantities() (Quantities, time.Time) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return Quantities{}.merge(s.quantities), s.quantitiesAt
+}
+
+func (s *baseSensor) setQuantities(q Quantities, updatedAt time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.quantities = s.quantities.merge(q)
+	s.quantitiesAt = updatedAt
+	log.Printf("[Sensor:%s] updated %s, (updatedAt: %s)", s.name, q, updatedAt)
+}
+
+func (s *baseSensor) Subscribe() *Subscription {
 	ch := make(chan units.Temperature, 1)
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if s.closed {
+		close(ch)
+		return &Subscription{C: ch, cancel: func() {}}
+	}
 	s.subscriptions = append(s.subscriptions, ch)
-	return ch
+	return &Subscription{C: ch, cancel: func() { s.unsubscribe(ch) }}
+}
+
+func (s *baseSensor) unsubscribe(ch chan units.Temperature) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, sub := range s.subscriptions {
+		if sub == ch {
+			s.subscriptions = append(s.subscriptions[:i:i], s.subscriptions[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// close calls stop to end any polling, and then closes all subscriptions. It
+// only does so the first time it's called.
+func (s *baseSensor) close(stop func()) {
+	s.closeOnce.Do(func() {
+		if stop != nil {
+			stop()
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.closed = true
+		for _, ch := range s.subscriptions {
+			close(ch)
+		}
+		s.subscriptions = nil
+	})
+}
+
+func (s *baseSensor) History() *History {
+	return s.history
+}
+
+func (s *baseSensor) Raw() units.Temperature {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.raw
+}
+
+func (s *baseSensor) Filter() *Filter {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.filter == nil {
+		return nil
+	}
+	f := s.filter.Filter
+	return &f
+}
+
+func (s *baseSensor) RejectedReadings() RejectedReadings {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.filter == nil {
+		return RejectedReadings{}
+	}
+	return s.filter.rejected
+}
+
+func (s *baseSensor) setFilter(f *Filter) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.filter = newReadingFilter(f)
 }
 
-func (s *baseSensor) set(temp units.Temperature, updatedAt time.Time) {
+func (s *baseSensor) Calibration() Calibration {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.calibration
+}
+
+// SetCalibration sets the calibration, and recalibrates the latest reading.
+func (s *baseSensor) SetCalibration(c Calibration) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	s.temp = temp
+	s.calibration = c
+	log.Printf("[Sensor:%s] calibration set to %s", s.name, c)
+	if s.updatedAt.IsZero() {
+		return
+	}
+	s.temp = c.Apply(s.filtered)
+	s.notify()
+}
+
+func (s *baseSensor) set(raw units.Temperature, updatedAt time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	filtered := raw
+	if s.filter != nil {
+		var err error
+		filtered, err = s.filter.apply(raw, updatedAt)
+		if err != nil {
+			log.Printf("[Sensor:%s] rejected reading %s: %s", s.name, raw, err.Error())
+			return
+		}
+	}
+	s.raw = raw
+	s.filtered = filtered
+	s.temp = s.calibration.Apply(filtered)
 	s.updatedAt = updatedAt
-	log.Printf("[Sensor:%s] updated to %s, (updatedAt: %s)", s.name, temp, updatedAt)
+	if s.history != nil {
+		s.history.Add(Reading{Temperature: s.temp, At: updatedAt})
+	}
+	log.Printf("[Sensor:%s] updated to %s (raw: %s), (updatedAt: %s)", s.name, s.temp, raw, updatedAt)
+	s.notify()
+}
 
+// Must be called with the lock held.
+func (s *baseSensor) notify() {
 	for _, ch := range s.subscriptions {
 		select {
 		case ch <- s.temp:
@@ -0,0 +1,43 @@
+package sensor
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/alext/heating-controller/units"
+)
+
+// Calibration corrects the raw readings from a sensor. The raw value is
+// multiplied by Scale and then Offset is added.
+type Calibration struct {
+	Offset units.Temperature `json:"offset"`
+	// Zero means no scaling.
+	Scale float64 `json:"scale,omitempty"`
+}
+
+func (c Calibration) Valid() bool {
+	return c.Scale >= 0
+}
+
+func (c Calibration) Apply(raw units.Temperature) units.Temperature {
+	if c.Scale != 0 {
+		raw = units.Temperature(math.Round(float64(raw) * c.Scale))
+	}
+	return raw + c.Offset
+}
+
+func (c Calibration) String() string {
+	if c.Scale == 0 {
+		return fmt.Sprintf("offset %s", c.Offset)
+	}
+	return fmt.Sprintf("scale %g, offset %s", c.Scale, c.Offset)
+}
+
+// CalibratedSensor is a sensor whose readings are corrected by a calibration.
+type CalibratedSensor interface {
+	Sensor
+	// Raw returns the latest reading before calibration.
+	Raw() units.Temperature
+	Calibration() Calibration
+	SetCalibration(Calibration)
+}
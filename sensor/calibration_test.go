@@ -0,0 +1,63 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("calibrating a sensor", func() {
+	DescribeTable("applying a calibration",
+		func(cal Calibration, raw, expected int) {
+			Expect(cal.Apply(units.Temperature(raw))).To(BeEquivalentTo(expected))
+		},
+		Entry("no calibration", Calibration{}, 19000, 19000),
+		Entry("an offset", Calibration{Offset: -500}, 19000, 18500),
+		Entry("a scale", Calibration{Scale: 1.02}, 19000, 19380),
+		Entry("a scale and offset", Calibration{Offset: 300, Scale: 0.98}, 19000, 18920),
+	)
+
+	It("rejects a negative scale", func() {
+		Expect(Calibration{Scale: -1}.Valid()).To(BeFalse())
+		Expect(Calibration{Offset: -500}.Valid()).To(BeTrue())
+	})
+
+	Describe("a calibrated sensor", func() {
+		var (
+			s   SettableSensor
+			now time.Time
+		)
+
+		BeforeEach(func() {
+			now = time.Now()
+			s = NewPushSensor("foo", "something")
+			s.(CalibratedSensor).SetCalibration(Calibration{Offset: -400})
+		})
+
+		It("calibrates readings, keeping the raw value available", func() {
+			ch := s.Subscribe().C
+			s.Set(19000, now)
+
+			Eventually(ch).Should(Receive(Equal(units.Temperature(18600))))
+			temp, updatedAt := s.Read()
+			Expect(temp).To(BeEquivalentTo(18600))
+			Expect(updatedAt).To(Equal(now))
+			Expect(s.(CalibratedSensor).Raw()).To(BeEquivalentTo(19000))
+		})
+
+		It("recalibrates the latest reading when the calibration changes", func() {
+			s.Set(19000, now)
+			ch := s.Subscribe().C
+
+			s.(CalibratedSensor).SetCalibration(Calibration{Offset: 200})
+			Eventually(ch).Should(Receive(Equal(units.Temperature(19200))))
+			Expect(s.(CalibratedSensor).Calibration()).To(Equal(Calibration{Offset: 200}))
+			_, updatedAt := s.Read()
+			Expect(updatedAt).To(Equal(now))
+		})
+	})
+})
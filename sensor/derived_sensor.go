@@ -0,0 +1,58 @@
+package sensor
+
+import (
+	"fmt"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+// NewDifferenceSensor returns a sensor whose value is the reading of minuend
+// less the reading of subtrahend (e.g. flow minus return).
+func NewDifferenceSensor(name string, minuend, subtrahend AggregateInput) MultiSensor {
+	inputs := []AggregateInput{minuend, subtrahend}
+	return newCombinedSensor(name, inputs, func(_ []AggregateInput, readings []InputReading) (units.Temperature, bool) {
+		if readings[0].Excluded || readings[1].Excluded {
+			return 0, false
+		}
+		return readings[0].Temperature - readings[1].Temperature, true
+	})
+}
+
+// NewOffsetSensor returns a sensor whose value is the reading of input with a
+// constant offset added.
+func NewOffsetSensor(name string, input AggregateInput, offset units.Temperature) MultiSensor {
+	return newCombinedSensor(name, []AggregateInput{input}, func(_ []AggregateInput, readings []InputReading) (units.Temperature, bool) {
+		if readings[0].Excluded {
+			return 0, false
+		}
+		return readings[0].Temperature + offset, true
+	})
+}
+
+// newDerived builds a sensor calculated from the named input sensors.
+func newDerived(name string, cfg config.SensorConfig, sensors map[string]Sensor) (Sensor, error) {
+	inputs := make([]AggregateInput, 0, len(cfg.Inputs))
+	for _, inputName := range cfg.Inputs {
+		s, ok := sensors[inputName]
+		if !ok {
+			return nil, fmt.Errorf("Unknown input '%s' for sensor '%s'", inputName, name)
+		}
+		inputs = append(inputs, AggregateInput{Name: inputName, Sensor: s, Weight: 1})
+	}
+
+	switch cfg.Type {
+	case "difference":
+		if len(inputs) != 2 {
+			return nil, fmt.Errorf("Difference sensor '%s' needs exactly 2 inputs", name)
+		}
+		return NewDifferenceSensor(name, inputs[0], inputs[1]), nil
+	case "offset":
+		if len(inputs) != 1 {
+			return nil, fmt.Errorf("Offset sensor '%s' needs exactly 1 input", name)
+		}
+		return NewOffsetSensor(name, inputs[0], cfg.Offset), nil
+	default:
+		return NewAggregateSensor(name, Aggregation(cfg.Type), inputs)
+	}
+}
@@ -0,0 +1,96 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("derived sensors", func() {
+	var (
+		flow, ret SettableSensor
+		sensors   map[string]Sensor
+		s         Sensor
+		now       time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+		flow = NewPushSensor("flow", "1")
+		ret = NewPushSensor("return", "2")
+		flow.Set(45000, now.Add(-time.Minute))
+		ret.Set(38500, now.Add(-2*time.Minute))
+		sensors = map[string]Sensor{"flow": flow, "return": ret}
+	})
+
+	AfterEach(func() {
+		timeNow = time.Now
+		if s != nil {
+			s.Close()
+			s = nil
+		}
+	})
+
+	Describe("a difference sensor", func() {
+		BeforeEach(func() {
+			var err error
+			s, err = New("delta", config.SensorConfig{Type: "difference", Inputs: []string{"flow", "return"}}, sensors)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("subtracts the second input from the first", func() {
+			temp, updatedAt := s.Read()
+			Expect(temp).To(BeEquivalentTo(6500))
+			Expect(updatedAt).To(Equal(now.Add(-time.Minute)))
+		})
+
+		It("recalculates and notifies subscribers when an input updates", func() {
+			ch := s.Subscribe().C
+			ret.Set(40000, now)
+			Eventually(ch).Should(Receive(Equal(units.Temperature(5000))))
+		})
+
+		It("has no value until both inputs have a reading", func() {
+			s.Close()
+			s, _ = New("delta", config.SensorConfig{Type: "difference", Inputs: []string{"flow", "other"}},
+				map[string]Sensor{"flow": flow, "other": NewPushSensor("other", "3")})
+			_, updatedAt := s.Read()
+			Expect(updatedAt.IsZero()).To(BeTrue())
+		})
+	})
+
+	It("adds the offset to the input of an offset sensor", func() {
+		var err error
+		s, err = New("adjusted", config.SensorConfig{Type: "offset", Inputs: []string{"flow"}, Offset: -1500}, sensors)
+		Expect(err).NotTo(HaveOccurred())
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(43500))
+	})
+
+	It("aggregates the inputs of mean, min and max sensors", func() {
+		var err error
+		s, err = New("average", config.SensorConfig{Type: "mean", Inputs: []string{"flow", "return"}}, sensors)
+		Expect(err).NotTo(HaveOccurred())
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(41750))
+	})
+
+	It("errors with the wrong number of inputs", func() {
+		_, err := New("delta", config.SensorConfig{Type: "difference", Inputs: []string{"flow"}}, sensors)
+		Expect(err).To(HaveOccurred())
+		_, err = New("adjusted", config.SensorConfig{Type: "offset", Inputs: []string{"flow", "return"}}, sensors)
+		Expect(err).To(HaveOccurred())
+		_, err = New("average", config.SensorConfig{Type: "mean"}, sensors)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("errors with an unknown input", func() {
+		_, err := New("delta", config.SensorConfig{Type: "difference", Inputs: []string{"flow", "non-existent"}}, sensors)
+		Expect(err).To(MatchError("Unknown input 'non-existent' for sensor 'delta'"))
+	})
+})
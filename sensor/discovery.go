@@ -0,0 +1,55 @@
+package sensor
+
+import (
+	"errors"
+	iofs "io/fs"
+	"strings"
+
+	"github.com/alext/heating-controller/units"
+)
+
+// The family code prefixes of 1-wire temperature sensor IDs.
+var w1TemperatureFamilies = []string{"10-", "22-", "28-", "3b-", "42-"}
+
+// W1Device is a 1-wire temperature sensor found on the bus.
+type W1Device struct {
+	ID          string            `json:"id"`
+	Temperature units.Temperature `json:"temperature"`
+	// Set if the device couldn't be read.
+	Error string `json:"error,omitempty"`
+}
+
+// DiscoverW1Devices lists the 1-wire temperature sensors on the bus, along
+// with a current reading from each.
+func DiscoverW1Devices() ([]W1Device, error) {
+	entries, err := iofs.ReadDir(fs, strings.TrimSuffix(w1DevicesPath, "/"))
+	if err != nil {
+		if errors.Is(err, iofs.ErrNotExist) {
+			// No 1-wire bus.
+			return nil, nil
+		}
+		return nil, err
+	}
+	var devices []W1Device
+	for _, e := range entries {
+		if !isW1TemperatureSensor(e.Name()) {
+			continue
+		}
+		d := W1Device{ID: e.Name()}
+		d.Temperature, err = readW1Device(d.ID)
+		if err != nil {
+			d.Error = err.Error()
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+func isW1TemperatureSensor(id string) bool {
+	for _, prefix := range w1TemperatureFamilies {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
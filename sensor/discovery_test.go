@@ -0,0 +1,39 @@
+package sensor
+
+import (
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("discovering w1 devices", func() {
+	var (
+		testFS fstest.MapFS
+	)
+
+	BeforeEach(func() {
+		testFS = make(fstest.MapFS)
+		fs = testFS
+	})
+
+	It("lists the temperature sensors with a current reading", func() {
+		testFS[w1DevicesPath+"28-0123456789ab/w1_slave"] = &fstest.MapFile{Data: []byte(sampleData1)}
+		testFS[w1DevicesPath+"28-0123456789cd/w1_slave"] = &fstest.MapFile{Data: []byte(badCRCData)}
+		testFS[w1DevicesPath+"w1_bus_master1/uevent"] = &fstest.MapFile{}
+		testFS[w1DevicesPath+"01-0123456789ef/uevent"] = &fstest.MapFile{}
+
+		devices, err := DiscoverW1Devices()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(devices).To(Equal([]W1Device{
+			{ID: "28-0123456789ab", Temperature: 19437},
+			{ID: "28-0123456789cd", Error: "CRC check failed"},
+		}))
+	})
+
+	It("returns nothing when there's no 1-wire bus", func() {
+		devices, err := DiscoverW1Devices()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(devices).To(BeEmpty())
+	})
+})
@@ -0,0 +1,164 @@
+package sensor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+// OutputFormat is the form of the temperature printed by an exec sensor's
+// command.
+type OutputFormat string
+
+const (
+	// OutputCelsius is a decimal number of °C, optionally followed by "°C".
+	OutputCelsius OutputFormat = "celsius"
+	// OutputMillidegrees is an integer number of thousandths of a °C, as
+	// reported by the kernel for many sensors.
+	OutputMillidegrees OutputFormat = "millidegrees"
+)
+
+const defaultExecTimeout = 10 * time.Second
+
+// ExecCommand configures the command run by an exec sensor.
+type ExecCommand struct {
+	// The program and its arguments. It's run directly, not via a shell, and
+	// on timeout only the program itself is killed.
+	Args    []string
+	Timeout time.Duration
+	Format  OutputFormat
+}
+
+func (c ExecCommand) Valid() bool {
+	if len(c.Args) == 0 || c.Args[0] == "" || c.Timeout < 0 {
+		return false
+	}
+	switch c.Format {
+	case "", OutputCelsius, OutputMillidegrees:
+		return true
+	}
+	return false
+}
+
+type execSensor struct {
+	baseSensor
+	command ExecCommand
+	polling Polling
+	errors  ReadErrors
+	closeCh chan struct{}
+}
+
+// NewExecSensor returns a sensor that runs a command to read the temperature.
+func NewExecSensor(name, id string, command ExecCommand, polling Polling) Sensor {
+	return newExecSensor(name, id, command, nil, polling)
+}
+
+func newExecSensor(name, id string, command ExecCommand, f *Filter, polling Polling) Sensor {
+	if command.Timeout == 0 {
+		command.Timeout = defaultExecTimeout
+	}
+	if command.Format == "" {
+		command.Format = OutputCelsius
+	}
+	s := &execSensor{
+		baseSensor: newBaseSensor(name, id),
+		command:    command,
+		polling:    polling,
+		closeCh:    make(chan struct{}),
+	}
+	s.filter = newReadingFilter(f)
+	s.readTemperature(timeNow())
+	go s.polling.loop(s.closeCh, s.readTemperature)
+	return s
+}
+
+func (s *execSensor) Close() {
+	s.close(s.stopPolling)
+}
+
+func (s *execSensor) stopPolling() {
+	s.closeCh <- struct{}{}
+	<-s.closeCh
+}
+
+func (s *execSensor) ReadErrors() ReadErrors {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.errors
+}
+
+func (s *execSensor) readTemperature(updateTime time.Time) {
+	temp, err := s.run()
+	if err != nil {
+		s.lock.Lock()
+		s.errors.ReadFailures++
+		s.errors.LastError = err.Error()
+		s.errors.LastErrorAt = timeNow()
+		s.lock.Unlock()
+		log.Printf("[Sensor:%s] Error running %s: %s", s.name, s.command.Args[0], err.Error())
+		return
+	}
+	s.set(temp, updateTime)
+}
+
+func (s *execSensor) run() (units.Temperature, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.command.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command.Args[0], s.command.Args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return 0, fmt.Errorf("Timed out after %s", s.command.Timeout)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return 0, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return 0, err
+	}
+	return parseCommandOutput(stdout.String(), s.command.Format)
+}
+
+// parseCommandOutput parses the temperature from the last non-empty line of
+// the output, so that scripts can print diagnostics before it.
+func parseCommandOutput(output string, format OutputFormat) (units.Temperature, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	value := strings.TrimSpace(lines[len(lines)-1])
+	if value == "" {
+		return 0, fmt.Errorf("No output")
+	}
+	temp, err := units.ParseTemperature(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid temperature value '%s'", value)
+	}
+	if format == OutputMillidegrees {
+		temp /= 1000
+	}
+	return temp, nil
+}
+
+// execCommandFromConfig returns the command given in the config, using the
+// defaults for any missing values.
+func execCommandFromConfig(cfg config.SensorConfig) (ExecCommand, error) {
+	c := ExecCommand{
+		Args:    cfg.Command,
+		Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		Format:  OutputFormat(cfg.Format),
+	}
+	if !c.Valid() {
+		return c, fmt.Errorf("Invalid command: %q, format: '%s'", cfg.Command, cfg.Format)
+	}
+	return c, nil
+}
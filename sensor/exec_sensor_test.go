@@ -0,0 +1,171 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/ticker"
+)
+
+var _ = Describe("an exec sensor", func() {
+	var (
+		tkr       *dummyTicker
+		tkrNotify chan struct{}
+		s         Sensor
+		now       time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+
+		tkrNotify = make(chan struct{}, 1)
+		newTicker = func(d time.Duration) ticker.Ticker {
+			tkr = &dummyTicker{
+				duration: d,
+				C:        make(chan time.Time, 1),
+				notify:   tkrNotify,
+			}
+			return tkr
+		}
+	})
+
+	AfterEach(func() {
+		if s != nil {
+			s.Close()
+		}
+		s = nil
+		timeNow = time.Now
+	})
+
+	var shell = func(script string) ExecCommand {
+		return ExecCommand{Args: []string{"sh", "-c", script}}
+	}
+
+	It("reads the temperature on construction", func() {
+		s = NewExecSensor("foo", "bar", shell("echo 19.5"), DefaultPolling)
+		temp, updatedAt := s.Read()
+		Expect(temp).To(BeEquivalentTo(19500))
+		Expect(updatedAt).To(Equal(now))
+	})
+
+	It("runs the command at the given interval", func() {
+		s = NewExecSensor("foo", "bar", shell("echo 19.5"), Polling{Interval: 30 * time.Second})
+		<-tkrNotify
+		Expect(tkr.duration).To(Equal(30 * time.Second))
+
+		tkr.C <- now.Add(30 * time.Second)
+		<-tkrNotify
+		_, updatedAt := s.Read()
+		Expect(updatedAt).To(Equal(now.Add(30 * time.Second)))
+	})
+
+	DescribeTable("parsing the output",
+		func(format OutputFormat, output string, expected int) {
+			cmd := shell("printf -- '" + output + "'")
+			cmd.Format = format
+			s = NewExecSensor("foo", "bar", cmd, DefaultPolling)
+			temp, _ := s.Read()
+			Expect(temp).To(BeEquivalentTo(expected))
+		},
+		Entry("°C", OutputCelsius, "21.37\n", 21370),
+		Entry("°C with the unit", OutputCelsius, "21.37°C\n", 21370),
+		Entry("°C by default", OutputFormat(""), "-2.1", -2100),
+		Entry("millidegrees", OutputMillidegrees, "21375\n", 21375),
+		Entry("the last line of the output", OutputCelsius, "reading bme280...\n18.25\n\n", 18250),
+	)
+
+	Describe("failed reads", func() {
+		var expectFailure = func(cmd ExecCommand, message string) {
+			s = NewExecSensor("foo", "bar", cmd, DefaultPolling)
+			_, updatedAt := s.Read()
+			Expect(updatedAt.IsZero()).To(BeTrue())
+			errs := s.(ErrorCountingSensor).ReadErrors()
+			Expect(errs.ReadFailures).To(BeEquivalentTo(1))
+			Expect(errs.LastError).To(ContainSubstring(message))
+			Expect(errs.LastErrorAt).To(Equal(now))
+		}
+
+		It("counts errors when the command fails, including its stderr", func() {
+			expectFailure(shell("echo 'i2c bus error' >&2; exit 1"), "i2c bus error")
+		})
+
+		It("counts errors when the command can't be run", func() {
+			expectFailure(ExecCommand{Args: []string{"/non-existent/command"}}, "no such file")
+		})
+
+		It("counts errors for unparseable output", func() {
+			expectFailure(shell("echo oops"), "Invalid temperature value 'oops'")
+		})
+
+		It("counts errors for no output", func() {
+			expectFailure(shell("true"), "No output")
+		})
+
+		It("times out slow commands", func() {
+			cmd := shell("exec sleep 5")
+			cmd.Timeout = 20 * time.Millisecond
+			expectFailure(cmd, "Timed out after 20ms")
+		})
+
+		It("keeps the last reading after a failure", func() {
+			s = NewExecSensor("foo", "bar", shell("echo 19.5"), DefaultPolling)
+			s.(*execSensor).command = shell("exit 1")
+			<-tkrNotify
+			tkr.C <- now.Add(time.Minute)
+			<-tkrNotify
+
+			temp, updatedAt := s.Read()
+			Expect(temp).To(BeEquivalentTo(19500))
+			Expect(updatedAt).To(Equal(now))
+			Expect(s.(ErrorCountingSensor).ReadErrors().ReadFailures).To(BeEquivalentTo(1))
+		})
+	})
+
+	Describe("constructing from config", func() {
+		It("uses the command as the ID unless one is given", func() {
+			var err error
+			s, err = New("foo", config.SensorConfig{Type: "exec", Command: []string{"echo", "19.5"}}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.ID()).To(Equal("echo 19.5"))
+		})
+
+		It("applies the config", func() {
+			var err error
+			s, err = New("foo", config.SensorConfig{
+				Type: "exec", ID: "bme280", Command: []string{"echo", "21375"},
+				Format: "millidegrees", TimeoutSeconds: 5, PollSeconds: 300,
+			}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.ID()).To(Equal("bme280"))
+			temp, _ := s.Read()
+			Expect(temp).To(BeEquivalentTo(21375))
+
+			es := s.(*execSensor)
+			Expect(es.command.Timeout).To(Equal(5 * time.Second))
+			Expect(es.polling.Interval).To(Equal(5 * time.Minute))
+		})
+
+		It("defaults the timeout", func() {
+			var err error
+			s, err = New("foo", config.SensorConfig{Type: "exec", Command: []string{"echo", "19.5"}}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.(*execSensor).command.Timeout).To(Equal(defaultExecTimeout))
+		})
+
+		DescribeTable("invalid config",
+			func(cfg config.SensorConfig) {
+				cfg.Type = "exec"
+				_, err := New("foo", cfg, nil)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("missing command", config.SensorConfig{}),
+			Entry("empty program", config.SensorConfig{Command: []string{""}}),
+			Entry("unknown format", config.SensorConfig{Command: []string{"echo"}, Format: "fahrenheit"}),
+		)
+	})
+})
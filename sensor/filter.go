@@ -0,0 +1,172 @@
+package sensor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+// Smoothing is a strategy for smoothing the readings from a sensor.
+type Smoothing string
+
+const (
+	SmoothingNone          Smoothing = ""
+	SmoothingExponential   Smoothing = "exponential"
+	SmoothingMovingAverage Smoothing = "moving_average"
+)
+
+// W1ErrorValues are reported by a DS18B20 when a reading goes wrong: 85°C is
+// its power-on value, and -127°C means the device didn't respond.
+var W1ErrorValues = []units.Temperature{85000, -127000}
+
+const (
+	defaultSmoothingAlpha  = 0.5
+	defaultSmoothingWindow = 5
+)
+
+var (
+	errBadValue     = errors.New("known bad value")
+	errRateOfChange = errors.New("changing too fast")
+)
+
+// Filter configures the rejection of spurious readings from a sensor, and the
+// smoothing of the rest.
+type Filter struct {
+	RejectValues []units.Temperature
+	// Readings that differ from the last accepted reading by more than this
+	// per minute are rejected. Zero disables this.
+	MaxRate   units.Temperature
+	Smoothing Smoothing
+	// The weight given to each new reading with exponential smoothing.
+	Alpha float64
+	// The number of readings averaged with moving average smoothing.
+	Window int
+}
+
+func (f Filter) Valid() bool {
+	if f.MaxRate < 0 {
+		return false
+	}
+	switch f.Smoothing {
+	case SmoothingNone:
+		return true
+	case SmoothingExponential:
+		return f.Alpha > 0 && f.Alpha <= 1
+	case SmoothingMovingAverage:
+		return f.Window > 0
+	}
+	return false
+}
+
+// RejectedReadings counts the readings rejected by a sensor's filter.
+type RejectedReadings struct {
+	BadValue     uint64
+	RateOfChange uint64
+}
+
+// FilteredSensor is a sensor that filters its readings.
+type FilteredSensor interface {
+	Sensor
+	// Filter returns the filter, or nil if the sensor doesn't filter its
+	// readings.
+	Filter() *Filter
+	RejectedReadings() RejectedReadings
+}
+
+// filterFromConfig returns the filter given in the config, using the defaults
+// for any missing values. Returns nil if no filtering is configured.
+func filterFromConfig(cfg *config.FilterConfig, defaultRejectValues []units.Temperature) (*Filter, error) {
+	if cfg == nil {
+		if defaultRejectValues == nil {
+			return nil, nil
+		}
+		return &Filter{RejectValues: defaultRejectValues}, nil
+	}
+	f := &Filter{
+		RejectValues: cfg.RejectValues,
+		MaxRate:      cfg.MaxRatePerMinute,
+		Smoothing:    Smoothing(cfg.Smoothing),
+		Alpha:        cfg.Alpha,
+		Window:       cfg.Window,
+	}
+	if f.RejectValues == nil {
+		f.RejectValues = defaultRejectValues
+	}
+	if f.Alpha == 0 {
+		f.Alpha = defaultSmoothingAlpha
+	}
+	if f.Window == 0 {
+		f.Window = defaultSmoothingWindow
+	}
+	if !f.Valid() {
+		return nil, fmt.Errorf("Invalid filter: %+v", *cfg)
+	}
+	return f, nil
+}
+
+// readingFilter applies a Filter to a sequence of readings. It's not safe for
+// concurrent use.
+type readingFilter struct {
+	Filter
+	rejected RejectedReadings
+
+	lastRaw  units.Temperature
+	lastAt   time.Time
+	smoothed float64
+	window   []units.Temperature
+}
+
+func newReadingFilter(f *Filter) *readingFilter {
+	if f == nil {
+		return nil
+	}
+	return &readingFilter{Filter: *f}
+}
+
+// apply returns the filtered value for the given reading, or an error if the
+// reading is rejected.
+func (f *readingFilter) apply(raw units.Temperature, at time.Time) (units.Temperature, error) {
+	for _, v := range f.RejectValues {
+		if raw == v {
+			f.rejected.BadValue++
+			return 0, errBadValue
+		}
+	}
+	if f.MaxRate > 0 && !f.lastAt.IsZero() {
+		elapsed := at.Sub(f.lastAt)
+		if elapsed < time.Minute {
+			elapsed = time.Minute
+		}
+		if math.Abs(float64(raw-f.lastRaw)) > float64(f.MaxRate)*elapsed.Minutes() {
+			f.rejected.RateOfChange++
+			return 0, errRateOfChange
+		}
+	}
+	first := f.lastAt.IsZero()
+	f.lastRaw, f.lastAt = raw, at
+
+	switch f.Smoothing {
+	case SmoothingExponential:
+		if first {
+			f.smoothed = float64(raw)
+		} else {
+			f.smoothed = f.Alpha*float64(raw) + (1-f.Alpha)*f.smoothed
+		}
+		return units.Temperature(math.Round(f.smoothed)), nil
+	case SmoothingMovingAverage:
+		f.window = append(f.window, raw)
+		if len(f.window) > f.Window {
+			f.window = f.window[len(f.window)-f.Window:]
+		}
+		var sum float64
+		for _, t := range f.window {
+			sum += float64(t)
+		}
+		return units.Temperature(math.Round(sum / float64(len(f.window)))), nil
+	}
+	return raw, nil
+}
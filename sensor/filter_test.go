@@ -0,0 +1,117 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("filtering sensor readings", func() {
+	var (
+		s   SettableSensor
+		now time.Time
+	)
+
+	newFilteredSensor := func(cfg *config.FilterConfig) {
+		var err error
+		ss, err := New("foo", config.SensorConfig{Type: "push", ID: "1234", Filter: cfg}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		s = ss.(SettableSensor)
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+	})
+
+	It("doesn't filter a push sensor by default", func() {
+		newFilteredSensor(nil)
+		Expect(s.(FilteredSensor).Filter()).To(BeNil())
+		s.Set(85000, now)
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(85000))
+	})
+
+	It("rejects known bad values, counting them", func() {
+		newFilteredSensor(&config.FilterConfig{RejectValues: []units.Temperature{85000, -127000}})
+		s.Set(19000, now)
+		s.Set(85000, now.Add(time.Minute))
+		s.Set(-127000, now.Add(2*time.Minute))
+
+		temp, updatedAt := s.Read()
+		Expect(temp).To(BeEquivalentTo(19000))
+		Expect(updatedAt).To(Equal(now))
+		Expect(s.(FilteredSensor).RejectedReadings()).To(Equal(RejectedReadings{BadValue: 2}))
+	})
+
+	It("rejects readings changing faster than the maximum rate", func() {
+		newFilteredSensor(&config.FilterConfig{MaxRatePerMinute: 1000})
+		s.Set(19000, now)
+		s.Set(20500, now.Add(time.Minute))
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(19000))
+
+		// The allowed change grows with the time since the last accepted
+		// reading.
+		s.Set(20500, now.Add(2*time.Minute))
+		temp, _ = s.Read()
+		Expect(temp).To(BeEquivalentTo(20500))
+		Expect(s.(FilteredSensor).RejectedReadings()).To(Equal(RejectedReadings{RateOfChange: 1}))
+	})
+
+	It("doesn't notify subscribers of rejected readings", func() {
+		newFilteredSensor(&config.FilterConfig{RejectValues: []units.Temperature{85000}})
+		ch := s.Subscribe().C
+		s.Set(85000, now)
+		Consistently(ch).ShouldNot(Receive())
+	})
+
+	DescribeTable("smoothing the readings",
+		func(cfg *config.FilterConfig, expected []int) {
+			newFilteredSensor(cfg)
+			for i, raw := range []units.Temperature{19000, 20000, 21000, 19000} {
+				s.Set(raw, now.Add(time.Duration(i)*time.Minute))
+				temp, _ := s.Read()
+				Expect(temp).To(BeEquivalentTo(expected[i]))
+			}
+			Expect(s.(CalibratedSensor).Raw()).To(BeEquivalentTo(19000))
+		},
+		Entry("exponential", &config.FilterConfig{Smoothing: "exponential", Alpha: 0.5}, []int{19000, 19500, 20250, 19625}),
+		Entry("moving average", &config.FilterConfig{Smoothing: "moving_average", Window: 3}, []int{19000, 19500, 20000, 20000}),
+	)
+
+	It("calibrates the smoothed value", func() {
+		newFilteredSensor(&config.FilterConfig{Smoothing: "moving_average", Window: 2})
+		s.Set(19000, now)
+		s.Set(20000, now.Add(time.Minute))
+		s.(CalibratedSensor).SetCalibration(Calibration{Offset: -500})
+		temp, _ := s.Read()
+		Expect(temp).To(BeEquivalentTo(19000))
+	})
+
+	It("uses the defaults for missing smoothing values", func() {
+		newFilteredSensor(&config.FilterConfig{Smoothing: "moving_average"})
+		Expect(s.(FilteredSensor).Filter().Window).To(Equal(defaultSmoothingWindow))
+	})
+
+	It("defaults to rejecting the DS18B20 error values for w1 sensors", func() {
+		f, err := filterFromConfig(&config.FilterConfig{MaxRatePerMinute: 500}, W1ErrorValues)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.RejectValues).To(Equal(W1ErrorValues))
+
+		f, err = filterFromConfig(&config.FilterConfig{RejectValues: []units.Temperature{}}, W1ErrorValues)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.RejectValues).To(BeEmpty())
+	})
+
+	It("errors with an invalid filter", func() {
+		_, err := New("foo", config.SensorConfig{Type: "push", Filter: &config.FilterConfig{Smoothing: "fuzzy"}}, nil)
+		Expect(err).To(HaveOccurred())
+		_, err = New("foo", config.SensorConfig{Type: "push", Filter: &config.FilterConfig{Smoothing: "exponential", Alpha: 1.5}}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,272 @@
+package sensor
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+// Reading is a single temperature reading from a sensor.
+type Reading struct {
+	Temperature units.Temperature `json:"temperature"`
+	At          time.Time         `json:"at"`
+}
+
+// Retention configures how long a sensor's history is kept.
+type Retention struct {
+	// Readings are kept at their native resolution for this long.
+	Native time.Duration
+	// Older readings are averaged over intervals of this length...
+	Step time.Duration
+	// ...and kept until they're this old.
+	Downsampled time.Duration
+}
+
+var DefaultRetention = Retention{
+	Native:      48 * time.Hour,
+	Step:        15 * time.Minute,
+	Downsampled: 14 * 24 * time.Hour,
+}
+
+func (r Retention) Valid() bool {
+	return r.Native > 0 && r.Step > 0 && r.Downsampled >= r.Native
+}
+
+// HistorySensor is a sensor that keeps a history of its readings.
+type HistorySensor interface {
+	Sensor
+	History() *History
+}
+
+// History holds the recent readings of a sensor, downsampling the older
+// ones. It's safe for concurrent use.
+type History struct {
+	lock        sync.RWMutex
+	retention   Retention
+	native      readingRing
+	downsampled readingRing
+	// The readings being averaged into the next downsampled reading.
+	pending      time.Time
+	pendingSum   float64
+	pendingCount int
+}
+
+func NewHistory(r Retention) *History {
+	return &History{retention: r}
+}
+
+func (h *History) Retention() Retention {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.retention
+}
+
+// SetRetention changes the retention, which applies from the next reading.
+func (h *History) SetRetention(r Retention) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.retention = r
+}
+
+// Add records a reading. Readings older than the latest one are ignored.
+func (h *History) Add(r Reading) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.add(r)
+}
+
+// Restore replaces the history with the given readings (e.g. from before a
+// restart), followed by any readings already held that are newer.
+func (h *History) Restore(readings []Reading) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	current := h.readings()
+	h.native, h.downsampled = readingRing{}, readingRing{}
+	h.pendingSum, h.pendingCount = 0, 0
+	for _, r := range readings {
+		h.add(r)
+	}
+	for _, r := range current {
+		h.add(r)
+	}
+}
+
+// Must be called with the lock held.
+func (h *History) add(r Reading) {
+	if last, ok := h.native.last(); ok && r.At.Before(last.At) {
+		return
+	}
+	h.native.push(r)
+
+	for {
+		oldest, ok := h.native.first()
+		if !ok || r.At.Sub(oldest.At) <= h.retention.Native {
+			break
+		}
+		h.native.shift()
+		h.downsample(oldest)
+	}
+	for {
+		oldest, ok := h.downsampled.first()
+		if !ok || r.At.Sub(oldest.At) <= h.retention.Downsampled {
+			break
+		}
+		h.downsampled.shift()
+	}
+}
+
+// Must be called with the lock held.
+func (h *History) downsample(r Reading) {
+	bucket := r.At.Truncate(h.retention.Step)
+	if h.pendingCount > 0 && !bucket.Equal(h.pending) {
+		h.downsampled.push(h.pendingReading())
+		h.pendingSum, h.pendingCount = 0, 0
+	}
+	h.pending = bucket
+	h.pendingSum += float64(r.Temperature)
+	h.pendingCount++
+}
+
+// Must be called with the lock held, and pendingCount > 0.
+func (h *History) pendingReading() Reading {
+	return Reading{
+		Temperature: units.Temperature(math.Round(h.pendingSum / float64(h.pendingCount))),
+		At:          h.pending,
+	}
+}
+
+// Readings returns all the readings held, oldest first.
+func (h *History) Readings() []Reading {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.readings()
+}
+
+// Must be called with the lock held.
+func (h *History) readings() []Reading {
+	readings := make([]Reading, 0, h.downsampled.size+h.native.size+1)
+	readings = h.downsampled.appendTo(readings)
+	if h.pendingCount > 0 {
+		readings = append(readings, h.pendingReading())
+	}
+	return h.native.appendTo(readings)
+}
+
+// Query returns the readings taken between from and to inclusive, oldest
+// first. If step is non-zero, the readings are averaged over intervals of
+// that length starting at from, with each result timed at the start of its
+// interval. Intervals without any readings are omitted.
+func (h *History) Query(from, to time.Time, step time.Duration) []Reading {
+	var readings []Reading
+	for _, r := range h.Readings() {
+		if r.At.Before(from) || r.At.After(to) {
+			continue
+		}
+		readings = append(readings, r)
+	}
+	if step <= 0 || len(readings) == 0 {
+		return readings
+	}
+
+	var (
+		result []Reading
+		bucket time.Time
+		sum    float64
+		count  int
+	)
+	for _, r := range readings {
+		b := from.Add(r.At.Sub(from) / step * step)
+		if count > 0 && !b.Equal(bucket) {
+			result = append(result, Reading{Temperature: units.Temperature(math.Round(sum / float64(count))), At: bucket})
+			sum, count = 0, 0
+		}
+		bucket = b
+		sum += float64(r.Temperature)
+		count++
+	}
+	return append(result, Reading{Temperature: units.Temperature(math.Round(sum / float64(count))), At: bucket})
+}
+
+// retentionFromConfig returns the retention given in the config, using the
+// defaults for any missing values.
+func retentionFromConfig(cfg *config.HistoryConfig) (Retention, error) {
+	r := DefaultRetention
+	if cfg == nil {
+		return r, nil
+	}
+	if cfg.Hours > 0 {
+		r.Native = time.Duration(cfg.Hours) * time.Hour
+	}
+	if cfg.DownsampleMinutes > 0 {
+		r.Step = time.Duration(cfg.DownsampleMinutes) * time.Minute
+	}
+	if cfg.DownsampledDays > 0 {
+		r.Downsampled = time.Duration(cfg.DownsampledDays) * 24 * time.Hour
+	}
+	if r.Downsampled < r.Native {
+		r.Downsampled = r.Native
+	}
+	if !r.Valid() {
+		return r, fmt.Errorf("Invalid history: %+v", *cfg)
+	}
+	return r, nil
+}
+
+// readingRing is a FIFO queue of readings held in a ring buffer, which grows
+// as needed.
+type readingRing struct {
+	buf   []Reading
+	start int
+	size  int
+}
+
+func (q *readingRing) push(r Reading) {
+	if q.size == len(q.buf) {
+		q.grow()
+	}
+	q.buf[(q.start+q.size)%len(q.buf)] = r
+	q.size++
+}
+
+func (q *readingRing) grow() {
+	n := len(q.buf) * 2
+	if n == 0 {
+		n = 64
+	}
+	buf := make([]Reading, n)
+	q.appendTo(buf[:0])
+	q.buf = buf
+	q.start = 0
+}
+
+func (q *readingRing) shift() {
+	q.start = (q.start + 1) % len(q.buf)
+	q.size--
+}
+
+func (q *readingRing) first() (Reading, bool) {
+	if q.size == 0 {
+		return Reading{}, false
+	}
+	return q.buf[q.start], true
+}
+
+func (q *readingRing) last() (Reading, bool) {
+	if q.size == 0 {
+		return Reading{}, false
+	}
+	return q.buf[(q.start+q.size-1)%len(q.buf)], true
+}
+
+// appendTo appends the readings to s in order.
+func (q *readingRing) appendTo(s []Reading) []Reading {
+	if q.start+q.size <= len(q.buf) {
+		return append(s, q.buf[q.start:q.start+q.size]...)
+	}
+	s = append(s, q.buf[q.start:]...)
+	return append(s, q.buf[:(q.start+q.size)%len(q.buf)]...)
+}
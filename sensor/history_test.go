@@ -0,0 +1,159 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("sensor history", func() {
+	var (
+		h     *History
+		start time.Time
+	)
+
+	BeforeEach(func() {
+		start = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		h = NewHistory(Retention{Native: time.Hour, Step: 15 * time.Minute, Downsampled: 3 * time.Hour})
+	})
+
+	// addEvery adds n readings at the given interval from start, with the
+	// temperature going up by 10 each time from base.
+	var addEvery = func(interval time.Duration, n int, base units.Temperature) {
+		for i := 0; i < n; i++ {
+			h.Add(Reading{Temperature: base + units.Temperature(i*10), At: start.Add(time.Duration(i) * interval)})
+		}
+	}
+
+	It("holds the readings in order", func() {
+		addEvery(time.Minute, 3, 19000)
+		Expect(h.Readings()).To(Equal([]Reading{
+			{Temperature: 19000, At: start},
+			{Temperature: 19010, At: start.Add(time.Minute)},
+			{Temperature: 19020, At: start.Add(2 * time.Minute)},
+		}))
+	})
+
+	It("ignores readings older than the latest one", func() {
+		addEvery(time.Minute, 2, 19000)
+		h.Add(Reading{Temperature: 25000, At: start.Add(30 * time.Second)})
+		Expect(h.Readings()).To(HaveLen(2))
+	})
+
+	It("keeps the readings in order as the buffer wraps and grows", func() {
+		// The native readings wrap around the buffer as old ones are
+		// downsampled, and then grow it when the interval shortens.
+		addEvery(time.Minute, 200, 0)
+		for i := 0; i < 200; i++ {
+			h.Add(Reading{Temperature: 5000, At: start.Add(200*time.Minute + time.Duration(i)*10*time.Second)})
+		}
+		readings := h.Readings()
+		for i := 1; i < len(readings); i++ {
+			Expect(readings[i].At.After(readings[i-1].At)).To(BeTrue())
+		}
+	})
+
+	It("downsamples readings older than the native retention", func() {
+		addEvery(time.Minute, 91, 19000)
+		readings := h.Readings()
+
+		// Readings from 12:00-12:29 are averaged into two 15 minute steps,
+		// with 12:30 onwards at their native resolution.
+		Expect(readings[0]).To(Equal(Reading{Temperature: 19070, At: start}))
+		Expect(readings[1]).To(Equal(Reading{Temperature: 19220, At: start.Add(15 * time.Minute)}))
+		Expect(readings[2]).To(Equal(Reading{Temperature: 19300, At: start.Add(30 * time.Minute)}))
+		Expect(readings).To(HaveLen(63))
+	})
+
+	It("drops downsampled readings older than the downsampled retention", func() {
+		addEvery(time.Minute, 5*60, 0)
+		readings := h.Readings()
+		Expect(readings[0].At).To(Equal(start.Add(2*time.Hour - time.Minute).Truncate(15 * time.Minute).Add(15 * time.Minute)))
+		Expect(readings[len(readings)-1].At).To(Equal(start.Add(5*time.Hour - time.Minute)))
+	})
+
+	Describe("querying", func() {
+		BeforeEach(func() {
+			addEvery(time.Minute, 30, 19000)
+		})
+
+		It("returns the readings within the range inclusive", func() {
+			readings := h.Query(start.Add(10*time.Minute), start.Add(12*time.Minute), 0)
+			Expect(readings).To(Equal([]Reading{
+				{Temperature: 19100, At: start.Add(10 * time.Minute)},
+				{Temperature: 19110, At: start.Add(11 * time.Minute)},
+				{Temperature: 19120, At: start.Add(12 * time.Minute)},
+			}))
+		})
+
+		It("averages the readings over each step from the start", func() {
+			readings := h.Query(start.Add(5*time.Minute), start.Add(time.Hour), 10*time.Minute)
+			Expect(readings).To(Equal([]Reading{
+				{Temperature: 19095, At: start.Add(5 * time.Minute)},
+				{Temperature: 19195, At: start.Add(15 * time.Minute)},
+				{Temperature: 19270, At: start.Add(25 * time.Minute)},
+			}))
+		})
+
+		It("returns nothing outside the readings held", func() {
+			Expect(h.Query(start.Add(-time.Hour), start.Add(-time.Minute), time.Minute)).To(BeEmpty())
+		})
+	})
+
+	It("restores saved readings before any already held", func() {
+		h.Add(Reading{Temperature: 20000, At: start.Add(10 * time.Minute)})
+		h.Restore([]Reading{
+			{Temperature: 19000, At: start},
+			{Temperature: 19500, At: start.Add(5 * time.Minute)},
+			{Temperature: 25000, At: start.Add(15 * time.Minute)},
+		})
+		Expect(h.Readings()).To(Equal([]Reading{
+			{Temperature: 19000, At: start},
+			{Temperature: 19500, At: start.Add(5 * time.Minute)},
+			{Temperature: 25000, At: start.Add(15 * time.Minute)},
+		}))
+	})
+
+	Describe("sensors", func() {
+		It("record their readings", func() {
+			s := NewPushSensor("foo", "1234")
+			s.Set(19000, start)
+			s.Set(19500, start.Add(time.Minute))
+			Expect(s.(HistorySensor).History().Readings()).To(Equal([]Reading{
+				{Temperature: 19000, At: start},
+				{Temperature: 19500, At: start.Add(time.Minute)},
+			}))
+		})
+
+		It("record the calibrated value", func() {
+			s := NewPushSensor("foo", "1234")
+			s.(CalibratedSensor).SetCalibration(Calibration{Offset: -500})
+			s.Set(19000, start)
+			Expect(s.(HistorySensor).History().Readings()).To(Equal([]Reading{{Temperature: 18500, At: start}}))
+		})
+
+		It("use the default retention unless configured", func() {
+			s, err := New("foo", config.SensorConfig{Type: "push"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.(HistorySensor).History().Retention()).To(Equal(DefaultRetention))
+
+			s, err = New("foo", config.SensorConfig{Type: "push", History: &config.HistoryConfig{Hours: 6, DownsampleMinutes: 5, DownsampledDays: 2}}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.(HistorySensor).History().Retention()).To(Equal(Retention{
+				Native:      6 * time.Hour,
+				Step:        5 * time.Minute,
+				Downsampled: 48 * time.Hour,
+			}))
+		})
+
+		It("keep downsampled readings at least as long as the native ones", func() {
+			s, err := New("foo", config.SensorConfig{Type: "push", History: &config.HistoryConfig{Hours: 24 * 30}}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.(HistorySensor).History().Retention().Downsampled).To(Equal(30 * 24 * time.Hour))
+		})
+	})
+})
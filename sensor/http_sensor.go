@@ -0,0 +1,188 @@
+package sensor
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+const (
+	defaultHTTPTimeout     = 10 * time.Second
+	defaultHTTPMaxFailures = 3
+	maxHTTPBodySize        = 64 * 1024
+)
+
+// HTTPSource configures where an http sensor reads from.
+type HTTPSource struct {
+	URL      string
+	Username string
+	Password string
+	Timeout  time.Duration
+	// The dot-separated path to the temperature in °C in a JSON response.
+	JSONPath string
+	// Matched against the response, with the first group being the
+	// temperature in °C. Takes precedence over JSONPath.
+	Regexp *regexp.Regexp
+	// The number of consecutive failed reads after which the sensor is stale.
+	MaxFailures int
+}
+
+type httpSensor struct {
+	baseSensor
+	source   HTTPSource
+	jsonPath []string
+	client   *http.Client
+	polling  Polling
+	errors   ReadErrors
+	failures int
+	closeCh  chan struct{}
+}
+
+// NewHTTPSensor returns a sensor that polls a URL for the temperature.
+func NewHTTPSensor(name, id string, source HTTPSource, polling Polling) Sensor {
+	return newHTTPSensor(name, id, source, nil, polling)
+}
+
+func newHTTPSensor(name, id string, source HTTPSource, f *Filter, polling Polling) Sensor {
+	if source.Timeout == 0 {
+		source.Timeout = defaultHTTPTimeout
+	}
+	if source.MaxFailures == 0 {
+		source.MaxFailures = defaultHTTPMaxFailures
+	}
+	s := &httpSensor{
+		baseSensor: newBaseSensor(name, id),
+		source:     source,
+		client: &http.Client{
+			Timeout: source.Timeout,
+			// A transport of its own, so that closing the sensor can close
+			// its idle connections.
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
+		polling: polling,
+		closeCh: make(chan struct{}),
+	}
+	if source.JSONPath != "" {
+		s.jsonPath = strings.Split(source.JSONPath, ".")
+	}
+	s.filter = newReadingFilter(f)
+	s.readTemperature(timeNow())
+	go s.polling.loop(s.closeCh, s.readTemperature)
+	return s
+}
+
+func (s *httpSensor) Close() {
+	s.close(s.stopPolling)
+}
+
+func (s *httpSensor) stopPolling() {
+	s.closeCh <- struct{}{}
+	<-s.closeCh
+	s.client.CloseIdleConnections()
+}
+
+func (s *httpSensor) ReadErrors() ReadErrors {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.errors
+}
+
+// Stale returns whether the last MaxFailures reads have all failed.
+func (s *httpSensor) Stale() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.failures >= s.source.MaxFailures
+}
+
+func (s *httpSensor) readTemperature(updateTime time.Time) {
+	temp, err := s.fetch()
+	if err != nil {
+		s.recordError(err)
+		return
+	}
+	s.lock.Lock()
+	if s.failures >= s.source.MaxFailures {
+		log.Printf("[Sensor:%s] Readings resumed", s.name)
+	}
+	s.failures = 0
+	s.lock.Unlock()
+	s.set(temp, updateTime)
+}
+
+func (s *httpSensor) recordError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.errors.ReadFailures++
+	s.errors.LastError = err.Error()
+	s.errors.LastErrorAt = timeNow()
+	s.failures++
+	log.Printf("[Sensor:%s] Error reading %s: %s", s.name, s.source.URL, err.Error())
+	if s.failures == s.source.MaxFailures {
+		log.Printf("[Sensor:%s] Stale after %d consecutive failures", s.name, s.failures)
+	}
+}
+
+func (s *httpSensor) fetch() (units.Temperature, error) {
+	req, err := http.NewRequest("GET", s.source.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if s.source.Username != "" {
+		req.SetBasicAuth(s.source.Username, s.source.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Unexpected response status: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBodySize))
+	if err != nil {
+		return 0, err
+	}
+
+	if s.source.Regexp != nil {
+		matches := s.source.Regexp.FindSubmatch(body)
+		if len(matches) < 2 {
+			return 0, fmt.Errorf("Failed to match temperature in response: %q", body)
+		}
+		return parseTemperature(matches[1], nil)
+	}
+	return parseTemperature(body, s.jsonPath)
+}
+
+// httpSourceFromConfig returns the source given in the config, using the
+// defaults for any missing values.
+func httpSourceFromConfig(cfg config.SensorConfig) (HTTPSource, error) {
+	source := HTTPSource{
+		URL:         cfg.URL,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		Timeout:     time.Duration(cfg.TimeoutSeconds) * time.Second,
+		JSONPath:    cfg.JSONPath,
+		MaxFailures: cfg.MaxFailures,
+	}
+	if source.URL == "" {
+		return source, fmt.Errorf("Missing URL")
+	}
+	if cfg.Regexp != "" {
+		re, err := regexp.Compile(cfg.Regexp)
+		if err != nil {
+			return source, err
+		}
+		if re.NumSubexp() < 1 {
+			return source, fmt.Errorf("Regexp '%s' has no group to match the temperature", cfg.Regexp)
+		}
+		source.Regexp = re
+	}
+	return source, nil
+}
@@ -0,0 +1,206 @@
+package sensor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/ticker"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("an http sensor", func() {
+	var (
+		server    *httptest.Server
+		status    int
+		body      string
+		lastReq   *http.Request
+		tkr       *dummyTicker
+		tkrNotify chan struct{}
+		s         Sensor
+		now       time.Time
+	)
+
+	BeforeEach(func() {
+		status, body, lastReq = http.StatusOK, "19.5", nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lastReq = r
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+		now = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+
+		tkrNotify = make(chan struct{}, 1)
+		newTicker = func(d time.Duration) ticker.Ticker {
+			tkr = &dummyTicker{
+				duration: d,
+				C:        make(chan time.Time, 1),
+				notify:   tkrNotify,
+			}
+			return tkr
+		}
+	})
+
+	AfterEach(func() {
+		if s != nil {
+			s.Close()
+		}
+		s = nil
+		server.Close()
+		timeNow = time.Now
+	})
+
+	// tick triggers a poll, and waits for the loop to come round again so that
+	// the read has completed. The loop must already be waiting.
+	var tick = func(t time.Time) {
+		tkr.C <- t
+		<-tkrNotify
+	}
+
+	It("reads the temperature on construction", func() {
+		s = NewHTTPSensor("foo", "bar", HTTPSource{URL: server.URL}, DefaultPolling)
+		temp, updatedAt := s.Read()
+		Expect(temp).To(BeEquivalentTo(19500))
+		Expect(updatedAt).To(Equal(now))
+	})
+
+	It("polls at the given interval", func() {
+		s = NewHTTPSensor("foo", "bar", HTTPSource{URL: server.URL}, Polling{Interval: 30 * time.Second})
+		<-tkrNotify
+		Expect(tkr.duration).To(Equal(30 * time.Second))
+
+		body = "20.25"
+		tick(now.Add(30 * time.Second))
+		temp, updatedAt := s.Read()
+		Expect(temp).To(BeEquivalentTo(20250))
+		Expect(updatedAt).To(Equal(now.Add(30 * time.Second)))
+	})
+
+	DescribeTable("extracting the temperature",
+		func(jsonPath, pattern, response string, expected int) {
+			body = response
+			source := HTTPSource{URL: server.URL, JSONPath: jsonPath}
+			if pattern != "" {
+				source.Regexp = regexp.MustCompile(pattern)
+			}
+			s = NewHTTPSensor("foo", "bar", source, DefaultPolling)
+			temp, _ := s.Read()
+			Expect(temp).To(BeEquivalentTo(expected))
+		},
+		Entry("raw response", "", "", "19.5\n", 19500),
+		Entry("JSON path", "sensors.lounge.temp", "", `{"sensors":{"lounge":{"temp":21.37}}}`, 21370),
+		Entry("regexp", "", `Temperature: ([-0-9.]+)`, "<p>Temperature: -3.5&deg;C</p>", -3500),
+	)
+
+	It("sends basic auth credentials when given", func() {
+		s = NewHTTPSensor("foo", "bar", HTTPSource{URL: server.URL, Username: "user", Password: "secret"}, DefaultPolling)
+		username, password, ok := lastReq.BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(username).To(Equal("user"))
+		Expect(password).To(Equal("secret"))
+	})
+
+	Describe("failed reads", func() {
+		It("counts errors for non-200 responses", func() {
+			status = http.StatusServiceUnavailable
+			s = NewHTTPSensor("foo", "bar", HTTPSource{URL: server.URL}, DefaultPolling)
+			_, updatedAt := s.Read()
+			Expect(updatedAt.IsZero()).To(BeTrue())
+			errs := s.(ErrorCountingSensor).ReadErrors()
+			Expect(errs.ReadFailures).To(BeEquivalentTo(1))
+			Expect(errs.LastError).To(ContainSubstring("503"))
+			Expect(errs.LastErrorAt).To(Equal(now))
+		})
+
+		It("counts errors for unparseable responses", func() {
+			body = "<html>oops</html>"
+			s = NewHTTPSensor("foo", "bar", HTTPSource{URL: server.URL}, DefaultPolling)
+			Expect(s.(ErrorCountingSensor).ReadErrors().ReadFailures).To(BeEquivalentTo(1))
+		})
+
+		It("times out slow responses", func() {
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+				w.Write([]byte("19.5"))
+			}))
+			defer slow.Close()
+			s = NewHTTPSensor("foo", "bar", HTTPSource{URL: slow.URL, Timeout: 20 * time.Millisecond}, DefaultPolling)
+			_, updatedAt := s.Read()
+			Expect(updatedAt.IsZero()).To(BeTrue())
+			Expect(s.(ErrorCountingSensor).ReadErrors().LastError).To(ContainSubstring("Timeout"))
+		})
+
+		It("becomes stale after MaxFailures consecutive failures, and recovers on success", func() {
+			s = NewHTTPSensor("foo", "bar", HTTPSource{URL: server.URL, MaxFailures: 2}, DefaultPolling)
+			Expect(s.(StaleReporter).Stale()).To(BeFalse())
+			<-tkrNotify
+
+			status = http.StatusInternalServerError
+			tick(now.Add(time.Minute))
+			Expect(s.(StaleReporter).Stale()).To(BeFalse())
+			tick(now.Add(2 * time.Minute))
+			Expect(s.(StaleReporter).Stale()).To(BeTrue())
+
+			temp, _ := s.Read()
+			Expect(temp).To(BeEquivalentTo(19500))
+
+			status = http.StatusOK
+			tick(now.Add(3 * time.Minute))
+			Expect(s.(StaleReporter).Stale()).To(BeFalse())
+		})
+	})
+
+	Describe("constructing from config", func() {
+		It("uses the URL as the ID unless one is given", func() {
+			var err error
+			s, err = New("foo", config.SensorConfig{Type: "http", URL: server.URL}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.ID()).To(Equal(server.URL))
+		})
+
+		It("applies the config", func() {
+			body = `{"t":"18.5"}`
+			var err error
+			s, err = New("foo", config.SensorConfig{
+				Type: "http", ID: "outside", URL: server.URL, JSONPath: "t",
+				TimeoutSeconds: 5, MaxFailures: 4, PollSeconds: 300,
+			}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.ID()).To(Equal("outside"))
+			temp, _ := s.Read()
+			Expect(temp).To(Equal(units.Temperature(18500)))
+
+			hs := s.(*httpSensor)
+			Expect(hs.source.Timeout).To(Equal(5 * time.Second))
+			Expect(hs.source.MaxFailures).To(Equal(4))
+			Expect(hs.polling.Interval).To(Equal(5 * time.Minute))
+		})
+
+		It("defaults the timeout and max failures", func() {
+			var err error
+			s, err = New("foo", config.SensorConfig{Type: "http", URL: server.URL}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			hs := s.(*httpSensor)
+			Expect(hs.source.Timeout).To(Equal(defaultHTTPTimeout))
+			Expect(hs.source.MaxFailures).To(Equal(defaultHTTPMaxFailures))
+		})
+
+		DescribeTable("invalid config",
+			func(cfg config.SensorConfig) {
+				cfg.Type = "http"
+				_, err := New("foo", cfg, nil)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("missing URL", config.SensorConfig{}),
+			Entry("bad regexp", config.SensorConfig{URL: "http://example.com", Regexp: "([0-9"}),
+			Entry("regexp without a group", config.SensorConfig{URL: "http://example.com", Regexp: "[0-9.]+"}),
+		)
+	})
+})
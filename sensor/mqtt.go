@@ -0,0 +1,162 @@
+package sensor
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/alext/heating-controller/config"
+)
+
+const defaultMQTTClientID = "heating-controller"
+
+var errMQTTNotConfigured = errors.New("No MQTT broker configured")
+
+// mqttSubscriber is the part of an MQTT connection used by mqtt sensors.
+type mqttSubscriber interface {
+	// Subscribe calls the handler with the payload of each message published
+	// to the topic until the returned function is called.
+	Subscribe(topic string, handler func(payload []byte)) (unsubscribe func())
+}
+
+// The connection shared by all mqtt sensors, set up by ConnectMQTT.
+var mqttBroker mqttSubscriber
+
+// How long to wait between attempts to make the initial connection. Once
+// connected, the client reconnects automatically if the connection is lost.
+var mqttConnectRetryInterval = 10 * time.Second
+
+// ConnectMQTT connects to the given MQTT broker in the background, retrying
+// until it succeeds. This must be done before creating any mqtt sensors, which
+// receive messages once the connection is made.
+func ConnectMQTT(cfg *config.MQTTConfig) {
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = defaultMQTTClientID
+	}
+	c := &mqttConnection{handlers: make(map[string][]*mqttHandler)}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("[mqtt] Connection lost: %s", err.Error())
+		})
+	c.client = mqtt.NewClient(opts)
+	mqttBroker = c
+	go c.connect(cfg.Broker)
+}
+
+type mqttConnection struct {
+	client   mqtt.Client
+	lock     sync.Mutex
+	handlers map[string][]*mqttHandler
+}
+
+// mqttHandler wraps a handler function so that it can be found again to
+// remove it.
+type mqttHandler struct {
+	handle func([]byte)
+}
+
+// connect makes the initial connection to the broker, retrying until it
+// succeeds.
+func (c *mqttConnection) connect(broker string) {
+	for {
+		token := c.client.Connect()
+		token.Wait()
+		err := token.Error()
+		if err == nil {
+			return
+		}
+		log.Printf("[mqtt] Error connecting to %s, retrying in %s: %s", broker, mqttConnectRetryInterval, err.Error())
+		time.Sleep(mqttConnectRetryInterval)
+	}
+}
+
+func (c *mqttConnection) Subscribe(topic string, handler func([]byte)) func() {
+	h := &mqttHandler{handle: handler}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.handlers[topic] = append(c.handlers[topic], h)
+	if len(c.handlers[topic]) == 1 && c.client.IsConnected() {
+		c.subscribe(topic)
+	}
+	return func() { c.removeHandler(topic, h) }
+}
+
+// removeHandler removes the handler from the topic, unsubscribing from the
+// topic once it has no handlers left.
+func (c *mqttConnection) removeHandler(topic string, h *mqttHandler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	handlers := c.handlers[topic]
+	for i, existing := range handlers {
+		if existing != h {
+			continue
+		}
+		if len(handlers) > 1 {
+			// Copy rather than modifying the slice in place, as it may be
+			// being dispatched to.
+			c.handlers[topic] = append(handlers[:i:i], handlers[i+1:]...)
+			return
+		}
+		delete(c.handlers, topic)
+		if c.client.IsConnected() {
+			c.unsubscribe(topic)
+		}
+		return
+	}
+}
+
+// onConnect (re)subscribes to all the topics, as subscriptions don't survive
+// reconnecting with a clean session.
+func (c *mqttConnection) onConnect(_ mqtt.Client) {
+	log.Printf("[mqtt] Connected")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for topic := range c.handlers {
+		c.subscribe(topic)
+	}
+}
+
+// Must be called with the lock held.
+func (c *mqttConnection) subscribe(topic string) {
+	token := c.client.Subscribe(topic, 0, func(_ mqtt.Client, m mqtt.Message) {
+		c.dispatch(topic, m.Payload())
+	})
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Printf("[mqtt] Error subscribing to %s: %s", topic, err.Error())
+		}
+	}()
+}
+
+// Must be called with the lock held.
+func (c *mqttConnection) unsubscribe(topic string) {
+	token := c.client.Unsubscribe(topic)
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Printf("[mqtt] Error unsubscribing from %s: %s", topic, err.Error())
+		}
+	}()
+}
+
+// dispatch passes the payload of a message received on the topic to its
+// handlers.
+func (c *mqttConnection) dispatch(topic string, payload []byte) {
+	c.lock.Lock()
+	handlers := c.handlers[topic]
+	c.lock.Unlock()
+	for _, h := range handlers {
+		h.handle(payload)
+	}
+}
@@ -0,0 +1,50 @@
+package sensor
+
+import (
+	"log"
+	"strings"
+)
+
+type mqttSensor struct {
+	baseSensor
+	jsonPath    []string
+	unsubscribe func()
+}
+
+// NewMQTTSensor returns a sensor that's updated from messages published to the
+// given topic. The temperature in °C is extracted from the JSON payload using
+// the given dot-separated path (e.g. "state.temperature"), or the payload is
+// used as is if the path is empty.
+func NewMQTTSensor(name, id, topic, jsonPath string) (Sensor, error) {
+	if mqttBroker == nil {
+		return nil, errMQTTNotConfigured
+	}
+	s := &mqttSensor{
+		baseSensor: newBaseSensor(name, id),
+	}
+	if jsonPath != "" {
+		s.jsonPath = strings.Split(jsonPath, ".")
+	}
+	s.unsubscribe = mqttBroker.Subscribe(topic, s.handleMessage)
+	return s, nil
+}
+
+// Close stops following the topic, and closes the subscriptions.
+func (s *mqttSensor) Close() {
+	s.close(s.unsubscribe)
+}
+
+func (s *mqttSensor) handleMessage(payload []byte) {
+	s.lock.RLock()
+	closed := s.closed
+	s.lock.RUnlock()
+	if closed {
+		return
+	}
+	temp, err := parseTemperature(payload, s.jsonPath)
+	if err != nil {
+		log.Printf("[Sensor:%s] Error parsing message: %s", s.name, err.Error())
+		return
+	}
+	s.set(temp, timeNow())
+}
@@ -0,0 +1,121 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+type fakeMQTTBroker struct {
+	handlers map[string][]*mqttHandler
+}
+
+func (b *fakeMQTTBroker) Subscribe(topic string, handler func([]byte)) func() {
+	h := &mqttHandler{handle: handler}
+	b.handlers[topic] = append(b.handlers[topic], h)
+	return func() {
+		for i, existing := range b.handlers[topic] {
+			if existing == h {
+				b.handlers[topic] = append(b.handlers[topic][:i], b.handlers[topic][i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (b *fakeMQTTBroker) publish(topic, payload string) {
+	for _, h := range b.handlers[topic] {
+		h.handle([]byte(payload))
+	}
+}
+
+var _ = Describe("an mqtt sensor", func() {
+	var (
+		broker *fakeMQTTBroker
+		now    time.Time
+	)
+
+	BeforeEach(func() {
+		broker = &fakeMQTTBroker{handlers: make(map[string][]*mqttHandler)}
+		mqttBroker = broker
+		now = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		mqttBroker = nil
+		timeNow = time.Now
+	})
+
+	It("errors if no broker is configured", func() {
+		mqttBroker = nil
+		_, err := New("foo", config.SensorConfig{Type: "mqtt", Topic: "zigbee2mqtt/lounge"}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("uses the topic as the ID unless one is given", func() {
+		s, err := New("foo", config.SensorConfig{Type: "mqtt", Topic: "zigbee2mqtt/lounge"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.ID()).To(Equal("zigbee2mqtt/lounge"))
+
+		s, err = New("foo", config.SensorConfig{Type: "mqtt", ID: "lounge", Topic: "zigbee2mqtt/lounge"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.ID()).To(Equal("lounge"))
+	})
+
+	It("stops following the topic when closed", func() {
+		s, err := New("foo", config.SensorConfig{Type: "mqtt", Topic: "zigbee2mqtt/lounge"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		other, err := New("bar", config.SensorConfig{Type: "mqtt", Topic: "zigbee2mqtt/lounge"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(broker.handlers["zigbee2mqtt/lounge"]).To(HaveLen(2))
+
+		s.Close()
+		Expect(broker.handlers["zigbee2mqtt/lounge"]).To(HaveLen(1))
+
+		broker.publish("zigbee2mqtt/lounge", "19.5")
+		_, updatedAt := other.Read()
+		Expect(updatedAt).To(Equal(now))
+		_, updatedAt = s.Read()
+		Expect(updatedAt.IsZero()).To(BeTrue())
+	})
+
+	DescribeTable("updating from published messages",
+		func(jsonPath, payload string, expected int) {
+			s, err := New("foo", config.SensorConfig{Type: "mqtt", Topic: "zigbee2mqtt/lounge", JSONPath: jsonPath}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			ch := s.Subscribe().C
+
+			broker.publish("zigbee2mqtt/lounge", payload)
+			Eventually(ch).Should(Receive(Equal(units.Temperature(expected))))
+			temp, updatedAt := s.Read()
+			Expect(temp).To(BeEquivalentTo(expected))
+			Expect(updatedAt).To(Equal(now))
+		},
+		Entry("raw payload", "", "19.5\n", 19500),
+		Entry("top level JSON field", "temperature", `{"temperature":21.37,"humidity":48}`, 21370),
+		Entry("nested JSON field", "state.temperature", `{"state":{"temperature":-2.1}}`, -2100),
+		Entry("JSON string value", "temperature", `{"temperature":"18.25"}`, 18250),
+	)
+
+	DescribeTable("ignoring unparseable messages",
+		func(jsonPath, payload string) {
+			s, err := New("foo", config.SensorConfig{Type: "mqtt", Topic: "zigbee2mqtt/lounge", JSONPath: jsonPath}, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			broker.publish("zigbee2mqtt/lounge", payload)
+			_, updatedAt := s.Read()
+			Expect(updatedAt.IsZero()).To(BeTrue())
+		},
+		Entry("non-numeric payload", "", "offline"),
+		Entry("invalid JSON", "temperature", "{"),
+		Entry("missing field", "temperature", `{"humidity":48}`),
+		Entry("non-object in path", "state.temperature", `{"state":21.5}`),
+		Entry("non-numeric field", "temperature", `{"temperature":true}`),
+	)
+})
@@ -0,0 +1,250 @@
+package sensor
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/units"
+)
+
+// testMQTTServer is a minimal in-process MQTT broker. It supports QoS 0 only,
+// and matches topics exactly.
+type testMQTTServer struct {
+	listener net.Listener
+	lock     sync.Mutex
+	conns    map[net.Conn]map[string]bool
+	connects int
+}
+
+func startTestMQTTServer(addr string) *testMQTTServer {
+	l, err := net.Listen("tcp", addr)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	s := &testMQTTServer{listener: l, conns: make(map[net.Conn]map[string]bool)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testMQTTServer) serve(conn net.Conn) {
+	defer s.drop(conn)
+	for {
+		p, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		switch p := p.(type) {
+		case *packets.ConnectPacket:
+			s.connects++
+			s.conns[conn] = make(map[string]bool)
+			packets.NewControlPacket(packets.Connack).Write(conn)
+		case *packets.SubscribePacket:
+			for _, topic := range p.Topics {
+				s.conns[conn][topic] = true
+			}
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = make([]byte, len(p.Topics))
+			ack.Write(conn)
+		case *packets.UnsubscribePacket:
+			for _, topic := range p.Topics {
+				delete(s.conns[conn], topic)
+			}
+			ack := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			ack.MessageID = p.MessageID
+			ack.Write(conn)
+		case *packets.PingreqPacket:
+			packets.NewControlPacket(packets.Pingresp).Write(conn)
+		case *packets.DisconnectPacket:
+			s.lock.Unlock()
+			return
+		}
+		s.lock.Unlock()
+	}
+}
+
+func (s *testMQTTServer) drop(conn net.Conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	conn.Close()
+	delete(s.conns, conn)
+}
+
+// dropConnections closes all the client connections.
+func (s *testMQTTServer) dropConnections() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+func (s *testMQTTServer) connectCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connects
+}
+
+func (s *testMQTTServer) subscribed(topic string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, topics := range s.conns {
+		if topics[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *testMQTTServer) publish(topic, payload string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for conn, topics := range s.conns {
+		if topics[topic] {
+			p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+			p.TopicName = topic
+			p.Payload = []byte(payload)
+			p.Write(conn)
+		}
+	}
+}
+
+func (s *testMQTTServer) close() {
+	s.listener.Close()
+	s.dropConnections()
+}
+
+// freeTCPAddr returns a local address that nothing is listening on.
+func freeTCPAddr() string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	defer l.Close()
+	return l.Addr().String()
+}
+
+var _ = Describe("the mqtt connection", func() {
+	var (
+		addr   string
+		server *testMQTTServer
+	)
+
+	BeforeEach(func() {
+		mqttConnectRetryInterval = 10 * time.Millisecond
+		addr = freeTCPAddr()
+		server = nil
+	})
+
+	AfterEach(func() {
+		if c, ok := mqttBroker.(*mqttConnection); ok {
+			c.client.Disconnect(0)
+		}
+		if server != nil {
+			server.close()
+		}
+		mqttBroker = nil
+		mqttConnectRetryInterval = 10 * time.Second
+	})
+
+	It("connects in the background, retrying until the broker is available", func() {
+		ConnectMQTT(&config.MQTTConfig{Broker: "tcp://" + addr})
+		s, err := NewMQTTSensor("foo", "foo", "sensors/lounge", "")
+		Expect(err).NotTo(HaveOccurred())
+		ch := s.Subscribe().C
+
+		time.Sleep(50 * time.Millisecond)
+		server = startTestMQTTServer(addr)
+		Eventually(func() bool { return server.subscribed("sensors/lounge") }).Should(BeTrue())
+
+		server.publish("sensors/lounge", "19.5")
+		Eventually(ch).Should(Receive(Equal(units.Temperature(19500))))
+	})
+
+	It("subscribes to topics added once connected", func() {
+		server = startTestMQTTServer(addr)
+		ConnectMQTT(&config.MQTTConfig{Broker: "tcp://" + addr})
+		Eventually(func() bool { return mqttBroker.(*mqttConnection).client.IsConnected() }).Should(BeTrue())
+
+		s, err := NewMQTTSensor("foo", "foo", "sensors/lounge", "")
+		Expect(err).NotTo(HaveOccurred())
+		ch := s.Subscribe().C
+		Eventually(func() bool { return server.subscribed("sensors/lounge") }).Should(BeTrue())
+
+		server.publish("sensors/lounge", "19.5")
+		Eventually(ch).Should(Receive(Equal(units.Temperature(19500))))
+	})
+
+	It("resubscribes to the topics after reconnecting", func() {
+		server = startTestMQTTServer(addr)
+		ConnectMQTT(&config.MQTTConfig{Broker: "tcp://" + addr})
+		s, err := NewMQTTSensor("foo", "foo", "sensors/lounge", "")
+		Expect(err).NotTo(HaveOccurred())
+		ch := s.Subscribe().C
+		Eventually(func() bool { return server.subscribed("sensors/lounge") }).Should(BeTrue())
+
+		server.dropConnections()
+		Eventually(server.connectCount, 5*time.Second).Should(Equal(2))
+		Eventually(func() bool { return server.subscribed("sensors/lounge") }).Should(BeTrue())
+
+		server.publish("sensors/lounge", "20.5")
+		Eventually(ch).Should(Receive(Equal(units.Temperature(20500))))
+	})
+
+	It("dispatches messages to all the handlers for their topic", func() {
+		server = startTestMQTTServer(addr)
+		ConnectMQTT(&config.MQTTConfig{Broker: "tcp://" + addr})
+
+		var (
+			lock     sync.Mutex
+			received []string
+		)
+		handler := func(name string) func([]byte) {
+			return func(payload []byte) {
+				lock.Lock()
+				defer lock.Unlock()
+				received = append(received, name+":"+string(payload))
+			}
+		}
+		mqttBroker.Subscribe("sensors/lounge", handler("one"))
+		mqttBroker.Subscribe("sensors/lounge", handler("two"))
+		mqttBroker.Subscribe("sensors/kitchen", handler("three"))
+		Eventually(func() bool { return server.subscribed("sensors/kitchen") }).Should(BeTrue())
+		Eventually(func() bool { return server.subscribed("sensors/lounge") }).Should(BeTrue())
+
+		server.publish("sensors/lounge", "19.5")
+		Eventually(func() []string {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]string(nil), received...)
+		}).Should(ConsistOf("one:19.5", "two:19.5"))
+	})
+
+	It("unsubscribes from a topic once it has no handlers left", func() {
+		server = startTestMQTTServer(addr)
+		ConnectMQTT(&config.MQTTConfig{Broker: "tcp://" + addr})
+		Eventually(func() bool { return mqttBroker.(*mqttConnection).client.IsConnected() }).Should(BeTrue())
+
+		unsubscribeOne := mqttBroker.Subscribe("sensors/lounge", func([]byte) {})
+		unsubscribeTwo := mqttBroker.Subscribe("sensors/lounge", func([]byte) {})
+		Eventually(func() bool { return server.subscribed("sensors/lounge") }).Should(BeTrue())
+
+		unsubscribeOne()
+		Consistently(func() bool { return server.subscribed("sensors/lounge") }, 100*time.Millisecond).Should(BeTrue())
+
+		unsubscribeTwo()
+		Eventually(func() bool { return server.subscribed("sensors/lounge") }).Should(BeFalse())
+	})
+})
@@ -0,0 +1,50 @@
+package sensor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/alext/heating-controller/units"
+)
+
+// parseTemperature extracts a temperature in °C from the payload using the
+// given JSON path, or from the whole payload if the path is empty.
+func parseTemperature(payload []byte, jsonPath []string) (units.Temperature, error) {
+	var value interface{} = strings.TrimSpace(string(payload))
+	if len(jsonPath) > 0 {
+		var data interface{}
+		err := json.Unmarshal(payload, &data)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range jsonPath {
+			obj, ok := data.(map[string]interface{})
+			if !ok {
+				return 0, fmt.Errorf("Missing '%s' in payload: %s", strings.Join(jsonPath, "."), payload)
+			}
+			data, ok = obj[key]
+			if !ok {
+				return 0, fmt.Errorf("Missing '%s' in payload: %s", strings.Join(jsonPath, "."), payload)
+			}
+		}
+		value = data
+	}
+
+	var degrees float64
+	switch v := value.(type) {
+	case float64:
+		degrees = v
+	case string:
+		var err error
+		degrees, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid temperature value '%s'", v)
+		}
+	default:
+		return 0, fmt.Errorf("Invalid temperature value '%v'", v)
+	}
+	return units.Temperature(math.Round(degrees * 1000)), nil
+}
@@ -0,0 +1,74 @@
+package sensor
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/ticker"
+)
+
+// variable indirection to enable testing
+var (
+	newTicker    = ticker.New
+	randDuration = func(max time.Duration) time.Duration {
+		return time.Duration(rand.Int63n(int64(max)))
+	}
+)
+
+// Polling configures how often a polling sensor is read.
+type Polling struct {
+	Interval time.Duration
+	// Each read is delayed by a random amount up to this, so that sensors
+	// sharing a bus don't all read at once.
+	Jitter time.Duration
+}
+
+var DefaultPolling = Polling{Interval: time.Minute}
+
+func (p Polling) Valid() bool {
+	return p.Interval > 0 && p.Jitter >= 0 && p.Jitter < p.Interval
+}
+
+// delay returns the random delay to apply before a read.
+func (p Polling) delay() time.Duration {
+	if p.Jitter <= 0 {
+		return 0
+	}
+	return randDuration(p.Jitter)
+}
+
+// loop calls read on each tick until it receives on closeCh, which it then
+// closes to confirm it has stopped.
+func (p Polling) loop(closeCh chan struct{}, read func(time.Time)) {
+	t := newTicker(p.Interval)
+	for {
+		select {
+		case t := <-t.Channel():
+			if d := p.delay(); d > 0 {
+				timeSleep(d)
+				t = t.Add(d)
+			}
+			read(t)
+		case <-closeCh:
+			t.Stop()
+			close(closeCh)
+			return
+		}
+	}
+}
+
+// pollingFromConfig returns the polling settings given in the config, using
+// the defaults for any missing values.
+func pollingFromConfig(cfg config.SensorConfig) (Polling, error) {
+	p := DefaultPolling
+	if cfg.PollSeconds > 0 {
+		p.Interval = time.Duration(cfg.PollSeconds) * time.Second
+	}
+	p.Jitter = time.Duration(cfg.PollJitterSeconds) * time.Second
+	if !p.Valid() {
+		return p, fmt.Errorf("Invalid polling: jitter must be less than the interval")
+	}
+	return p, nil
+}
@@ -17,13 +17,18 @@ func NewPushSensor(name, id string) SettableSensor {
 		baseSensor: newBaseSensor(name, id),
 	}
 	ps.baseSensor.temp = initialValue
+	ps.baseSensor.raw = initialValue
 	return ps
 }
 
 func (s *pushSensor) Close() {
-	// No-Op
+	s.close(nil)
 }
 
 func (s *pushSensor) Set(temp units.Temperature, updatedAt time.Time) {
 	s.baseSensor.set(temp, updatedAt)
 }
+
+func (s *pushSensor) SetQuantities(q Quantities, updatedAt time.Time) {
+	s.baseSensor.setQuantities(q, updatedAt)
+}
@@ -35,7 +35,7 @@ var _ = Describe("a push sensor", func() {
 	Describe("subscribing to updates", func() {
 		It("allows subscribing to updates", func() {
 			s := NewPushSensor("foo", "something")
-			ch := s.Subscribe()
+			ch := s.Subscribe().C
 
 			s.Set(1234, time.Now())
 			Eventually(ch).Should(Receive(Equal(units.Temperature(1234))))
@@ -43,14 +43,14 @@ var _ = Describe("a push sensor", func() {
 
 		It("allows multiple subscribers", func() {
 			s := NewPushSensor("foo", "something")
-			ch1 := s.Subscribe()
-			ch2 := s.Subscribe()
+			ch1 := s.Subscribe().C
+			ch2 := s.Subscribe().C
 
 			s.Set(1234, time.Now())
 			Eventually(ch1).Should(Receive(Equal(units.Temperature(1234))))
 			Eventually(ch2).Should(Receive(Equal(units.Temperature(1234))))
 
-			ch3 := s.Subscribe()
+			ch3 := s.Subscribe().C
 			s.Set(12345, time.Now())
 			Eventually(ch1).Should(Receive(Equal(units.Temperature(12345))))
 			Eventually(ch2).Should(Receive(Equal(units.Temperature(12345))))
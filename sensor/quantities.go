@@ -0,0 +1,59 @@
+package sensor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/alext/heating-controller/units"
+)
+
+// Quantities are the readings from a sensor other than the temperature. Any
+// that the sensor doesn't report are nil.
+type Quantities struct {
+	Humidity *units.Humidity `json:"humidity,omitempty"`
+	Pressure *units.Pressure `json:"pressure,omitempty"`
+}
+
+func (q Quantities) Empty() bool {
+	return q.Humidity == nil && q.Pressure == nil
+}
+
+func (q Quantities) String() string {
+	var parts []string
+	if q.Humidity != nil {
+		parts = append(parts, "humidity: "+q.Humidity.String())
+	}
+	if q.Pressure != nil {
+		parts = append(parts, "pressure: "+q.Pressure.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// merge returns q updated with any quantities given in other.
+func (q Quantities) merge(other Quantities) Quantities {
+	if other.Humidity != nil {
+		h := *other.Humidity
+		q.Humidity = &h
+	}
+	if other.Pressure != nil {
+		p := *other.Pressure
+		q.Pressure = &p
+	}
+	return q
+}
+
+// QuantitySensor is a sensor that can report quantities other than the
+// temperature.
+type QuantitySensor interface {
+	Sensor
+	// ReadQuantities returns the latest quantities, and when they were last
+	// updated. The time is zero if there haven't been any.
+	ReadQuantities() (Quantities, time.Time)
+}
+
+type SettableQuantitySensor interface {
+	QuantitySensor
+	// SetQuantities updates the quantities given, leaving any others
+	// unchanged.
+	SetQuantities(Quantities, time.Time)
+}
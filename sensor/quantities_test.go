@@ -0,0 +1,55 @@
+package sensor
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("sensor quantities", func() {
+	It("has no quantities until they're set", func() {
+		s := NewPushSensor("foo", "1234").(SettableQuantitySensor)
+		q, updatedAt := s.ReadQuantities()
+		Expect(q.Empty()).To(BeTrue())
+		Expect(updatedAt.IsZero()).To(BeTrue())
+	})
+
+	It("updates the quantities given, leaving the others unchanged", func() {
+		s := NewPushSensor("foo", "1234").(SettableQuantitySensor)
+		t1 := time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		h, p := units.Humidity(48500), units.Pressure(101325)
+		s.SetQuantities(Quantities{Humidity: &h, Pressure: &p}, t1)
+
+		h2 := units.Humidity(50000)
+		s.SetQuantities(Quantities{Humidity: &h2}, t1.Add(time.Minute))
+
+		q, updatedAt := s.ReadQuantities()
+		Expect(*q.Humidity).To(Equal(units.Humidity(50000)))
+		Expect(*q.Pressure).To(Equal(units.Pressure(101325)))
+		Expect(updatedAt).To(Equal(t1.Add(time.Minute)))
+	})
+
+	It("doesn't share the values with the caller", func() {
+		s := NewPushSensor("foo", "1234").(SettableQuantitySensor)
+		h := units.Humidity(48500)
+		s.SetQuantities(Quantities{Humidity: &h}, time.Now())
+		h = 10000
+
+		q, _ := s.ReadQuantities()
+		*q.Humidity = 20000
+		q, _ = s.ReadQuantities()
+		Expect(*q.Humidity).To(Equal(units.Humidity(48500)))
+	})
+
+	It("leaves the temperature unchanged", func() {
+		s := NewPushSensor("foo", "1234")
+		h := units.Humidity(48500)
+		s.(SettableQuantitySensor).SetQuantities(Quantities{Humidity: &h}, time.Now())
+		temp, updatedAt := s.Read()
+		Expect(temp).To(BeEquivalentTo(initialValue))
+		Expect(updatedAt.IsZero()).To(BeTrue())
+	})
+})
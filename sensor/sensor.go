@@ -4,18 +4,30 @@ import (
 	"fmt"
 	iofs "io/fs"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/alext/heating-controller/config"
 	"github.com/alext/heating-controller/units"
 )
 
-var fs iofs.FS = os.DirFS("")
+// The filesystem that sensor paths are relative to. This must be rooted at "/"
+// rather than "", as DirFS with an empty root fails every Open since Go 1.21.
+var fs iofs.FS = os.DirFS("/")
 
 type Sensor interface {
 	ID() string
 	Read() (units.Temperature, time.Time)
-	Subscribe() <-chan units.Temperature
+	Subscribe() *Subscription
+	// Close stops any polling, and closes the channels of all subscriptions.
+	// It's safe to call more than once.
+	Close()
+}
+
+// StaleReporter is a sensor that can tell when its readings are stale, beyond
+// their age.
+type StaleReporter interface {
+	Stale() bool
 }
 
 type SettableSensor interface {
@@ -23,13 +35,110 @@ type SettableSensor interface {
 	Set(units.Temperature, time.Time)
 }
 
-func New(name string, cfg config.SensorConfig) (Sensor, error) {
+// New builds the sensor described by cfg. Derived sensors look up their inputs
+// in sensors, so these must be created first.
+func New(name string, cfg config.SensorConfig, sensors map[string]Sensor) (Sensor, error) {
+	r, err := retentionFromConfig(cfg.History)
+	if err != nil {
+		return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+	}
+	s, err := newSensor(name, cfg, sensors)
+	if err != nil {
+		return nil, err
+	}
+	if hs, ok := s.(HistorySensor); ok {
+		hs.History().SetRetention(r)
+	}
+	return s, nil
+}
+
+func newSensor(name string, cfg config.SensorConfig, sensors map[string]Sensor) (Sensor, error) {
 	switch cfg.Type {
 	case "w1":
-		return NewW1Sensor(name, cfg.ID), nil
+		f, err := filterFromConfig(cfg.Filter, W1ErrorValues)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		p, err := pollingFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		return newW1Sensor(name, cfg.ID, f, w1RetryFromConfig(cfg), p), nil
 	case "push":
-		return NewPushSensor(name, cfg.ID), nil
+		f, err := filterFromConfig(cfg.Filter, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		s := NewPushSensor(name, cfg.ID)
+		s.(*pushSensor).setFilter(f)
+		return s, nil
+	case "mqtt":
+		f, err := filterFromConfig(cfg.Filter, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		id := cfg.ID
+		if id == "" {
+			id = cfg.Topic
+		}
+		s, err := NewMQTTSensor(name, id, cfg.Topic, cfg.JSONPath)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		s.(*mqttSensor).setFilter(f)
+		return s, nil
+	case "http":
+		f, err := filterFromConfig(cfg.Filter, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		p, err := pollingFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		source, err := httpSourceFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		id := cfg.ID
+		if id == "" {
+			id = cfg.URL
+		}
+		return newHTTPSensor(name, id, source, f, p), nil
+	case "exec":
+		f, err := filterFromConfig(cfg.Filter, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		p, err := pollingFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		command, err := execCommandFromConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Sensor '%s': %w", name, err)
+		}
+		id := cfg.ID
+		if id == "" {
+			id = strings.Join(cfg.Command, " ")
+		}
+		return newExecSensor(name, id, command, f, p), nil
+	case "mean", "min", "max", "difference", "offset":
+		return newDerived(name, cfg, sensors)
 	default:
 		return nil, fmt.Errorf("Unrecognised sensor type: '%s'", cfg.Type)
 	}
 }
+
+// w1RetryFromConfig returns the retry settings given in the config, using the
+// defaults for any missing values.
+func w1RetryFromConfig(cfg config.SensorConfig) W1Retry {
+	retry := DefaultW1Retry
+	if cfg.ReadRetries != nil {
+		retry.Retries = *cfg.ReadRetries
+	}
+	if cfg.RetryBackoffMilliseconds > 0 {
+		retry.Backoff = time.Duration(cfg.RetryBackoffMilliseconds) * time.Millisecond
+	}
+	return retry
+}
@@ -0,0 +1,24 @@
+package sensor
+
+import (
+	"sync"
+
+	"github.com/alext/heating-controller/units"
+)
+
+// Subscription delivers the readings from a sensor. If the subscriber falls
+// behind, intermediate readings are dropped.
+type Subscription struct {
+	// C receives each new reading. It's closed when the subscription is
+	// cancelled, or the sensor is closed.
+	C <-chan units.Temperature
+
+	once   sync.Once
+	cancel func()
+}
+
+// Cancel stops the delivery of readings, and closes C. It's safe to call more
+// than once, and after the sensor has been closed.
+func (s *Subscription) Cancel() {
+	s.once.Do(s.cancel)
+}
@@ -0,0 +1,165 @@
+package sensor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing/fstest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/ticker"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("sensor subscriptions", func() {
+	var s SettableSensor
+
+	BeforeEach(func() {
+		s = NewPushSensor("foo", "1234")
+	})
+
+	Describe("cancelling a subscription", func() {
+		It("closes the channel, and stops delivering readings", func() {
+			sub1 := s.Subscribe()
+			sub2 := s.Subscribe()
+
+			sub1.Cancel()
+			Eventually(sub1.C).Should(BeClosed())
+
+			s.Set(1234, time.Now())
+			Eventually(sub2.C).Should(Receive(Equal(units.Temperature(1234))))
+		})
+
+		It("can be cancelled more than once", func() {
+			sub := s.Subscribe()
+			sub.Cancel()
+			sub.Cancel()
+			Eventually(sub.C).Should(BeClosed())
+		})
+
+		It("can be cancelled after the sensor is closed", func() {
+			sub := s.Subscribe()
+			s.Close()
+			sub.Cancel()
+			Eventually(sub.C).Should(BeClosed())
+		})
+	})
+
+	Describe("closing a sensor", func() {
+		It("closes the channels of all subscriptions", func() {
+			sub1 := s.Subscribe()
+			sub2 := s.Subscribe()
+
+			s.Close()
+			Eventually(sub1.C).Should(BeClosed())
+			Eventually(sub2.C).Should(BeClosed())
+		})
+
+		It("closes the channel of any later subscriptions", func() {
+			s.Close()
+			sub := s.Subscribe()
+			Eventually(sub.C).Should(BeClosed())
+		})
+
+		It("can be closed more than once", func() {
+			s.Close()
+			s.Close()
+		})
+
+		It("closes an aggregate sensor's subscriptions, leaving its inputs open", func() {
+			s.Set(19000, time.Now())
+			agg, err := NewAggregateSensor("agg", AggregateMean, []AggregateInput{{Name: "foo", Sensor: s, Weight: 1}})
+			Expect(err).NotTo(HaveOccurred())
+			sub := agg.Subscribe()
+			inputSub := s.Subscribe()
+
+			agg.Close()
+			Eventually(sub.C).Should(BeClosed())
+			s.Set(20000, time.Now())
+			Eventually(inputSub.C).Should(Receive(Equal(units.Temperature(20000))))
+		})
+
+		It("stops an aggregate sensor following an input that's closed", func() {
+			s.Set(19000, time.Now())
+			agg, err := NewAggregateSensor("agg", AggregateMean, []AggregateInput{{Name: "foo", Sensor: s, Weight: 1}})
+			Expect(err).NotTo(HaveOccurred())
+			defer agg.Close()
+
+			s.Close()
+			s.Set(20000, time.Now())
+			Consistently(func() units.Temperature {
+				temp, _ := agg.Read()
+				return temp
+			}, 50*time.Millisecond).Should(BeEquivalentTo(19000))
+		})
+	})
+
+	Describe("goroutine leaks", func() {
+		var (
+			before    int
+			testFS    fstest.MapFS
+			tkrNotify chan struct{}
+		)
+
+		BeforeEach(func() {
+			testFS = make(fstest.MapFS)
+			testFS[w1DevicesPath+testSensorID+"/w1_slave"] = &fstest.MapFile{Data: []byte(sampleData1)}
+			fs = testFS
+			tkrNotify = make(chan struct{}, 1)
+			newTicker = func(d time.Duration) ticker.Ticker {
+				return &dummyTicker{duration: d, C: make(chan time.Time, 1), notify: tkrNotify}
+			}
+			before = runtime.NumGoroutine()
+		})
+
+		var expectNoLeaks = func() {
+			Eventually(runtime.NumGoroutine).Should(BeNumerically("<=", before))
+		}
+
+		It("leaves none running once polling sensors are closed", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("19.5"))
+			}))
+			defer server.Close()
+			before = runtime.NumGoroutine()
+
+			sensors := []Sensor{
+				NewW1Sensor("w1", testSensorID),
+				NewHTTPSensor("http", "http", HTTPSource{URL: server.URL}, DefaultPolling),
+				NewExecSensor("exec", "exec", ExecCommand{Args: []string{"echo", "19.5"}}, DefaultPolling),
+			}
+			for _, sens := range sensors {
+				sens.Subscribe()
+			}
+			Expect(runtime.NumGoroutine()).To(BeNumerically(">", before))
+
+			for _, sens := range sensors {
+				sens.Close()
+			}
+			expectNoLeaks()
+		})
+
+		It("leaves none running once derived sensors are closed", func() {
+			s.Set(19000, time.Now())
+			other := NewPushSensor("bar", "2345")
+			other.Set(18000, time.Now())
+			inputs := []AggregateInput{{Name: "foo", Sensor: s, Weight: 1}, {Name: "bar", Sensor: other, Weight: 1}}
+
+			agg, _ := NewAggregateSensor("agg", AggregateMean, inputs)
+			diff := NewDifferenceSensor("diff", inputs[0], inputs[1])
+			offset := NewOffsetSensor("offset", AggregateInput{Name: "diff", Sensor: diff}, 500)
+			sub := offset.Subscribe()
+			s.Set(20000, time.Now())
+			Eventually(sub.C).Should(Receive())
+			Expect(runtime.NumGoroutine()).To(BeNumerically(">", before))
+
+			offset.Close()
+			diff.Close()
+			agg.Close()
+			expectNoLeaks()
+		})
+	})
+})
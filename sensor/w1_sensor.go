@@ -1,6 +1,8 @@
 package sensor
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"regexp"
@@ -12,65 +14,142 @@ import (
 
 const w1DevicesPath = "sys/bus/w1/devices/"
 
+// variable indirection to enable testing
+var timeSleep = time.Sleep
+
+var errCRC = errors.New("CRC check failed")
+
+// W1Retry configures the retrying of failed reads from a 1-wire device.
+type W1Retry struct {
+	Retries int
+	// The delay before the first retry, doubling for each subsequent retry.
+	Backoff time.Duration
+}
+
+var DefaultW1Retry = W1Retry{
+	Retries: 2,
+	Backoff: 250 * time.Millisecond,
+}
+
+// ReadErrors counts the failed reads from a sensor, and records the latest
+// error.
+type ReadErrors struct {
+	CRCFailures  uint64    `json:"crc_failures"`
+	ReadFailures uint64    `json:"read_failures"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastErrorAt  time.Time `json:"last_error_at,omitempty"`
+}
+
+// ErrorCountingSensor is a sensor that records its failed reads.
+type ErrorCountingSensor interface {
+	Sensor
+	ReadErrors() ReadErrors
+}
+
 type w1Sensor struct {
 	baseSensor
+	retry   W1Retry
+	polling Polling
+	errors  ReadErrors
 	closeCh chan struct{}
 }
 
+// NewW1Sensor returns a sensor that polls a 1-wire device, rejecting the
+// DS18B20 error values.
 func NewW1Sensor(name, id string) Sensor {
+	return newW1Sensor(name, id, &Filter{RejectValues: W1ErrorValues}, DefaultW1Retry, DefaultPolling)
+}
+
+func newW1Sensor(name, id string, f *Filter, retry W1Retry, polling Polling) Sensor {
 	s := &w1Sensor{
 		baseSensor: newBaseSensor(name, id),
+		retry:      retry,
+		polling:    polling,
 		closeCh:    make(chan struct{}),
 	}
+	s.filter = newReadingFilter(f)
 	s.readTemperature(time.Now())
-	go s.readLoop()
+	go s.polling.loop(s.closeCh, s.readTemperature)
 	return s
 }
 
-func (s *w1Sensor) readLoop() {
-	t := newTicker(time.Minute)
-	for {
-		select {
-		case t := <-t.Channel():
-			s.readTemperature(t)
-		case <-s.closeCh:
-			t.Stop()
-			close(s.closeCh)
-			return
-		}
-	}
+func (s *w1Sensor) Close() {
+	s.close(s.stopPolling)
 }
 
-func (s *w1Sensor) Close() {
+func (s *w1Sensor) stopPolling() {
 	s.closeCh <- struct{}{}
 	<-s.closeCh
 }
 
-var temperatureRegexp = regexp.MustCompile(`t=(-?\d+)`)
+func (s *w1Sensor) ReadErrors() ReadErrors {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.errors
+}
+
+var (
+	crcRegexp         = regexp.MustCompile(`crc=[0-9a-f]{2} (YES|NO)`)
+	temperatureRegexp = regexp.MustCompile(`t=(-?\d+)`)
+)
 
+// readTemperature reads the device, retrying with backoff on failure.
 func (s *w1Sensor) readTemperature(updateTime time.Time) {
-	file, err := fs.Open(w1DevicesPath + s.baseSensor.id + "/w1_slave")
+	backoff := s.retry.Backoff
+	for attempt := 0; ; attempt++ {
+		temp, err := readW1Device(s.baseSensor.id)
+		if err == nil {
+			s.baseSensor.set(temp, updateTime)
+			return
+		}
+		s.recordError(err)
+		if attempt >= s.retry.Retries {
+			log.Printf("[sensor:%s] Giving up after %d attempts: %s", s.baseSensor.id, attempt+1, err.Error())
+			return
+		}
+		log.Printf("[sensor:%s] Retrying in %s after error: %s", s.baseSensor.id, backoff, err.Error())
+		timeSleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *w1Sensor) recordError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if errors.Is(err, errCRC) {
+		s.errors.CRCFailures++
+	} else {
+		s.errors.ReadFailures++
+	}
+	s.errors.LastError = err.Error()
+	s.errors.LastErrorAt = timeNow()
+}
+
+func readW1Device(id string) (units.Temperature, error) {
+	file, err := fs.Open(w1DevicesPath + id + "/w1_slave")
 	if err != nil {
-		log.Printf("[sensor:%s] Error opening device file: %s", s.baseSensor.id, err.Error())
-		return
+		return 0, fmt.Errorf("Error opening device file: %w", err)
 	}
 	defer file.Close()
 	data, err := ioutil.ReadAll(file)
 	if err != nil {
-		log.Printf("[sensor:%s] Error reading device: %s", s.baseSensor.id, err.Error())
-		return
+		return 0, fmt.Errorf("Error reading device: %w", err)
+	}
+	crcMatches := crcRegexp.FindStringSubmatch(string(data))
+	if crcMatches == nil {
+		return 0, fmt.Errorf("Failed to match CRC in data: %q", string(data))
+	}
+	if crcMatches[1] != "YES" {
+		return 0, errCRC
 	}
 	matches := temperatureRegexp.FindStringSubmatch(string(data))
 	if matches == nil {
-		log.Printf("[sensor:%s] Failed to match temperature in data:\n%s", s.baseSensor.id, string(data))
-		return
+		return 0, fmt.Errorf("Failed to match temperature in data: %q", string(data))
 	}
 
 	temp, err := strconv.Atoi(matches[1])
 	if err != nil {
-		log.Printf("[sensor:%s] Error parsing temperature value '%s': %s", s.baseSensor.id, matches[1], err.Error())
-		return
+		return 0, fmt.Errorf("Error parsing temperature value '%s': %w", matches[1], err)
 	}
-
-	s.baseSensor.set(units.Temperature(temp), updateTime)
+	return units.Temperature(temp), nil
 }
@@ -4,6 +4,8 @@ import (
 	"testing/fstest"
 	"time"
 
+	"github.com/alext/heating-controller/config"
+	"github.com/alext/heating-controller/ticker"
 	"github.com/alext/heating-controller/units"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -17,6 +19,9 @@ const sampleData1 = `37 01 4b 46 7f ff 09 10 26 : crc=26 YES
 const sampleData2 = `21 01 4b 46 7f ff 0f 10 4b : crc=4b YES
 21 01 4b 46 7f ff 0f 10 4b t=18062
 `
+const badCRCData = `21 01 4b 46 7f ff 0f 10 4b : crc=4c NO
+21 01 4b 46 7f ff 0f 10 4b t=18062
+`
 
 type dummyTicker struct {
 	duration time.Duration
@@ -41,14 +46,23 @@ var _ = Describe("a w1 sensor", func() {
 		testFS    fstest.MapFS
 		tkr       *dummyTicker
 		tkrNotify chan struct{}
+		sleeps    []time.Duration
+		onSleep   func()
 	)
 
 	BeforeEach(func() {
 		testFS = make(fstest.MapFS)
 		fs = testFS
 		tkrNotify = make(chan struct{}, 1)
+		sleeps, onSleep = nil, nil
+		timeSleep = func(d time.Duration) {
+			sleeps = append(sleeps, d)
+			if onSleep != nil {
+				onSleep()
+			}
+		}
 
-		newTicker = func(d time.Duration) ticker {
+		newTicker = func(d time.Duration) ticker.Ticker {
 			tkr = &dummyTicker{
 				duration: d,
 				C:        make(chan time.Time, 1),
@@ -58,6 +72,10 @@ var _ = Describe("a w1 sensor", func() {
 		}
 	})
 
+	AfterEach(func() {
+		timeSleep = time.Sleep
+	})
+
 	var populateValueFile = func(id, contents string) {
 		valueFilePath := w1DevicesPath + id + "/w1_slave"
 		testFS[valueFilePath] = &fstest.MapFile{
@@ -75,9 +93,7 @@ var _ = Describe("a w1 sensor", func() {
 			sensor = NewW1Sensor("foo", testSensorID)
 		})
 		AfterEach(func() {
-			if s, ok := sensor.(*w1Sensor); ok {
-				s.Close()
-			}
+			sensor.Close()
 		})
 
 		It("returns the ID", func() {
@@ -130,6 +146,21 @@ f6 ff 55 00 7f ff 0c 10 47 t=-625`
 			close(done)
 		})
 
+		It("should reject the DS18B20 error values", func(done Done) {
+			<-tkrNotify
+
+			powerOnData := `50 05 4b 46 7f ff 0c 10 1c : crc=1c YES
+50 05 4b 46 7f ff 0c 10 1c t=85000`
+			populateValueFile(testSensorID, powerOnData)
+			tkr.C <- time.Now()
+			<-tkrNotify
+			temperature, _ := sensor.Read()
+			Expect(temperature).To(BeEquivalentTo(19437))
+			Expect(sensor.(FilteredSensor).RejectedReadings()).To(Equal(RejectedReadings{BadValue: 1}))
+
+			close(done)
+		})
+
 		It("should track when the temperature was last updated", func(done Done) {
 			<-tkrNotify
 			populateValueFile(testSensorID, sampleData2)
@@ -153,7 +184,7 @@ f6 ff 55 00 7f ff 0c 10 47 t=-625`
 		It("allows subscribing to updates", func() {
 			<-tkrNotify
 
-			ch := sensor.Subscribe()
+			ch := sensor.Subscribe().C
 			tkr.C <- time.Now()
 			<-tkrNotify
 			Eventually(ch).Should(Receive(Equal(units.Temperature(19437))))
@@ -165,6 +196,102 @@ f6 ff 55 00 7f ff 0c 10 47 t=-625`
 		})
 	})
 
+	Describe("configuring the polling", func() {
+		var (
+			sensor           Sensor
+			origRandDuration func(time.Duration) time.Duration
+		)
+
+		BeforeEach(func() {
+			populateValueFile(testSensorID, sampleData1)
+			origRandDuration = randDuration
+			randDuration = func(max time.Duration) time.Duration { return max / 2 }
+		})
+		AfterEach(func() {
+			sensor.Close()
+			randDuration = origRandDuration
+		})
+
+		It("polls at the configured interval", func(done Done) {
+			var err error
+			sensor, err = New("foo", config.SensorConfig{Type: "w1", ID: testSensorID, PollSeconds: 10}, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			<-tkrNotify
+			Expect(tkr.duration).To(Equal(10 * time.Second))
+
+			close(done)
+		})
+
+		It("delays each read by a random jitter", func(done Done) {
+			sensor = newW1Sensor("foo", testSensorID, nil, DefaultW1Retry, Polling{Interval: time.Minute, Jitter: 10 * time.Second})
+			<-tkrNotify
+
+			populateValueFile(testSensorID, sampleData2)
+			tickTime := time.Now().Add(-time.Minute)
+			tkr.C <- tickTime
+			<-tkrNotify
+			temperature, updatedAt := sensor.Read()
+			Expect(temperature).To(BeEquivalentTo(18062))
+			Expect(sleeps).To(Equal([]time.Duration{5 * time.Second}))
+			Expect(updatedAt).To(Equal(tickTime.Add(5 * time.Second)))
+
+			close(done)
+		})
+
+		It("errors with a jitter that isn't less than the interval", func() {
+			_, err := New("foo", config.SensorConfig{Type: "w1", ID: testSensorID, PollSeconds: 10, PollJitterSeconds: 10}, nil)
+			Expect(err).To(HaveOccurred())
+			sensor = NewW1Sensor("foo", testSensorID)
+		})
+	})
+
+	Describe("handling failed reads", func() {
+		var (
+			sensor *w1Sensor
+		)
+
+		AfterEach(func() {
+			sensor.Close()
+		})
+
+		It("rejects a reading with a failed CRC check, retrying with backoff", func() {
+			populateValueFile(testSensorID, badCRCData)
+			onSleep = func() {
+				if len(sleeps) == 2 {
+					populateValueFile(testSensorID, sampleData1)
+				}
+			}
+			sensor = newW1Sensor("foo", testSensorID, nil, W1Retry{Retries: 3, Backoff: 100 * time.Millisecond}, DefaultPolling).(*w1Sensor)
+
+			temperature, _ := sensor.Read()
+			Expect(temperature).To(BeEquivalentTo(19437))
+			Expect(sleeps).To(Equal([]time.Duration{100 * time.Millisecond, 200 * time.Millisecond}))
+			errors := sensor.ReadErrors()
+			Expect(errors.CRCFailures).To(BeEquivalentTo(2))
+			Expect(errors.ReadFailures).To(BeEquivalentTo(0))
+			Expect(errors.LastError).To(Equal("CRC check failed"))
+		})
+
+		It("gives up after the configured number of retries", func() {
+			populateValueFile(testSensorID, badCRCData)
+			sensor = newW1Sensor("foo", testSensorID, nil, W1Retry{Retries: 2, Backoff: 100 * time.Millisecond}, DefaultPolling).(*w1Sensor)
+
+			_, updatedAt := sensor.Read()
+			Expect(updatedAt.IsZero()).To(BeTrue())
+			Expect(sleeps).To(HaveLen(2))
+			Expect(sensor.ReadErrors().CRCFailures).To(BeEquivalentTo(3))
+		})
+
+		It("counts errors reading the device", func() {
+			sensor = newW1Sensor("foo", testSensorID, nil, W1Retry{}, DefaultPolling).(*w1Sensor)
+
+			errors := sensor.ReadErrors()
+			Expect(errors.ReadFailures).To(BeEquivalentTo(1))
+			Expect(errors.LastError).To(ContainSubstring("Error opening device file"))
+		})
+	})
+
 	Describe("closing a sensor", func() {
 		var (
 			sensor *w1Sensor
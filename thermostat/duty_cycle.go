@@ -0,0 +1,194 @@
+package thermostat
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/ticker"
+	"github.com/alext/heating-controller/units"
+)
+
+// variable indirection to enable testing
+var newTicker = ticker.New
+
+// The number of steps each cycle is divided into. This sets the resolution of
+// the duty cycle.
+const dutyCycleSteps = 20
+
+// dutyCycleFunc returns the fraction of the next cycle that the output should
+// be on for. It's called with the thermostat lock held for writing.
+type dutyCycleFunc func(target, current units.Temperature) float64
+
+// dutyCycleThermostat switches the output on for a fraction of each fixed
+// length cycle, with the fraction calculated at the start of each cycle. It's
+// the basis of the TPI and PID thermostats.
+type dutyCycleThermostat struct {
+	id          string
+	cycleLength time.Duration
+	source      *sensor.Subscription
+	demand      demandFunc
+	dutyCycle   dutyCycleFunc
+	ticker      ticker.Ticker
+	closeCh     chan struct{}
+	doneCh      chan struct{}
+	closeOnce   sync.Once
+
+	lock       sync.RWMutex
+	target     units.Temperature
+	current    units.Temperature
+	hysteresis Hysteresis
+	step       int
+	onSteps    int
+	active     bool
+	idle       bool
+}
+
+func newDutyCycleThermostat(id string, source sensor.Sensor, target units.Temperature, cycleLength time.Duration, df demandFunc) *dutyCycleThermostat {
+	initial, _ := source.Read()
+	return &dutyCycleThermostat{
+		id:          id,
+		cycleLength: cycleLength,
+		source:      source.Subscribe(),
+		target:      target,
+		current:     initial,
+		hysteresis:  DefaultHysteresis,
+		demand:      df,
+		closeCh:     make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+// start begins the first cycle using the given function to calculate the
+// duty cycle.
+func (t *dutyCycleThermostat) start(f dutyCycleFunc) {
+	t.dutyCycle = f
+
+	t.lock.Lock()
+	// Set active so that the initial demand is only sent if the output isn't
+	// needed, to match the zone's initial state.
+	t.active = true
+	t.startCycle()
+	t.lock.Unlock()
+
+	t.ticker = newTicker(t.cycleLength / dutyCycleSteps)
+	go t.loop()
+}
+
+func (t *dutyCycleThermostat) Current() units.Temperature {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.current
+}
+
+func (t *dutyCycleThermostat) Target() units.Temperature {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.target
+}
+
+// Set updates the target. This takes effect from the start of the next cycle.
+func (t *dutyCycleThermostat) Set(tmp units.Temperature) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.target = tmp
+}
+
+// Hysteresis is not used for duty cycle control, but is stored so that it's
+// preserved when switching between modes.
+func (t *dutyCycleThermostat) Hysteresis() Hysteresis {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.hysteresis
+}
+
+func (t *dutyCycleThermostat) SetHysteresis(h Hysteresis) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.hysteresis = h
+}
+
+// DutyCycle returns the fraction of the current cycle that the output is on
+// for.
+func (t *dutyCycleThermostat) DutyCycle() float64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return float64(t.onSteps) / dutyCycleSteps
+}
+
+// SetIdle sets whether the output is currently not wanted regardless of the
+// temperature. This takes effect from the start of the next cycle.
+func (t *dutyCycleThermostat) SetIdle(idle bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.idle = idle
+}
+
+// Close stops the thermostat, and waits for its loop to exit.
+func (t *dutyCycleThermostat) Close() {
+	t.closeOnce.Do(func() {
+		if t.source != nil {
+			t.source.Cancel()
+		}
+		close(t.closeCh)
+		<-t.doneCh
+	})
+}
+
+// loop runs the cycle until the thermostat is closed, closing doneCh once it
+// has stopped.
+func (t *dutyCycleThermostat) loop() {
+	defer close(t.doneCh)
+	sourceCh := t.source.C
+	for {
+		select {
+		case tmp, ok := <-sourceCh:
+			if !ok {
+				// The sensor has closed, so carry on with the last reading.
+				sourceCh = nil
+				continue
+			}
+			t.lock.Lock()
+			t.current = tmp
+			t.lock.Unlock()
+		case <-t.ticker.Channel():
+			t.tick()
+		case <-t.closeCh:
+			t.ticker.Stop()
+			return
+		}
+	}
+}
+
+func (t *dutyCycleThermostat) tick() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.step++
+	if t.step >= dutyCycleSteps {
+		t.startCycle()
+		return
+	}
+	t.setActive(t.step < t.onSteps)
+}
+
+// Must be called with the lock held for writing.
+func (t *dutyCycleThermostat) startCycle() {
+	t.step = 0
+	fraction := math.Max(0, math.Min(1, t.dutyCycle(t.target, t.current)))
+	t.onSteps = int(math.Round(fraction * dutyCycleSteps))
+	log.Printf("[Thermostat:%s] starting cycle, current: %s, target: %s, on for %d/%d", t.id, t.current, t.target, t.onSteps, dutyCycleSteps)
+	t.setActive(t.onSteps > 0)
+}
+
+// Must be called with the lock held for writing.
+func (t *dutyCycleThermostat) setActive(active bool) {
+	if active == t.active {
+		return
+	}
+	t.active = active
+	if t.demand != nil {
+		go t.demand(active)
+	}
+}
@@ -0,0 +1,111 @@
+package thermostat
+
+import (
+	"math"
+	"time"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/units"
+)
+
+// PIDSettings configures a PID thermostat. The gains are in terms of the
+// fraction of each cycle that the output is on for.
+type PIDSettings struct {
+	// The length of each on/off cycle.
+	CycleLength time.Duration
+	// The proportional gain, per °C of error.
+	Kp float64
+	// The integral gain, per °C minute of accumulated error.
+	Ki float64
+	// The derivative gain, per °C/minute rate of change.
+	Kd float64
+}
+
+// DefaultPIDSettings gives 6 cycles an hour, with the output fully on when
+// 2°C below target.
+var DefaultPIDSettings = PIDSettings{
+	CycleLength: 10 * time.Minute,
+	Kp:          0.5,
+	Ki:          0.005,
+	Kd:          0,
+}
+
+// PIDTerms are the contributions of each of the terms to the output of a PID
+// thermostat, as calculated at the start of the current cycle.
+type PIDTerms struct {
+	P      float64 `json:"p"`
+	I      float64 `json:"i"`
+	D      float64 `json:"d"`
+	Output float64 `json:"output"`
+}
+
+// PIDThermostat is a thermostat that exposes its internal PID terms.
+type PIDThermostat interface {
+	Thermostat
+	PIDTerms() PIDTerms
+}
+
+type pidThermostat struct {
+	*dutyCycleThermostat
+	settings PIDSettings
+
+	integral    float64
+	previous    units.Temperature
+	hasPrevious bool
+	terms       PIDTerms
+}
+
+// NewPID returns a thermostat using PID control. Each cycle the output is
+// switched on for a fraction of the cycle given by the sum of the PID terms.
+func NewPID(id string, source sensor.Sensor, target units.Temperature, settings PIDSettings, df demandFunc) PIDThermostat {
+	t := &pidThermostat{
+		dutyCycleThermostat: newDutyCycleThermostat(id, source, target, settings.CycleLength, df),
+		settings:            settings,
+	}
+	t.start(t.calculateDutyCycle)
+	return t
+}
+
+func (t *pidThermostat) PIDTerms() PIDTerms {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.terms
+}
+
+func (t *pidThermostat) calculateDutyCycle(target, current units.Temperature) float64 {
+	minutes := t.settings.CycleLength.Minutes()
+	err := (target - current).Float()
+
+	p := t.settings.Kp * err
+
+	// The derivative is taken on the measurement rather than the error so that
+	// changing the target doesn't cause a spike.
+	var d float64
+	if t.hasPrevious && minutes > 0 {
+		d = -t.settings.Kd * (current - t.previous).Float() / minutes
+	}
+	t.previous, t.hasPrevious = current, true
+
+	if t.idle {
+		t.integral = 0
+	}
+	// Anti-windup: don't accumulate any further error that would push an
+	// already saturated output further into saturation.
+	integral := t.integral + err*minutes
+	output := p + t.settings.Ki*integral + d
+	if !t.idle && !(output > 1 && err > 0) && !(output < 0 && err < 0) {
+		t.integral = integral
+	}
+	i := t.settings.Ki * t.integral
+	// Limit the integral term itself to the range of the output.
+	if i > 1 || i < -1 {
+		i = math.Max(-1, math.Min(1, i))
+		if t.settings.Ki != 0 {
+			t.integral = i / t.settings.Ki
+		}
+	}
+
+	output = p + i + d
+	t.terms = PIDTerms{P: p, I: i, D: d, Output: math.Max(0, math.Min(1, output))}
+	return output
+}
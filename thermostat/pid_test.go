@@ -0,0 +1,153 @@
+package thermostat
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/ticker"
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("A PID thermostat", func() {
+	var (
+		t         *pidThermostat
+		sens      sensor.SettableSensor
+		settings  PIDSettings
+		demands   chan bool
+		tkr       *dummyTicker
+		tkrNotify chan struct{}
+	)
+
+	BeforeEach(func() {
+		t = nil
+		sens = sensor.NewPushSensor("foo", "something")
+		sens.Set(19000, time.Now())
+		settings = PIDSettings{
+			CycleLength: 10 * time.Minute,
+			Kp:          0.5,
+		}
+		demands = make(chan bool, 10)
+		tkrNotify = make(chan struct{}, 1)
+
+		newTicker = func(d time.Duration) ticker.Ticker {
+			tkr = &dummyTicker{
+				duration: d,
+				C:        make(chan time.Time, 1),
+				notify:   tkrNotify,
+				stopped:  make(chan struct{}),
+			}
+			return tkr
+		}
+	})
+
+	AfterEach(func() {
+		if t != nil {
+			t.Close()
+		}
+	})
+
+	var newPID = func(target units.Temperature) *pidThermostat {
+		// Capture the channel, as demands may still be sent after the test.
+		ch := demands
+		return NewPID("something", sens, target, settings, func(b bool) { ch <- b }).(*pidThermostat)
+	}
+
+	var tick = func(n int) {
+		for i := 0; i < n; i++ {
+			tkr.C <- time.Now()
+			<-tkrNotify
+		}
+	}
+
+	It("is on for a fraction of the cycle given by the proportional term", func(done Done) {
+		t = newPID(20000)
+		<-tkrNotify
+		Expect(tkr.duration).To(Equal(30 * time.Second))
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(10))
+		Expect(t.PIDTerms()).To(Equal(PIDTerms{P: 0.5, Output: 0.5}))
+
+		tick(10)
+		Eventually(demands).Should(Receive(BeFalse()))
+		tick(10)
+		Eventually(demands).Should(Receive(BeTrue()))
+		close(done)
+	})
+
+	It("limits the output to the whole cycle", func(done Done) {
+		t = newPID(23000)
+		<-tkrNotify
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(20))
+		Expect(t.PIDTerms().P).To(BeNumerically("~", 2.0))
+		Expect(t.PIDTerms().Output).To(BeNumerically("~", 1.0))
+		close(done)
+	})
+
+	It("accumulates a persistent error in the integral term", func(done Done) {
+		settings.Ki = 0.01
+		t = newPID(19500)
+		<-tkrNotify
+		// 0.5°C for 10 minutes
+		Expect(t.PIDTerms().I).To(BeNumerically("~", 0.05))
+
+		tick(20)
+		Expect(t.PIDTerms().I).To(BeNumerically("~", 0.1))
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(7))
+		close(done)
+	})
+
+	It("stops accumulating the integral while the output is saturated", func(done Done) {
+		settings.Ki = 0.01
+		t = newPID(23000)
+		<-tkrNotify
+		Expect(t.PIDTerms().I).To(BeNumerically("~", 0))
+
+		tick(40)
+		Expect(t.PIDTerms().I).To(BeNumerically("~", 0))
+
+		sens.Set(22500, time.Now())
+		Eventually(t.Current).Should(BeEquivalentTo(22500))
+		tick(20)
+		// 0.5°C error now has room to accumulate
+		Expect(t.PIDTerms().I).To(BeNumerically("~", 0.05))
+		close(done)
+	})
+
+	It("opposes the rate of change of temperature with the derivative term", func(done Done) {
+		settings.Kd = 1
+		t = newPID(20000)
+		<-tkrNotify
+		Expect(t.PIDTerms().D).To(BeNumerically("~", 0))
+
+		sens.Set(19500, time.Now())
+		Eventually(t.Current).Should(BeEquivalentTo(19500))
+		tick(20)
+		// Rising 0.5°C over 10 minutes
+		Expect(t.PIDTerms().D).To(BeNumerically("~", -0.05))
+		Expect(t.PIDTerms().P).To(BeNumerically("~", 0.25))
+		close(done)
+	})
+
+	It("can be closed more than once", func(done Done) {
+		t = newPID(20000)
+		<-tkrNotify
+		t.Close()
+		Expect(tkr.stopped).To(BeClosed())
+		Expect(t.Close).NotTo(Panic())
+		close(done)
+	})
+
+	It("doesn't produce a derivative spike when the target changes", func(done Done) {
+		settings.Kd = 1
+		t = newPID(20000)
+		<-tkrNotify
+
+		t.Set(21000)
+		tick(20)
+		Expect(t.PIDTerms().D).To(BeNumerically("~", 0))
+		Expect(t.PIDTerms().P).To(BeNumerically("~", 1.0))
+		close(done)
+	})
+})
@@ -1,6 +1,7 @@
 package thermostat
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/alext/heating-controller/sensor"
@@ -12,32 +13,68 @@ type Thermostat interface {
 	Current() units.Temperature
 	Target() units.Temperature
 	Set(units.Temperature)
+	Hysteresis() Hysteresis
+	SetHysteresis(Hysteresis)
 	Close()
 }
 
+// Hysteresis defines the switching band around the target temperature. The
+// thermostat activates once the temperature falls more than Lower below the
+// target, and deactivates once it rises more than Upper above it.
+type Hysteresis struct {
+	Lower units.Temperature `json:"lower"`
+	Upper units.Temperature `json:"upper"`
+}
+
+var DefaultHysteresis = Hysteresis{Lower: 200, Upper: 0}
+
+func (h Hysteresis) Valid() bool {
+	return h.Lower >= 0 && h.Upper >= 0
+}
+
+func (h Hysteresis) String() string {
+	return fmt.Sprintf("-%s/+%s", h.Lower, h.Upper)
+}
+
+// Idler is implemented by thermostats that accumulate the error over time.
+// While idle, e.g. when the schedule doesn't call for heat, the accumulated
+// error is discarded so that it doesn't wind up while the output can't come
+// on.
+type Idler interface {
+	SetIdle(bool)
+}
+
+// DutyCycler is implemented by thermostats that switch the output on for a
+// fraction of each cycle. These don't use the hysteresis.
+type DutyCycler interface {
+	DutyCycle() float64
+}
+
 type demandFunc func(bool)
 
 type thermostat struct {
-	id       string
-	sourceCh <-chan units.Temperature
-	demand   demandFunc
-	closeCh  chan struct{}
-
-	lock    sync.RWMutex
-	target  units.Temperature
-	current units.Temperature
-	active  bool
+	id      string
+	source  *sensor.Subscription
+	demand  demandFunc
+	closeCh chan struct{}
+
+	lock       sync.RWMutex
+	target     units.Temperature
+	current    units.Temperature
+	hysteresis Hysteresis
+	active     bool
 }
 
 func New(id string, source sensor.Sensor, target units.Temperature, df demandFunc) Thermostat {
 	initial, _ := source.Read()
 	t := &thermostat{
-		id:       id,
-		sourceCh: source.Subscribe(),
-		target:   target,
-		current:  initial,
-		demand:   df,
-		closeCh:  make(chan struct{}),
+		id:         id,
+		source:     source.Subscribe(),
+		target:     target,
+		hysteresis: DefaultHysteresis,
+		current:    initial,
+		demand:     df,
+		closeCh:    make(chan struct{}),
 	}
 
 	// Set active so that a new thermostat defaults to active when within the
@@ -75,31 +112,41 @@ func (t *thermostat) Set(tmp units.Temperature) {
 	t.trigger()
 }
 
+func (t *thermostat) Hysteresis() Hysteresis {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.hysteresis
+}
+
+func (t *thermostat) SetHysteresis(h Hysteresis) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.hysteresis = h
+	t.trigger()
+}
+
 func (t *thermostat) Close() {
-	if t.closeCh != nil {
-		close(t.closeCh)
+	if t.source != nil {
+		t.source.Cancel()
+		<-t.closeCh
 	}
 }
 
+// readLoop follows the source until the subscription ends, closing closeCh
+// once it has stopped.
 func (t *thermostat) readLoop() {
-	for {
-		select {
-		case tmp := <-t.sourceCh:
-			t.setCurrent(tmp)
-		case <-t.closeCh:
-			return
-		}
+	defer close(t.closeCh)
+	for tmp := range t.source.C {
+		t.setCurrent(tmp)
 	}
 }
 
-const threshold = 200
-
 // Must be called with the lock held for writing.
 func (t *thermostat) trigger() {
 	previousActive := t.active
-	if t.current < (t.target - threshold) {
+	if t.current < (t.target - t.hysteresis.Lower) {
 		t.active = true
-	} else if t.current > t.target { // no threshold here due to hysteresis in system.
+	} else if t.current > (t.target + t.hysteresis.Upper) {
 		t.active = false
 	}
 	if t.active != previousActive && t.demand != nil {
@@ -56,8 +56,9 @@ var _ = Describe("A Thermostat", func() {
 	Describe("setting the target temperature", func() {
 		BeforeEach(func() {
 			t = &thermostat{
-				current: 18000,
-				target:  17000,
+				current:    18000,
+				target:     17000,
+				hysteresis: DefaultHysteresis,
 			}
 		})
 
@@ -72,6 +73,43 @@ var _ = Describe("A Thermostat", func() {
 		})
 	})
 
+	Describe("setting the hysteresis", func() {
+		BeforeEach(func() {
+			t = &thermostat{
+				current:    17900,
+				target:     18000,
+				hysteresis: DefaultHysteresis,
+			}
+		})
+
+		It("defaults to the existing behaviour", func() {
+			t = New("something", sens, 19000, func(b bool) {}).(*thermostat)
+			Expect(t.Hysteresis()).To(Equal(Hysteresis{Lower: 200, Upper: 0}))
+		})
+
+		It("updates the hysteresis for the thermostat", func() {
+			t.SetHysteresis(Hysteresis{Lower: 50, Upper: 300})
+			Expect(t.Hysteresis()).To(Equal(Hysteresis{Lower: 50, Upper: 300}))
+		})
+
+		It("triggers the thermostat to update the demand state", func() {
+			t.SetHysteresis(Hysteresis{Lower: 50, Upper: 300})
+			Expect(t.active).To(BeTrue())
+		})
+	})
+
+	Describe("hysteresis validation", func() {
+		It("is valid with non-negative values", func() {
+			Expect(Hysteresis{}.Valid()).To(BeTrue())
+			Expect(Hysteresis{Lower: 200, Upper: 100}.Valid()).To(BeTrue())
+		})
+
+		It("is invalid with negative values", func() {
+			Expect(Hysteresis{Lower: -100}.Valid()).To(BeFalse())
+			Expect(Hysteresis{Upper: -100}.Valid()).To(BeFalse())
+		})
+	})
+
 	Describe("subscribing to sensor updates", func() {
 		BeforeEach(func() {
 			t = New("sonething", sens, 18000, func(b bool) {}).(*thermostat)
@@ -95,6 +133,18 @@ var _ = Describe("A Thermostat", func() {
 
 			Expect(t.active).To(BeTrue())
 		})
+
+		It("stops following the sensor once closed", func() {
+			t.Close()
+			sens.Set(21000, time.Now())
+			Consistently(t.Current, 50*time.Millisecond).Should(BeEquivalentTo(19000))
+		})
+
+		It("keeps the last reading once the sensor is closed", func() {
+			sens.Close()
+			Eventually(t.closeCh).Should(BeClosed())
+			Expect(t.Current()).To(BeEquivalentTo(19000))
+		})
 	})
 
 	type TriggeringCase struct {
@@ -103,16 +153,22 @@ var _ = Describe("A Thermostat", func() {
 		CurrentlyActive    bool
 		ExpectedActive     bool
 		ExpectDemandCalled bool
+		Hysteresis         *Hysteresis // Defaults to DefaultHysteresis
 	}
 
 	DescribeTable("triggering changes in state",
 		func(c TriggeringCase) {
 			demandCalled := false
 			demandNotify := make(chan struct{})
+			hysteresis := DefaultHysteresis
+			if c.Hysteresis != nil {
+				hysteresis = *c.Hysteresis
+			}
 			t := &thermostat{
-				current: c.Current,
-				target:  c.Target,
-				active:  c.CurrentlyActive,
+				current:    c.Current,
+				target:     c.Target,
+				hysteresis: hysteresis,
+				active:     c.CurrentlyActive,
 				demand: func(param bool) {
 					defer GinkgoRecover()
 					demandCalled = true
@@ -163,5 +219,25 @@ var _ = Describe("A Thermostat", func() {
 			Current: 18050, Target: 18000, CurrentlyActive: false,
 			ExpectedActive: false, ExpectDemandCalled: false,
 		}),
+		Entry("activates when current below a custom lower threshold", TriggeringCase{
+			Current: 17950, Target: 18000, CurrentlyActive: false,
+			Hysteresis:     &Hysteresis{Lower: 0, Upper: 0},
+			ExpectedActive: true, ExpectDemandCalled: true,
+		}),
+		Entry("remains inactive when current within a custom lower threshold", TriggeringCase{
+			Current: 17600, Target: 18000, CurrentlyActive: false,
+			Hysteresis:     &Hysteresis{Lower: 500, Upper: 0},
+			ExpectedActive: false, ExpectDemandCalled: false,
+		}),
+		Entry("remains active when current within a custom upper threshold", TriggeringCase{
+			Current: 18200, Target: 18000, CurrentlyActive: true,
+			Hysteresis:     &Hysteresis{Lower: 200, Upper: 300},
+			ExpectedActive: true, ExpectDemandCalled: false,
+		}),
+		Entry("deactivates when current above a custom upper threshold", TriggeringCase{
+			Current: 18350, Target: 18000, CurrentlyActive: true,
+			Hysteresis:     &Hysteresis{Lower: 200, Upper: 300},
+			ExpectedActive: false, ExpectDemandCalled: true,
+		}),
 	)
 })
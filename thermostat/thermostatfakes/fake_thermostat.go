@@ -23,11 +23,26 @@ type FakeThermostat struct {
 	currentReturnsOnCall map[int]struct {
 		result1 units.Temperature
 	}
+	HysteresisStub        func() thermostat.Hysteresis
+	hysteresisMutex       sync.RWMutex
+	hysteresisArgsForCall []struct {
+	}
+	hysteresisReturns struct {
+		result1 thermostat.Hysteresis
+	}
+	hysteresisReturnsOnCall map[int]struct {
+		result1 thermostat.Hysteresis
+	}
 	SetStub        func(units.Temperature)
 	setMutex       sync.RWMutex
 	setArgsForCall []struct {
 		arg1 units.Temperature
 	}
+	SetHysteresisStub        func(thermostat.Hysteresis)
+	setHysteresisMutex       sync.RWMutex
+	setHysteresisArgsForCall []struct {
+		arg1 thermostat.Hysteresis
+	}
 	TargetStub        func() units.Temperature
 	targetMutex       sync.RWMutex
 	targetArgsForCall []struct {
@@ -101,6 +116,48 @@ func (fake *FakeThermostat) CurrentReturnsOnCall(i int, result1 units.Temperatur
 	}{result1}
 }
 
+func (fake *FakeThermostat) Hysteresis() thermostat.Hysteresis {
+	fake.hysteresisMutex.Lock()
+	ret, specificReturn := fake.hysteresisReturnsOnCall[len(fake.hysteresisArgsForCall)]
+	fake.hysteresisArgsForCall = append(fake.hysteresisArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Hysteresis", []interface{}{})
+	fake.hysteresisMutex.Unlock()
+	if fake.HysteresisStub != nil {
+		return fake.HysteresisStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.hysteresisReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeThermostat) HysteresisCallCount() int {
+	fake.hysteresisMutex.RLock()
+	defer fake.hysteresisMutex.RUnlock()
+	return len(fake.hysteresisArgsForCall)
+}
+
+func (fake *FakeThermostat) HysteresisReturns(result1 thermostat.Hysteresis) {
+	fake.HysteresisStub = nil
+	fake.hysteresisReturns = struct {
+		result1 thermostat.Hysteresis
+	}{result1}
+}
+
+func (fake *FakeThermostat) HysteresisReturnsOnCall(i int, result1 thermostat.Hysteresis) {
+	fake.HysteresisStub = nil
+	if fake.hysteresisReturnsOnCall == nil {
+		fake.hysteresisReturnsOnCall = make(map[int]struct {
+			result1 thermostat.Hysteresis
+		})
+	}
+	fake.hysteresisReturnsOnCall[i] = struct {
+		result1 thermostat.Hysteresis
+	}{result1}
+}
+
 func (fake *FakeThermostat) Set(arg1 units.Temperature) {
 	fake.setMutex.Lock()
 	fake.setArgsForCall = append(fake.setArgsForCall, struct {
@@ -126,6 +183,31 @@ func (fake *FakeThermostat) SetArgsForCall(i int) units.Temperature {
 	return argsForCall.arg1
 }
 
+func (fake *FakeThermostat) SetHysteresis(arg1 thermostat.Hysteresis) {
+	fake.setHysteresisMutex.Lock()
+	fake.setHysteresisArgsForCall = append(fake.setHysteresisArgsForCall, struct {
+		arg1 thermostat.Hysteresis
+	}{arg1})
+	fake.recordInvocation("SetHysteresis", []interface{}{arg1})
+	fake.setHysteresisMutex.Unlock()
+	if fake.SetHysteresisStub != nil {
+		fake.SetHysteresisStub(arg1)
+	}
+}
+
+func (fake *FakeThermostat) SetHysteresisCallCount() int {
+	fake.setHysteresisMutex.RLock()
+	defer fake.setHysteresisMutex.RUnlock()
+	return len(fake.setHysteresisArgsForCall)
+}
+
+func (fake *FakeThermostat) SetHysteresisArgsForCall(i int) thermostat.Hysteresis {
+	fake.setHysteresisMutex.RLock()
+	defer fake.setHysteresisMutex.RUnlock()
+	argsForCall := fake.setHysteresisArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeThermostat) Target() units.Temperature {
 	fake.targetMutex.Lock()
 	ret, specificReturn := fake.targetReturnsOnCall[len(fake.targetArgsForCall)]
@@ -175,8 +257,12 @@ func (fake *FakeThermostat) Invocations() map[string][][]interface{} {
 	defer fake.closeMutex.RUnlock()
 	fake.currentMutex.RLock()
 	defer fake.currentMutex.RUnlock()
+	fake.hysteresisMutex.RLock()
+	defer fake.hysteresisMutex.RUnlock()
 	fake.setMutex.RLock()
 	defer fake.setMutex.RUnlock()
+	fake.setHysteresisMutex.RLock()
+	defer fake.setHysteresisMutex.RUnlock()
 	fake.targetMutex.RLock()
 	defer fake.targetMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -0,0 +1,68 @@
+package thermostat
+
+import (
+	"time"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/units"
+)
+
+// TPISettings configures a time-proportional integral thermostat.
+type TPISettings struct {
+	// The length of each on/off cycle.
+	CycleLength time.Duration
+	// The error below target at which the output is on for the whole cycle.
+	ProportionalBand units.Temperature
+	// The time over which a constant error doubles the on-fraction. Zero
+	// disables the integral term.
+	IntegralTime time.Duration
+}
+
+// DefaultTPISettings gives 6 cycles an hour, with the output fully on when
+// 2°C below target.
+var DefaultTPISettings = TPISettings{
+	CycleLength:      10 * time.Minute,
+	ProportionalBand: 2000,
+	IntegralTime:     time.Hour,
+}
+
+type tpiThermostat struct {
+	*dutyCycleThermostat
+	settings TPISettings
+	integral units.Temperature
+}
+
+// NewTPI returns a thermostat using time-proportional integral control. Each
+// cycle the output is switched on for a fraction of the cycle calculated from
+// the error against the target.
+func NewTPI(id string, source sensor.Sensor, target units.Temperature, settings TPISettings, df demandFunc) Thermostat {
+	t := &tpiThermostat{
+		dutyCycleThermostat: newDutyCycleThermostat(id, source, target, settings.CycleLength, df),
+		settings:            settings,
+	}
+	t.start(t.calculateDutyCycle)
+	return t
+}
+
+func (t *tpiThermostat) calculateDutyCycle(target, current units.Temperature) float64 {
+	err := target - current
+	band := t.settings.ProportionalBand
+	if t.idle {
+		t.integral = 0
+	} else if t.settings.IntegralTime > 0 {
+		// Anti-windup: don't accumulate any further error that would push an
+		// already saturated output further into saturation.
+		integral := t.integral + units.Temperature(float64(err)*t.settings.CycleLength.Seconds()/t.settings.IntegralTime.Seconds())
+		output := float64(err+integral) / float64(band)
+		if !(output > 1 && err > 0) && !(output < 0 && err < 0) {
+			t.integral = integral
+		}
+		// Limit the integral term itself to the range of the output.
+		if t.integral > band {
+			t.integral = band
+		} else if t.integral < -band {
+			t.integral = -band
+		}
+	}
+	return float64(err+t.integral) / float64(band)
+}
@@ -0,0 +1,229 @@
+package thermostat
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/ticker"
+	"github.com/alext/heating-controller/units"
+)
+
+type dummyTicker struct {
+	duration time.Duration
+	C        chan time.Time
+	notify   chan struct{}
+	stopped  chan struct{}
+}
+
+func (t dummyTicker) Channel() <-chan time.Time {
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+	return t.C
+}
+func (t *dummyTicker) Stop() {
+	close(t.stopped)
+}
+
+// currentOnSteps returns the number of steps the output is on for in the
+// current cycle.
+func currentOnSteps(t *dutyCycleThermostat) int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.onSteps
+}
+
+var _ = Describe("A TPI thermostat", func() {
+	var (
+		t         *tpiThermostat
+		sens      sensor.SettableSensor
+		settings  TPISettings
+		demands   chan bool
+		tkr       *dummyTicker
+		tkrNotify chan struct{}
+	)
+
+	BeforeEach(func() {
+		t = nil
+		sens = sensor.NewPushSensor("foo", "something")
+		sens.Set(19000, time.Now())
+		settings = TPISettings{
+			CycleLength:      10 * time.Minute,
+			ProportionalBand: 2000,
+		}
+		demands = make(chan bool, 10)
+		tkrNotify = make(chan struct{}, 1)
+
+		newTicker = func(d time.Duration) ticker.Ticker {
+			tkr = &dummyTicker{
+				duration: d,
+				C:        make(chan time.Time, 1),
+				notify:   tkrNotify,
+				stopped:  make(chan struct{}),
+			}
+			return tkr
+		}
+	})
+
+	AfterEach(func() {
+		if t != nil {
+			t.Close()
+		}
+	})
+
+	var newTPI = func(target units.Temperature) *tpiThermostat {
+		// Capture the channel, as demands may still be sent after the test.
+		ch := demands
+		return NewTPI("something", sens, target, settings, func(b bool) { ch <- b }).(*tpiThermostat)
+	}
+
+	var integral = func() units.Temperature {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+		return t.integral
+	}
+
+	// Advances the given number of steps through the cycle, waiting for each
+	// tick to be processed.
+	var tick = func(n int) {
+		for i := 0; i < n; i++ {
+			tkr.C <- time.Now()
+			<-tkrNotify
+		}
+	}
+
+	It("starts a ticker to step through each cycle", func(done Done) {
+		t = newTPI(20000)
+		<-tkrNotify
+		Expect(tkr.duration).To(Equal(30 * time.Second))
+		close(done)
+	})
+
+	It("is on for a fraction of the cycle proportional to the error", func(done Done) {
+		// 1°C below target with a 2°C band
+		t = newTPI(20000)
+		<-tkrNotify
+		Consistently(demands).ShouldNot(Receive())
+
+		tick(9)
+		Consistently(demands).ShouldNot(Receive())
+		tick(1)
+		Eventually(demands).Should(Receive(BeFalse()))
+
+		tick(9)
+		Consistently(demands).ShouldNot(Receive())
+		tick(1)
+		Eventually(demands).Should(Receive(BeTrue()), "should turn back on at the start of the next cycle")
+		close(done)
+	})
+
+	It("stays off for the whole cycle when at or above target", func(done Done) {
+		t = newTPI(19000)
+		<-tkrNotify
+		Eventually(demands).Should(Receive(BeFalse()))
+
+		tick(19)
+		Consistently(demands).ShouldNot(Receive())
+		close(done)
+	})
+
+	It("stays on for the whole cycle when below target by more than the proportional band", func(done Done) {
+		t = newTPI(22000)
+		<-tkrNotify
+
+		tick(25)
+		Consistently(demands).ShouldNot(Receive())
+		close(done)
+	})
+
+	It("recalculates the on-fraction at the start of each cycle", func(done Done) {
+		t = newTPI(20000)
+		<-tkrNotify
+
+		sens.Set(20500, time.Now())
+		Eventually(t.Current).Should(BeEquivalentTo(20500))
+
+		tick(10)
+		Eventually(demands).Should(Receive(BeFalse()))
+		tick(10)
+		Consistently(demands).ShouldNot(Receive())
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(0))
+		close(done)
+	})
+
+	It("applies a target change from the start of the next cycle", func(done Done) {
+		t = newTPI(19000)
+		<-tkrNotify
+		Eventually(demands).Should(Receive(BeFalse()))
+
+		t.Set(21000)
+		Expect(t.Target()).To(BeEquivalentTo(21000))
+		tick(19)
+		Consistently(demands).ShouldNot(Receive())
+		tick(1)
+		Eventually(demands).Should(Receive(BeTrue()))
+		close(done)
+	})
+
+	It("increases the on-fraction with a persistent error when using an integral term", func(done Done) {
+		settings.IntegralTime = 20 * time.Minute
+		// 0.5°C below target, with the integral term adding half the error each cycle
+		t = newTPI(19500)
+		<-tkrNotify
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(8))
+
+		tick(20)
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(10))
+		close(done)
+	})
+
+	It("doesn't accumulate the error while the output is saturated", func(done Done) {
+		settings.IntegralTime = 20 * time.Minute
+		// 3°C below target with a 2°C band
+		t = newTPI(22000)
+		<-tkrNotify
+		tick(60)
+		Expect(integral()).To(BeZero())
+
+		// Once at target, the output goes off rather than overshooting.
+		sens.Set(22000, time.Now())
+		Eventually(t.Current).Should(BeEquivalentTo(22000))
+		tick(20)
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(0))
+		close(done)
+	})
+
+	It("discards the accumulated error while idle", func(done Done) {
+		settings.IntegralTime = 20 * time.Minute
+		// 0.5°C below target, with the integral term adding half the error each cycle
+		t = newTPI(19500)
+		<-tkrNotify
+		Expect(integral()).To(BeEquivalentTo(250))
+
+		t.SetIdle(true)
+		tick(20)
+		Expect(integral()).To(BeZero())
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(5))
+		tick(20)
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(5))
+
+		t.SetIdle(false)
+		tick(20)
+		Expect(integral()).To(BeEquivalentTo(250))
+		Expect(currentOnSteps(t.dutyCycleThermostat)).To(Equal(8))
+		close(done)
+	})
+
+	It("stops the ticker when closed", func(done Done) {
+		t = newTPI(20000)
+		<-tkrNotify
+		t.Close()
+		Expect(tkr.stopped).To(BeClosed())
+		t = nil
+		close(done)
+	})
+})
@@ -0,0 +1,24 @@
+// Package ticker wraps time.Ticker so that tests can substitute another
+// implementation.
+package ticker
+
+import "time"
+
+// Ticker is the interface of the wrapper around time.Ticker.
+type Ticker interface {
+	Channel() <-chan time.Time
+	Stop()
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+// New returns a Ticker backed by a time.Ticker with the given period.
+func New(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (t realTicker) Channel() <-chan time.Time {
+	return t.Ticker.C
+}
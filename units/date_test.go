@@ -0,0 +1,70 @@
+package units_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("Date", func() {
+
+	It("normalises out of range values", func() {
+		Expect(units.NewDate(2018, 12, 32)).To(Equal(units.NewDate(2019, 1, 1)))
+	})
+
+	It("returns the date of a time in its own location", func() {
+		t := time.Date(2018, 9, 25, 23, 30, 0, 0, time.FixedZone("UTC-3", -3*60*60))
+		Expect(units.DateOf(t)).To(Equal(units.NewDate(2018, 9, 25)))
+	})
+
+	It("compares dates", func() {
+		d := units.NewDate(2018, 9, 25)
+		Expect(d.Before(units.NewDate(2018, 9, 26))).To(BeTrue())
+		Expect(d.Before(units.NewDate(2018, 10, 1))).To(BeTrue())
+		Expect(d.Before(units.NewDate(2019, 1, 1))).To(BeTrue())
+		Expect(d.Before(d)).To(BeFalse())
+		Expect(d.Before(units.NewDate(2018, 9, 24))).To(BeFalse())
+	})
+
+	It("adds days across month boundaries", func() {
+		Expect(units.NewDate(2018, 9, 30).AddDays(1)).To(Equal(units.NewDate(2018, 10, 1)))
+		Expect(units.NewDate(2018, 10, 1).AddDays(-1)).To(Equal(units.NewDate(2018, 9, 30)))
+	})
+
+	It("returns the day of the week", func() {
+		Expect(units.NewDate(2018, 9, 25).Weekday()).To(Equal(time.Tuesday))
+	})
+
+	It("returns the time.Time for a time of day on the date", func() {
+		london, err := time.LoadLocation("Europe/London")
+		Expect(err).NotTo(HaveOccurred())
+		actual := units.NewDate(2018, 10, 28).At(units.NewTimeOfDay(14, 15), london)
+		Expect(actual).To(Equal(time.Date(2018, 10, 28, 14, 15, 0, 0, london)))
+	})
+
+	It("is zero only for the zero value", func() {
+		Expect(units.Date{}.IsZero()).To(BeTrue())
+		Expect(units.NewDate(2018, 9, 25).IsZero()).To(BeFalse())
+	})
+
+	Describe("text marshalling/unmarshalling", func() {
+		It("round trips through JSON", func() {
+			d := units.NewDate(2018, 9, 5)
+			Expect(json.Marshal(d)).To(BeEquivalentTo(`"2018-09-05"`))
+
+			var actual units.Date
+			Expect(json.Unmarshal([]byte(`"2018-09-05"`), &actual)).To(Succeed())
+			Expect(actual).To(Equal(d))
+		})
+
+		It("errors for an invalid date", func() {
+			var d units.Date
+			Expect(json.Unmarshal([]byte(`"2018-02-30"`), &d)).NotTo(Succeed())
+			Expect(json.Unmarshal([]byte(`"fooey"`), &d)).NotTo(Succeed())
+		})
+	})
+})
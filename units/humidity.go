@@ -0,0 +1,15 @@
+package units
+
+import "strconv"
+
+// Humidity is a relative humidity in thousandths of a percent.
+type Humidity int
+
+// Float returns the humidity as a percentage.
+func (h Humidity) Float() float64 {
+	return float64(h) / 1000
+}
+
+func (h Humidity) String() string {
+	return strconv.FormatFloat(h.Float(), 'f', -1, 64) + "%"
+}
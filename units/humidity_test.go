@@ -0,0 +1,19 @@
+package units_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("Humidity", func() {
+	DescribeTable("formatting as a string",
+		func(input int, expected string) {
+			Expect(units.Humidity(input).String()).To(Equal(expected))
+		},
+		Entry("returns humidity in %", 48500, "48.5%"),
+		Entry("omits decimals when not needed", 60000, "60%"),
+	)
+})
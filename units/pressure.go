@@ -0,0 +1,15 @@
+package units
+
+import "strconv"
+
+// Pressure is an atmospheric pressure in pascals.
+type Pressure int
+
+// Float returns the pressure in hectopascals.
+func (p Pressure) Float() float64 {
+	return float64(p) / 100
+}
+
+func (p Pressure) String() string {
+	return strconv.FormatFloat(p.Float(), 'f', -1, 64) + "hPa"
+}
@@ -0,0 +1,19 @@
+package units_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("Pressure", func() {
+	DescribeTable("formatting as a string",
+		func(input int, expected string) {
+			Expect(units.Pressure(input).String()).To(Equal(expected))
+		},
+		Entry("returns pressure in hPa", 101325, "1013.25hPa"),
+		Entry("omits decimals when not needed", 99800, "998hPa"),
+	)
+})
@@ -1,6 +1,7 @@
 package units
 
 import (
+	"math"
 	"strconv"
 	"strings"
 )
@@ -15,7 +16,7 @@ func ParseTemperature(input string) (Temperature, error) {
 	if err != nil {
 		return 0, err
 	}
-	return Temperature(f * 1000), nil
+	return Temperature(math.Round(f * 1000)), nil
 }
 
 func (t Temperature) Float() float64 {
@@ -35,6 +35,7 @@ var _ = Describe("Temperature", func() {
 		Entry("handles integers", "19", 19000, true),
 		Entry("handles decimals", "19.5", 19500, true),
 		Entry("handles negatives", "-3", -3000, true),
+		Entry("rounds to the nearest thousandth", "1.005", 1005, true),
 		Entry("handles integer with unit", "20°C", 20000, true),
 		Entry("handles decimal with unit", "20.1°C", 20100, true),
 		Entry("errors with invalid input", "foo", 0, false),
@@ -73,8 +73,14 @@ func (t TimeOfDay) Valid() bool {
 	return t <= maxValidTOD
 }
 
+// OnDay returns the time.Time for this time of day on the same day as the
+// given time.
+func (t TimeOfDay) OnDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, day.Location())
+}
+
 func (t TimeOfDay) NextOccuranceAfter(current time.Time) time.Time {
-	next := time.Date(current.Year(), current.Month(), current.Day(), t.Hour(), t.Minute(), t.Second(), 0, current.Location())
+	next := t.OnDay(current)
 	if next.Before(current) {
 		next = next.AddDate(0, 0, 1)
 	}
@@ -72,6 +72,14 @@ var _ = Describe("TimeOfDay", func() {
 		})
 	})
 
+	Describe("OnDay", func() {
+		It("returns the time.Time on the same day as the given time", func() {
+			tod := units.NewTimeOfDay(6, 30, 15)
+			actual := tod.OnDay(time.Date(2018, 9, 25, 23, 10, 0, 0, time.UTC))
+			Expect(actual).To(Equal(time.Date(2018, 9, 25, 6, 30, 15, 0, time.UTC)))
+		})
+	})
+
 	DescribeTable("Text marshalling/unmarshalling",
 		func(t units.TimeOfDay, serialised string) {
 			str := `"` + serialised + `"`
@@ -0,0 +1,134 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Weekdays is a set of days of the week. The zero value represents every day
+// so that anything without any days specified applies to the whole week.
+type Weekdays uint8
+
+const allWeekdays Weekdays = 1<<7 - 1
+
+var weekdayNames = [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// Weekdays are displayed starting on Monday.
+var weekdayOrder = [7]time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+func NewWeekdays(days ...time.Weekday) Weekdays {
+	var d Weekdays
+	for _, day := range days {
+		d |= 1 << uint(day)
+	}
+	if d == allWeekdays {
+		return 0
+	}
+	return d
+}
+
+// ParseWeekdays parses a comma separated list of day names or ranges of day
+// names (eg "Mon-Fri,Sun"). An empty string represents every day.
+func ParseWeekdays(input string) (Weekdays, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := parseWeekday(bounds[0])
+		if err != nil {
+			return 0, err
+		}
+		end := start
+		if len(bounds) == 2 {
+			end, err = parseWeekday(bounds[1])
+			if err != nil {
+				return 0, err
+			}
+		}
+		for i := indexOfWeekday(start); ; i = (i + 1) % 7 {
+			days = append(days, weekdayOrder[i])
+			if weekdayOrder[i] == end {
+				break
+			}
+		}
+	}
+	return NewWeekdays(days...), nil
+}
+
+func parseWeekday(input string) (time.Weekday, error) {
+	input = strings.TrimSpace(input)
+	for i, name := range weekdayNames {
+		if strings.EqualFold(input, name) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid weekday: %s", input)
+}
+
+func indexOfWeekday(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+func (d Weekdays) Includes(day time.Weekday) bool {
+	return d == 0 || d&(1<<uint(day)) != 0
+}
+
+// Overlaps returns whether the two sets have any days in common.
+func (d Weekdays) Overlaps(other Weekdays) bool {
+	return d == 0 || other == 0 || d&other != 0
+}
+
+// Days returns the individual days in the set, starting on Monday.
+func (d Weekdays) Days() []time.Weekday {
+	days := make([]time.Weekday, 0, 7)
+	for _, day := range weekdayOrder {
+		if d.Includes(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// String returns the days in the set with any runs of 3 or more consecutive
+// days collapsed into a range (eg "Mon-Fri,Sun").
+func (d Weekdays) String() string {
+	if d == 0 || d == allWeekdays {
+		return ""
+	}
+	var parts []string
+	for i := 0; i < 7; i++ {
+		if !d.Includes(weekdayOrder[i]) {
+			continue
+		}
+		j := i
+		for j+1 < 7 && d.Includes(weekdayOrder[j+1]) {
+			j++
+		}
+		switch j - i {
+		case 0:
+			parts = append(parts, weekdayNames[weekdayOrder[i]])
+		case 1:
+			parts = append(parts, weekdayNames[weekdayOrder[i]], weekdayNames[weekdayOrder[j]])
+		default:
+			parts = append(parts, weekdayNames[weekdayOrder[i]]+"-"+weekdayNames[weekdayOrder[j]])
+		}
+		i = j
+	}
+	return strings.Join(parts, ",")
+}
+
+func (d Weekdays) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Weekdays) UnmarshalText(data []byte) error {
+	var err error
+	*d, err = ParseWeekdays(string(data))
+	return err
+}
@@ -0,0 +1,94 @@
+package units_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/units"
+)
+
+var _ = Describe("Weekdays", func() {
+
+	Describe("checking whether a day is included", func() {
+		It("includes every day for the zero value", func() {
+			var d units.Weekdays
+			for day := time.Sunday; day <= time.Saturday; day++ {
+				Expect(d.Includes(day)).To(BeTrue())
+			}
+		})
+
+		It("includes only the given days", func() {
+			d := units.NewWeekdays(time.Saturday, time.Sunday)
+			Expect(d.Includes(time.Saturday)).To(BeTrue())
+			Expect(d.Includes(time.Sunday)).To(BeTrue())
+			Expect(d.Includes(time.Monday)).To(BeFalse())
+			Expect(d.Includes(time.Friday)).To(BeFalse())
+		})
+
+		It("normalises a set of all days to the zero value", func() {
+			d := units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday)
+			Expect(d).To(BeZero())
+		})
+	})
+
+	DescribeTable("checking whether two sets overlap",
+		func(a, b units.Weekdays, expected bool) {
+			Expect(a.Overlaps(b)).To(Equal(expected))
+			Expect(b.Overlaps(a)).To(Equal(expected))
+		},
+		Entry("every day with some days", units.NewWeekdays(), units.NewWeekdays(time.Monday), true),
+		Entry("sets with a day in common", units.NewWeekdays(time.Monday, time.Tuesday), units.NewWeekdays(time.Tuesday), true),
+		Entry("sets with no days in common", units.NewWeekdays(time.Monday, time.Tuesday), units.NewWeekdays(time.Saturday), false),
+	)
+
+	It("lists the days starting on Monday", func() {
+		d := units.NewWeekdays(time.Sunday, time.Wednesday, time.Monday)
+		Expect(d.Days()).To(Equal([]time.Weekday{time.Monday, time.Wednesday, time.Sunday}))
+	})
+
+	DescribeTable("formatting and parsing",
+		func(d units.Weekdays, expected string) {
+			Expect(d.String()).To(Equal(expected))
+
+			parsed, err := units.ParseWeekdays(expected)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(d))
+
+			str := `"` + expected + `"`
+			Expect(json.Marshal(d)).To(BeEquivalentTo(str))
+			var actual units.Weekdays
+			Expect(json.Unmarshal([]byte(str), &actual)).To(Succeed())
+			Expect(actual).To(Equal(d))
+		},
+		Entry("every day", units.NewWeekdays(), ""),
+		Entry("a single day", units.NewWeekdays(time.Tuesday), "Tue"),
+		Entry("two consecutive days", units.NewWeekdays(time.Saturday, time.Sunday), "Sat,Sun"),
+		Entry("a range of days", units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday), "Mon-Fri"),
+		Entry("a mixture", units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Friday, time.Sunday), "Mon-Wed,Fri,Sun"),
+	)
+
+	DescribeTable("parsing alternative forms",
+		func(input string, expected units.Weekdays) {
+			d, err := units.ParseWeekdays(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(d).To(Equal(expected))
+		},
+		Entry("lower case names", "mon,tue", units.NewWeekdays(time.Monday, time.Tuesday)),
+		Entry("whitespace", " Mon , Wed ", units.NewWeekdays(time.Monday, time.Wednesday)),
+		Entry("a range wrapping round the end of the week", "Fri-Mon", units.NewWeekdays(time.Friday, time.Saturday, time.Sunday, time.Monday)),
+		Entry("a full week", "Mon-Sun", units.NewWeekdays()),
+	)
+
+	DescribeTable("parsing errors",
+		func(input string) {
+			_, err := units.ParseWeekdays(input)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("an unknown day", "Mon,Foo"),
+		Entry("an invalid range", "Mon-Bar"),
+	)
+})
@@ -0,0 +1,85 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/units"
+)
+
+// The format used by datetime-local form inputs.
+const awayFormTimeFormat = "2006-01-02T15:04"
+
+type jsonAway struct {
+	Away *controller.AwayMode `json:"away"`
+}
+
+func (srv *WebServer) awayGet(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, jsonAway{Away: srv.controller.Away()})
+}
+
+func (srv *WebServer) awayPut(w http.ResponseWriter, req *http.Request) {
+	var a controller.AwayMode
+	err := json.NewDecoder(req.Body).Decode(&a)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	err = srv.controller.SetAway(a)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, jsonAway{Away: srv.controller.Away()})
+}
+
+func (srv *WebServer) awaySet(w http.ResponseWriter, req *http.Request) {
+	a, err := awayFromRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = srv.controller.SetAway(a)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, req, "/", http.StatusFound)
+}
+
+func (srv *WebServer) awayCancel(w http.ResponseWriter, req *http.Request) {
+	log.Printf("[webserver] cancelling away mode")
+	err := srv.controller.CancelAway()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, req, "/", http.StatusFound)
+}
+
+func awayFromRequest(req *http.Request) (controller.AwayMode, error) {
+	var (
+		a   controller.AwayMode
+		err error
+	)
+	a.Start, err = time.ParseInLocation(awayFormTimeFormat, req.FormValue("start"), time.Local)
+	if err != nil {
+		return a, errors.New("invalid start: " + err.Error())
+	}
+	a.End, err = time.ParseInLocation(awayFormTimeFormat, req.FormValue("end"), time.Local)
+	if err != nil {
+		return a, errors.New("invalid end: " + err.Error())
+	}
+	if req.FormValue("target") != "" {
+		target, err := units.ParseTemperature(req.FormValue("target"))
+		if err != nil {
+			return a, errors.New("target must be a number: " + err.Error())
+		}
+		a.Target = &target
+	}
+	return a, nil
+}
@@ -0,0 +1,151 @@
+package webserver_test
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/units"
+	"github.com/alext/heating-controller/webserver"
+)
+
+var _ = Describe("away controller", func() {
+	var (
+		ctrl        *controller.Controller
+		server      *webserver.WebServer
+		tempDataDir string
+		start, end  time.Time
+	)
+
+	BeforeEach(func() {
+		tempDataDir, _ = ioutil.TempDir("", "away_controller_test")
+		controller.DataDir = tempDataDir
+		ctrl = controller.New()
+		ctrl.AddZone(controller.NewZone("one", output.Virtual("one")))
+		server = webserver.New(ctrl, 8080, "", nil)
+
+		start = time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+		end = start.Add(7 * 24 * time.Hour)
+	})
+
+	AfterEach(func() {
+		ctrl.CancelAway()
+		os.RemoveAll(tempDataDir)
+	})
+
+	Describe("reading the away mode", func() {
+		It("returns null when no away mode is set", func() {
+			resp := doGetRequest(server, "/away")
+			Expect(resp.Code).To(Equal(200))
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(resp.Body.String()).To(MatchJSON(`{"away":null}`))
+		})
+
+		It("returns the current away mode", func() {
+			var target units.Temperature = 7000
+			ctrl.SetAway(controller.AwayMode{Start: start, End: end, Target: &target})
+
+			resp := doGetRequest(server, "/away")
+			Expect(resp.Code).To(Equal(200))
+			data := decodeJsonResponse(resp)["away"].(map[string]interface{})
+			Expect(data["start"]).To(Equal(start.Format(time.RFC3339)))
+			Expect(data["end"]).To(Equal(end.Format(time.RFC3339)))
+			Expect(data["target"]).To(BeEquivalentTo(7000))
+		})
+	})
+
+	Describe("setting the away mode with JSON", func() {
+		It("sets the away mode and returns it", func() {
+			resp := doJSONPutRequest(server, "/away", map[string]interface{}{
+				"start":  start,
+				"end":    end,
+				"target": 8000,
+			})
+			Expect(resp.Code).To(Equal(200))
+			Expect(decodeJsonResponse(resp)).To(HaveKey("away"))
+
+			a := ctrl.Away()
+			Expect(a).NotTo(BeNil())
+			Expect(a.Start.Equal(start)).To(BeTrue())
+			Expect(a.End.Equal(end)).To(BeTrue())
+			Expect(*a.Target).To(Equal(units.Temperature(8000)))
+		})
+
+		It("returns an error for an invalid away mode", func() {
+			resp := doJSONPutRequest(server, "/away", map[string]interface{}{
+				"start": end,
+				"end":   start,
+			})
+			Expect(resp.Code).To(Equal(400))
+			Expect(ctrl.Away()).To(BeNil())
+		})
+
+		It("returns an error for invalid JSON", func() {
+			resp := doJSONPutRequest(server, "/away", "fooey")
+			Expect(resp.Code).To(Equal(400))
+		})
+	})
+
+	Describe("setting the away mode with a form", func() {
+		var values url.Values
+
+		BeforeEach(func() {
+			values = url.Values{}
+			values.Set("start", start.Format("2006-01-02T15:04"))
+			values.Set("end", end.Format("2006-01-02T15:04"))
+			values.Set("target", "")
+		})
+
+		It("sets the away mode and redirects to the index", func() {
+			resp := doRequestWithValues(server, "POST", "/away", values)
+			Expect(resp.Code).To(Equal(302))
+			Expect(resp.Header().Get("Location")).To(Equal("/"))
+
+			a := ctrl.Away()
+			Expect(a).NotTo(BeNil())
+			Expect(a.Start.Equal(start)).To(BeTrue())
+			Expect(a.End.Equal(end)).To(BeTrue())
+			Expect(a.Target).To(BeNil())
+		})
+
+		It("sets the away target when given", func() {
+			values.Set("target", "7.5")
+			doRequestWithValues(server, "POST", "/away", values)
+
+			a := ctrl.Away()
+			Expect(a).NotTo(BeNil())
+			Expect(*a.Target).To(Equal(units.Temperature(7500)))
+		})
+
+		It("returns an error with an invalid time", func() {
+			values.Set("end", "fooey")
+			resp := doRequestWithValues(server, "POST", "/away", values)
+			Expect(resp.Code).To(Equal(400))
+			Expect(ctrl.Away()).To(BeNil())
+		})
+
+		It("returns an error with an invalid target", func() {
+			values.Set("target", "warm")
+			resp := doRequestWithValues(server, "POST", "/away", values)
+			Expect(resp.Code).To(Equal(400))
+			Expect(ctrl.Away()).To(BeNil())
+		})
+	})
+
+	Describe("cancelling the away mode", func() {
+		It("cancels the away mode and redirects to the index", func() {
+			ctrl.SetAway(controller.AwayMode{Start: start, End: end})
+
+			resp := doFakeDeleteRequest(server, "/away")
+			Expect(resp.Code).To(Equal(302))
+			Expect(resp.Header().Get("Location")).To(Equal("/"))
+			Expect(ctrl.Away()).To(BeNil())
+		})
+	})
+})
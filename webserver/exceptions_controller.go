@@ -0,0 +1,119 @@
+package webserver
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/units"
+)
+
+// The number of event rows presented on the new exception form.
+const exceptionFormRows = 4
+
+var eventFields = []string{"hour", "min", "action", "therm_action", "therm_param"}
+
+type exceptionData struct {
+	Zone *controller.Zone
+	Rows []int
+}
+
+func (srv *WebServer) scheduleNewException(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
+	t, err := template.ParseFiles(
+		filepath.Join(srv.templatesPath, "_base.tmpl"),
+		filepath.Join(srv.templatesPath, "exception_new.tmpl"),
+	)
+	if err != nil {
+		log.Println("Error parsing template:", err)
+		writeError(w, err)
+		return
+	}
+	err = t.Execute(w, exceptionData{Zone: z, Rows: make([]int, exceptionFormRows)})
+	if err != nil {
+		log.Println("Error executing template:", err)
+	}
+}
+
+func (srv *WebServer) scheduleAddException(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
+	ex := controller.Exception{}
+	err := populateExceptionFromRequest(&ex, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = z.AddException(ex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = z.Save()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, req, "/zones/"+z.ID+"/schedule", 302)
+}
+
+func (srv *WebServer) scheduleRemoveException(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
+	d, err := units.ParseDate(mux.Vars(req)["date"])
+	if err != nil {
+		write404(w)
+		return
+	}
+	err = z.RemoveException(d)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = z.Save()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	http.Redirect(w, req, "/zones/"+z.ID+"/schedule", 302)
+}
+
+// populateExceptionFromRequest builds an exception from the submitted form.
+// Each event is submitted as a row of repeated event fields, and any rows
+// without an hour are ignored.
+func populateExceptionFromRequest(ex *controller.Exception, req *http.Request) error {
+	err := req.ParseForm()
+	if err != nil {
+		return err
+	}
+	ex.Date, err = units.ParseDate(req.Form.Get("date"))
+	if err != nil {
+		return errors.New("invalid date: " + err.Error())
+	}
+	ex.Name = req.Form.Get("name")
+
+	ex.Events = make([]controller.Event, 0)
+	for i := range req.Form["hour"] {
+		row := url.Values{}
+		for _, field := range eventFields {
+			if i < len(req.Form[field]) {
+				row.Set(field, req.Form[field][i])
+			}
+		}
+		if row.Get("hour") == "" {
+			continue
+		}
+		e := controller.Event{}
+		err = populateEventFromValues(&e, row)
+		if err != nil {
+			return errors.New("event " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		ex.Events = append(ex.Events, e)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package webserver_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/units"
+	"github.com/alext/heating-controller/webserver"
+)
+
+var _ = Describe("schedule exceptions controller", func() {
+	var (
+		ctrl        *controller.Controller
+		server      *webserver.WebServer
+		tempDataDir string
+		zone1       *controller.Zone
+		nextYear    units.Date
+	)
+
+	BeforeEach(func() {
+		tempDataDir, _ = ioutil.TempDir("", "exceptions_controller_test")
+		controller.DataDir = tempDataDir
+		ctrl = controller.New()
+		server = webserver.New(ctrl, 8080, "", nil)
+
+		zone1 = controller.NewZone("one", output.Virtual("one"))
+		ctrl.AddZone(zone1)
+		zone1.AddEvent(controller.Event{Time: units.NewTimeOfDay(7, 30), Action: controller.On})
+
+		nextYear = units.DateOf(time.Now().Local().AddDate(1, 0, 0))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDataDir)
+	})
+
+	Describe("adding an exception", func() {
+		var values url.Values
+
+		BeforeEach(func() {
+			values = url.Values{}
+			values.Set("date", nextYear.String())
+			values.Set("name", "Holiday")
+			values["hour"] = []string{"9", "", "22"}
+			values["min"] = []string{"15", "", "0"}
+			values["action"] = []string{"On", "On", "Off"}
+			values["therm_action"] = []string{"SetTarget", "", ""}
+			values["therm_param"] = []string{"20", "", ""}
+		})
+
+		It("should add the exception, skipping blank rows, and redirect to the schedule", func() {
+			w := doRequestWithValues(server, "POST", "/zones/one/schedule/exceptions", values)
+
+			Expect(w.Code).To(Equal(302))
+			Expect(w.Header().Get("Location")).To(Equal("/zones/one/schedule"))
+
+			Expect(zone1.ReadExceptions()).To(Equal([]controller.Exception{{
+				Date: nextYear,
+				Name: "Holiday",
+				Events: []controller.Event{
+					{
+						Time: units.NewTimeOfDay(9, 15), Action: controller.On,
+						ThermAction: &controller.ThermostatAction{Action: controller.SetTarget, Param: 20000},
+					},
+					{Time: units.NewTimeOfDay(22, 0), Action: controller.Off},
+				},
+			}}))
+		})
+
+		It("should allow an exception with no events", func() {
+			values.Del("hour")
+			w := doRequestWithValues(server, "POST", "/zones/one/schedule/exceptions", values)
+
+			Expect(w.Code).To(Equal(302))
+			exceptions := zone1.ReadExceptions()
+			Expect(exceptions).To(HaveLen(1))
+			Expect(exceptions[0].Events).To(BeEmpty())
+		})
+
+		It("should save the zone state", func() {
+			doRequestWithValues(server, "POST", "/zones/one/schedule/exceptions", values)
+
+			data := readFile(controller.DataDir + "/one.json")
+			expected, _ := json.Marshal(map[string]interface{}{
+				"events": []map[string]interface{}{
+					{"time": "7:30", "action": "On"},
+				},
+				"exceptions": []map[string]interface{}{
+					{
+						"date": nextYear.String(),
+						"name": "Holiday",
+						"events": []map[string]interface{}{
+							{"time": "9:15", "action": "On", "therm_action": map[string]interface{}{"action": "SetTarget", "param": 20000}},
+							{"time": "22:00", "action": "Off"},
+						},
+					},
+				},
+			})
+			Expect(data).To(MatchJSON(expected))
+		})
+
+		Context("with invalid input", func() {
+			It("should return an error with an invalid date", func() {
+				values.Set("date", "2018-02-30")
+				w := doRequestWithValues(server, "POST", "/zones/one/schedule/exceptions", values)
+
+				Expect(w.Code).To(Equal(400))
+				Expect(zone1.ReadExceptions()).To(BeEmpty())
+			})
+
+			It("should return an error for a date in the past", func() {
+				values.Set("date", "2018-09-28")
+				w := doRequestWithValues(server, "POST", "/zones/one/schedule/exceptions", values)
+
+				Expect(w.Code).To(Equal(400))
+				Expect(zone1.ReadExceptions()).To(BeEmpty())
+			})
+
+			It("should return an error with an invalid event", func() {
+				values["action"] = []string{"On", "On", "Sideways"}
+				w := doRequestWithValues(server, "POST", "/zones/one/schedule/exceptions", values)
+
+				Expect(w.Code).To(Equal(400))
+				Expect(zone1.ReadExceptions()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("removing an exception", func() {
+		BeforeEach(func() {
+			zone1.AddException(controller.Exception{Date: nextYear, Events: []controller.Event{}})
+		})
+
+		It("should remove the matching exception and redirect to the schedule", func() {
+			w := doFakeDeleteRequest(server, "/zones/one/schedule/exceptions/"+nextYear.String())
+
+			Expect(w.Code).To(Equal(302))
+			Expect(w.Header().Get("Location")).To(Equal("/zones/one/schedule"))
+
+			Expect(zone1.ReadExceptions()).To(BeEmpty())
+		})
+
+		It("should save the zone state", func() {
+			doFakeDeleteRequest(server, "/zones/one/schedule/exceptions/"+nextYear.String())
+
+			data := readFile(controller.DataDir + "/one.json")
+			Expect(data).To(MatchJSON(`{"events":[{"time":"7:30","action":"On"}]}`))
+		})
+
+		It("should 404 for an invalid date in the URL", func() {
+			w := doFakeDeleteRequest(server, "/zones/one/schedule/exceptions/foo")
+			Expect(w.Code).To(Equal(404))
+		})
+	})
+})
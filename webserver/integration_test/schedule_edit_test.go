@@ -2,6 +2,7 @@ package integration_test
 
 import (
 	"net/http/httptest"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -133,6 +134,29 @@ var _ = Describe("Editing the schedule for a zone", func() {
 					}))
 				})
 
+				It("should allow adding an event restricted to certain days", func() {
+					Expect(page.Navigate(testServer.URL + "/zones/one/schedule/new")).To(Succeed())
+
+					form := page.Find("form")
+					Expect(form).To(BeFound())
+
+					Expect(form.Find("input[name=hour]").Fill("9")).To(Succeed())
+					Expect(form.Find("input[name=min]").Fill("15")).To(Succeed())
+					Expect(form.Find("select[name=action]").Select("On")).To(Succeed())
+					Expect(form.Find("input[name=days][value=Sat]").Check()).To(Succeed())
+					Expect(form.Find("input[name=days][value=Sun]").Check()).To(Succeed())
+					Expect(form.Find("input[value='Add Event']").Click()).To(Succeed())
+
+					Expect(page).To(HaveURL(testServer.URL + "/zones/one/schedule"))
+					Expect(page.All("table tr").At(3).All("td").At(0)).To(HaveText("Sat,Sun 9:15 On"))
+
+					events := zone1.ReadEvents()
+					Expect(events).To(HaveLen(5))
+					Expect(events).To(ContainElement(controller.Event{
+						Time: units.NewTimeOfDay(9, 15), Days: units.NewWeekdays(time.Saturday, time.Sunday), Action: controller.On,
+					}))
+				})
+
 				It("does not show the thermostat action fields for a zone without a thermostat", func() {
 					Expect(page.Navigate(testServer.URL + "/zones/two/schedule/new")).To(Succeed())
 
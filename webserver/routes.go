@@ -14,13 +14,24 @@ func (srv *WebServer) buildRouter(metricsHandler http.Handler) http.Handler {
 
 	r.Methods("GET").Path("/sensors").HandlerFunc(srv.sensorIndex)
 	r.Methods("PUT").Path("/sensors").HandlerFunc(srv.sensorBulkPut)
+	r.Methods("GET").Path("/sensors/discover").HandlerFunc(srv.sensorDiscover)
+	r.Methods("GET").Path("/sensors/discover.json").HandlerFunc(srv.sensorDiscoverJSON)
+	r.Methods("POST").Path("/sensors/discover").HandlerFunc(srv.sensorAdopt)
 	r.Methods("GET").Path("/sensors/{sensor_id}").HandlerFunc(srv.sensorGet)
 	r.Methods("PUT").Path("/sensors/{sensor_id}").HandlerFunc(srv.sensorPut)
+	r.Methods("PUT").Path("/sensors/{sensor_id}/calibration").HandlerFunc(srv.sensorPutCalibration)
+	r.Methods("GET").Path("/sensors/{sensor_id}/history").HandlerFunc(srv.sensorHistory)
 
 	r.Methods("GET").Path("/zones").HandlerFunc(srv.zonesAPIIndex)
 
+	r.Methods("GET").Path("/away").HandlerFunc(srv.awayGet)
+	r.Methods("PUT").Path("/away").HandlerFunc(srv.awayPut)
+	r.Methods("POST").Path("/away").HandlerFunc(srv.awaySet)
+	r.Methods("DELETE").Path("/away").HandlerFunc(srv.awayCancel)
+
 	r.Methods("PUT").Path("/zones/{zone_id}/boost").HandlerFunc(srv.withZone(srv.zoneBoost))
 	r.Methods("DELETE").Path("/zones/{zone_id}/boost").HandlerFunc(srv.withZone(srv.zoneCancelBoost))
+	r.Methods("GET").Path("/zones/{zone_id}/chart.svg").HandlerFunc(srv.withZone(srv.zoneChart))
 
 	r.Methods("GET").Path("/zones/{zone_id}/schedule").HandlerFunc(srv.withZone(srv.scheduleEdit))
 	r.Methods("GET").Path("/zones/{zone_id}/schedule/new").HandlerFunc(srv.withZone(srv.scheduleNewEvent))
@@ -28,9 +39,14 @@ func (srv *WebServer) buildRouter(metricsHandler http.Handler) http.Handler {
 	r.Methods("GET").Path("/zones/{zone_id}/schedule/{time:\\d+:\\d+}").HandlerFunc(srv.withZone(srv.scheduleEditEvent))
 	r.Methods("PUT").Path("/zones/{zone_id}/schedule/{time:\\d+:\\d+}").HandlerFunc(srv.withZone(srv.scheduleUpdateEvent))
 	r.Methods("DELETE").Path("/zones/{zone_id}/schedule/{time:\\d+:\\d+}").HandlerFunc(srv.withZone(srv.scheduleRemoveEvent))
+	r.Methods("GET").Path("/zones/{zone_id}/schedule/exceptions/new").HandlerFunc(srv.withZone(srv.scheduleNewException))
+	r.Methods("POST").Path("/zones/{zone_id}/schedule/exceptions").HandlerFunc(srv.withZone(srv.scheduleAddException))
+	r.Methods("DELETE").Path("/zones/{zone_id}/schedule/exceptions/{date}").HandlerFunc(srv.withZone(srv.scheduleRemoveException))
 
 	r.Methods("POST").Path("/zones/{zone_id}/thermostat/increment").HandlerFunc(srv.withZone(srv.thermostatInc))
 	r.Methods("POST").Path("/zones/{zone_id}/thermostat/decrement").HandlerFunc(srv.withZone(srv.thermostatDec))
+	r.Methods("POST").Path("/zones/{zone_id}/thermostat/hysteresis").HandlerFunc(srv.withZone(srv.thermostatSetHysteresis))
+	r.Methods("POST").Path("/zones/{zone_id}/thermostat/hysteresis/reset").HandlerFunc(srv.withZone(srv.thermostatResetHysteresis))
 
 	r.Methods("GET").Path("/metrics").Handler(metricsHandler)
 
@@ -5,8 +5,10 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/alext/heating-controller/controller"
 	"github.com/alext/heating-controller/units"
@@ -54,17 +56,18 @@ func (srv *WebServer) scheduleNewEvent(w http.ResponseWriter, req *http.Request,
 	ed := eventData{
 		NewEvent: true,
 		Zone:     z,
+		Days:     buildDayOptions(0),
 	}
 	srv.renderEventEdit(w, ed)
 }
 
 func (srv *WebServer) scheduleEditEvent(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
-	t, err := units.ParseTimeOfDay(mux.Vars(req)["time"])
+	t, days, err := eventKeyFromRequest(req)
 	if err != nil {
 		write404(w)
 		return
 	}
-	e, ok := z.FindEvent(t)
+	e, ok := z.FindEvent(t, days)
 	if !ok {
 		write404(w)
 		return
@@ -72,8 +75,10 @@ func (srv *WebServer) scheduleEditEvent(w http.ResponseWriter, req *http.Request
 
 	ed := eventData{
 		Zone:        z,
+		EventDays:   e.Days.String(),
 		HourValue:   strconv.Itoa(e.Time.Hour()),
 		MinuteValue: strconv.Itoa(e.Time.Minute()),
+		Days:        buildDayOptions(e.Days),
 		Action:      e.Action.String(),
 	}
 	if e.ThermAction != nil {
@@ -84,13 +89,13 @@ func (srv *WebServer) scheduleEditEvent(w http.ResponseWriter, req *http.Request
 }
 
 func (srv *WebServer) scheduleUpdateEvent(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
-	t, err := units.ParseTimeOfDay(mux.Vars(req)["time"])
+	t, days, err := eventKeyFromRequest(req)
 	if err != nil {
 		write404(w)
 		return
 	}
 
-	e, ok := z.FindEvent(t)
+	e, ok := z.FindEvent(t, days)
 	if !ok {
 		write404(w)
 		return
@@ -102,7 +107,7 @@ func (srv *WebServer) scheduleUpdateEvent(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	err = z.ReplaceEvent(t, e)
+	err = z.ReplaceEvent(t, days, e)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -116,12 +121,12 @@ func (srv *WebServer) scheduleUpdateEvent(w http.ResponseWriter, req *http.Reque
 }
 
 func (srv *WebServer) scheduleRemoveEvent(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
-	t, err := units.ParseTimeOfDay(mux.Vars(req)["time"])
+	t, days, err := eventKeyFromRequest(req)
 	if err != nil {
 		write404(w)
 		return
 	}
-	err = z.RemoveEvent(t)
+	err = z.RemoveEvent(t, days)
 	if err != nil {
 		writeError(w, err)
 		return
@@ -136,16 +141,48 @@ func (srv *WebServer) scheduleRemoveEvent(w http.ResponseWriter, req *http.Reque
 	http.Redirect(w, req, "/zones/"+z.ID+"/schedule", 302)
 }
 
+// eventKeyFromRequest returns the time and days identifying an existing event.
+// The days are given in the event_days query parameter, with none meaning
+// every day, as there can be several events at the same time on different
+// days.
+func eventKeyFromRequest(req *http.Request) (units.TimeOfDay, units.Weekdays, error) {
+	t, err := units.ParseTimeOfDay(mux.Vars(req)["time"])
+	if err != nil {
+		return t, 0, err
+	}
+	days, err := units.ParseWeekdays(req.URL.Query().Get("event_days"))
+	return t, days, err
+}
+
 type eventData struct {
 	NewEvent    bool
 	Zone        *controller.Zone
+	EventDays   string
 	HourValue   string
 	MinuteValue string
+	Days        []dayOption
 	Action      string
 	ThermAction string
 	ThermParam  string
 }
 
+type dayOption struct {
+	Name    string
+	Checked bool
+}
+
+func buildDayOptions(days units.Weekdays) []dayOption {
+	options := make([]dayOption, 0, 7)
+	for _, d := range units.NewWeekdays().Days() {
+		options = append(options, dayOption{
+			Name: units.NewWeekdays(d).String(),
+			// Every day is represented by no days being checked.
+			Checked: days != 0 && days.Includes(d),
+		})
+	}
+	return options
+}
+
 func (srv *WebServer) renderEventEdit(w http.ResponseWriter, ed eventData) {
 	t, err := template.ParseFiles(
 		filepath.Join(srv.templatesPath, "_base.tmpl"),
@@ -163,27 +200,40 @@ func (srv *WebServer) renderEventEdit(w http.ResponseWriter, ed eventData) {
 }
 
 func populateEventFromRequest(e *controller.Event, req *http.Request) error {
-	hour, err := strconv.Atoi(req.FormValue("hour"))
+	err := req.ParseForm()
+	if err != nil {
+		return err
+	}
+	return populateEventFromValues(e, req.Form)
+}
+
+func populateEventFromValues(e *controller.Event, values url.Values) error {
+	hour, err := strconv.Atoi(values.Get("hour"))
 	if err != nil {
 		return errors.New("hour must be a number: " + err.Error())
 	}
-	min, err := strconv.Atoi(req.FormValue("min"))
+	min, err := strconv.Atoi(values.Get("min"))
 	if err != nil {
 		return errors.New("minute must be a number: " + err.Error())
 	}
 	e.Time = units.NewTimeOfDay(hour, min)
 
-	err = e.Action.UnmarshalText([]byte(req.FormValue("action")))
+	e.Days, err = units.ParseWeekdays(strings.Join(values["days"], ","))
+	if err != nil {
+		return errors.New("invalid days: " + err.Error())
+	}
+
+	err = e.Action.UnmarshalText([]byte(values.Get("action")))
 	if err != nil {
 		return errors.New("invalid action: " + err.Error())
 	}
-	if req.FormValue("therm_action") != "" {
+	if values.Get("therm_action") != "" {
 		e.ThermAction = &controller.ThermostatAction{}
-		err = e.ThermAction.Action.UnmarshalText([]byte(req.FormValue("therm_action")))
+		err = e.ThermAction.Action.UnmarshalText([]byte(values.Get("therm_action")))
 		if err != nil {
 			return errors.New("invalid thermostat action: " + err.Error())
 		}
-		param, err := units.ParseTemperature(req.FormValue("therm_param"))
+		param, err := units.ParseTemperature(values.Get("therm_param"))
 		if err != nil {
 			return errors.New("thermostat param must be a number: " + err.Error())
 		}
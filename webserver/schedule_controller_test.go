@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -81,6 +82,20 @@ var _ = Describe("schedule controller", func() {
 			}))
 		})
 
+		It("should add the event restricted to the selected days", func() {
+			values["days"] = []string{"Mon", "Tue", "Wed", "Thu", "Fri"}
+			w := doRequestWithValues(server, "POST", "/zones/one/schedule", values)
+
+			Expect(w.Code).To(Equal(302))
+
+			events := zone1.ReadEvents()
+			Expect(events).To(HaveLen(3))
+			Expect(events).To(ContainElement(controller.Event{
+				Time: units.NewTimeOfDay(10, 24), Action: controller.On,
+				Days: units.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday),
+			}))
+		})
+
 		It("should save the zone state", func() {
 			doRequestWithValues(server, "POST", "/zones/one/schedule", values)
 
@@ -137,6 +152,14 @@ var _ = Describe("schedule controller", func() {
 				Expect(zone1.ReadEvents()).To(HaveLen(2))
 			})
 
+			It("should return an error with an invalid day", func() {
+				values["days"] = []string{"Mon", "Fooday"}
+				w := doRequestWithValues(server, "POST", "/zones/one/schedule", values)
+				Expect(w.Code).To(Equal(400))
+				Expect(w.Body.String()).To(ContainSubstring("invalid days"))
+				Expect(zone1.ReadEvents()).To(HaveLen(2))
+			})
+
 			It("should return an error with a well-formed, but invalid event", func() {
 				values.Set("hour", "25")
 				w := doRequestWithValues(server, "POST", "/zones/one/schedule", values)
@@ -191,6 +214,45 @@ var _ = Describe("schedule controller", func() {
 			Expect(data).To(MatchJSON(expected))
 		})
 
+		It("should clear the days when none are selected", func() {
+			zone1.ReplaceEvent(units.NewTimeOfDay(8, 30), 0, controller.Event{
+				Time: units.NewTimeOfDay(8, 30), Days: units.NewWeekdays(time.Saturday), Action: controller.Off,
+			})
+			doFakeRequestWithValues(server, "PUT", "/zones/one/schedule/8:30?event_days=Sat", values)
+
+			events := zone1.ReadEvents()
+			Expect(events[1]).To(Equal(controller.Event{Time: units.NewTimeOfDay(10, 24), Action: controller.On}))
+		})
+
+		It("should update the event on the days given, leaving others at the same time", func() {
+			zone1.ReplaceEvent(units.NewTimeOfDay(8, 30), 0, controller.Event{
+				Time: units.NewTimeOfDay(8, 30), Days: units.NewWeekdays(time.Saturday), Action: controller.Off,
+			})
+			zone1.AddEvent(controller.Event{Time: units.NewTimeOfDay(8, 30), Days: units.NewWeekdays(time.Sunday), Action: controller.Off})
+			values.Add("days", "Sun")
+
+			w := doFakeRequestWithValues(server, "PUT", "/zones/one/schedule/8:30?event_days=Sun", values)
+			Expect(w.Code).To(Equal(302))
+
+			events := zone1.ReadEvents()
+			Expect(events).To(HaveLen(3))
+			Expect(events).To(ContainElement(controller.Event{Time: units.NewTimeOfDay(8, 30), Days: units.NewWeekdays(time.Saturday), Action: controller.Off}))
+			Expect(events).To(ContainElement(controller.Event{Time: units.NewTimeOfDay(10, 24), Days: units.NewWeekdays(time.Sunday), Action: controller.On}))
+		})
+
+		It("should 404 when the event's days don't match", func() {
+			w := doFakeRequestWithValues(server, "PUT", "/zones/one/schedule/8:30?event_days=Sat", values)
+			Expect(w.Code).To(Equal(404))
+		})
+
+		It("should return an error when the update conflicts with another event", func() {
+			values.Set("hour", "7")
+			values.Set("min", "30")
+			w := doFakeRequestWithValues(server, "PUT", "/zones/one/schedule/8:30", values)
+			Expect(w.Code).To(Equal(400))
+			Expect(zone1.ReadEvents()[1].Time).To(Equal(units.NewTimeOfDay(8, 30)))
+		})
+
 		It("should return an error with invalid data", func() {
 			values.Set("action", "fooey")
 			w := doFakeRequestWithValues(server, "PUT", "/zones/one/schedule/8:30", values)
@@ -246,6 +308,18 @@ var _ = Describe("schedule controller", func() {
 			Expect(data).To(MatchJSON(expected))
 		})
 
+		It("should only remove the event on the given days", func() {
+			zone1.AddEvent(controller.Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Saturday), Action: controller.On})
+			zone1.AddEvent(controller.Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Sunday), Action: controller.On})
+
+			w := doFakeDeleteRequest(server, "/zones/one/schedule/9:00?event_days=Sun")
+			Expect(w.Code).To(Equal(302))
+
+			events := zone1.ReadEvents()
+			Expect(events).To(HaveLen(3))
+			Expect(events[2]).To(Equal(controller.Event{Time: units.NewTimeOfDay(9, 0), Days: units.NewWeekdays(time.Saturday), Action: controller.On}))
+		})
+
 		It("should do nothing for a non-existent event", func() {
 			w := doFakeDeleteRequest(server, "/zones/one/schedule/7:40")
 
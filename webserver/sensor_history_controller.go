@@ -0,0 +1,94 @@
+package webserver
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/alext/heating-controller/sensor"
+)
+
+// defaultHistoryPeriod is the period returned when no start time is given.
+const defaultHistoryPeriod = 24 * time.Hour
+
+func (srv *WebServer) sensorHistory(w http.ResponseWriter, req *http.Request) {
+	sensorID := mux.Vars(req)["sensor_id"]
+	s, ok := srv.controller.Sensor(sensorID)
+	if !ok {
+		write404(w)
+		return
+	}
+	hs, ok := s.(sensor.HistorySensor)
+	if !ok {
+		writeError(w, fmt.Errorf("Sensor %s doesn't keep a history", sensorID), http.StatusNotFound)
+		return
+	}
+
+	from, to, step, err := historyQueryFromRequest(req)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	readings := hs.History().Query(from, to, step)
+	if readings == nil {
+		readings = []sensor.Reading{}
+	}
+
+	if req.FormValue("format") == "csv" || strings.Contains(req.Header.Get("Accept"), "text/csv") {
+		writeHistoryCSV(w, readings)
+		return
+	}
+	writeJSON(w, readings)
+}
+
+// historyQueryFromRequest parses the from and to times (RFC 3339) and step
+// (e.g. "5m") from the request. These default to the last day at full
+// resolution.
+func historyQueryFromRequest(req *http.Request) (from, to time.Time, step time.Duration, err error) {
+	to = time.Now()
+	if v := req.FormValue("to"); v != "" {
+		to, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, step, errors.New("invalid to: " + err.Error())
+		}
+	}
+	from = to.Add(-defaultHistoryPeriod)
+	if v := req.FormValue("from"); v != "" {
+		from, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, step, errors.New("invalid from: " + err.Error())
+		}
+	}
+	if from.After(to) {
+		return from, to, step, errors.New("from must be before to")
+	}
+	if v := req.FormValue("step"); v != "" {
+		step, err = time.ParseDuration(v)
+		if err != nil {
+			return from, to, step, errors.New("invalid step: " + err.Error())
+		}
+		if step < 0 {
+			return from, to, step, errors.New("step must not be negative")
+		}
+	}
+	return from, to, step, nil
+}
+
+func writeHistoryCSV(w http.ResponseWriter, readings []sensor.Reading) {
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "temperature"})
+	for _, r := range readings {
+		cw.Write([]string{r.At.Format(time.RFC3339), fmt.Sprintf("%g", r.Temperature.Float())})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("[webserver] Error writing CSV: %s", err.Error())
+	}
+}
@@ -0,0 +1,117 @@
+package webserver_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/units"
+	"github.com/alext/heating-controller/webserver"
+)
+
+var _ = Describe("sensor history controller", func() {
+	var (
+		ctrl   *controller.Controller
+		server *webserver.WebServer
+		start  time.Time
+	)
+
+	BeforeEach(func() {
+		ctrl = controller.New()
+		server = webserver.New(ctrl, 8080, "", nil)
+
+		start = time.Date(2018, 9, 28, 12, 0, 0, 0, time.UTC)
+		s := sensor.NewPushSensor("foo", "1234")
+		for i := 0; i < 30; i++ {
+			s.Set(19000+units.Temperature(i*100), start.Add(time.Duration(i)*time.Minute))
+		}
+		ctrl.AddSensor("foo", s)
+		ctrl.AddSensor("other", &dummySensor{})
+	})
+
+	var decodeReadings = func(body []byte) []map[string]interface{} {
+		var data []map[string]interface{}
+		ExpectWithOffset(1, json.Unmarshal(body, &data)).To(Succeed())
+		return data
+	}
+
+	It("returns the readings in the given range as JSON", func() {
+		resp := doGetRequest(server, "/sensors/foo/history?from=2018-09-28T12:10:00Z&to=2018-09-28T12:12:00Z")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		data := decodeReadings(resp.Body.Bytes())
+		Expect(data).To(HaveLen(3))
+		Expect(data[0]["temperature"]).To(BeEquivalentTo(20000))
+		Expect(data[0]["at"]).To(Equal("2018-09-28T12:10:00Z"))
+		Expect(data[2]["temperature"]).To(BeEquivalentTo(20200))
+	})
+
+	It("averages the readings over the given step", func() {
+		resp := doGetRequest(server, "/sensors/foo/history?from=2018-09-28T12:00:00Z&to=2018-09-28T13:00:00Z&step=15m")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+
+		data := decodeReadings(resp.Body.Bytes())
+		Expect(data).To(HaveLen(2))
+		Expect(data[0]["temperature"]).To(BeEquivalentTo(19700))
+		Expect(data[1]["at"]).To(Equal("2018-09-28T12:15:00Z"))
+		Expect(data[1]["temperature"]).To(BeEquivalentTo(21200))
+	})
+
+	It("defaults to the day before the given end time", func() {
+		resp := doGetRequest(server, "/sensors/foo/history?to=2018-09-29T12:05:00Z")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(decodeReadings(resp.Body.Bytes())).To(HaveLen(25))
+	})
+
+	It("returns an empty list when there are no readings in the range", func() {
+		resp := doGetRequest(server, "/sensors/foo/history?from=2018-09-27T12:00:00Z&to=2018-09-27T13:00:00Z")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body.String()).To(MatchJSON(`[]`))
+	})
+
+	It("returns CSV when requested", func() {
+		resp := doGetRequest(server, "/sensors/foo/history?from=2018-09-28T12:10:00Z&to=2018-09-28T12:11:00Z&format=csv")
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("Content-Type")).To(Equal("text/csv"))
+		Expect(resp.Body.String()).To(Equal("time,temperature\n2018-09-28T12:10:00Z,20\n2018-09-28T12:11:00Z,20.1\n"))
+	})
+
+	It("returns CSV when accepted", func() {
+		req, _ := http.NewRequest("GET", "http://example.com/sensors/foo/history?from=2018-09-28T12:10:00Z&to=2018-09-28T12:10:00Z", nil)
+		req.Header.Set("Accept", "text/csv")
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body.String()).To(Equal("time,temperature\n2018-09-28T12:10:00Z,20\n"))
+	})
+
+	DescribeTable("rejecting invalid queries",
+		func(query string) {
+			resp := doGetRequest(server, "/sensors/foo/history?"+query)
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		},
+		Entry("invalid from", "from=yesterday"),
+		Entry("invalid to", "to=2018-09-28"),
+		Entry("from after to", "from=2018-09-28T13:00:00Z&to=2018-09-28T12:00:00Z"),
+		Entry("invalid step", "step=5"),
+		Entry("negative step", "step=-5m"),
+	)
+
+	It("returns 404 for a non-existent sensor", func() {
+		resp := doGetRequest(server, "/sensors/non-existent/history")
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("returns 404 for a sensor without a history", func() {
+		resp := doGetRequest(server, "/sensors/other/history")
+		Expect(resp.Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -3,10 +3,13 @@ package webserver
 import (
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"path/filepath"
 	"time"
 
+	"github.com/alext/heating-controller/controller"
 	"github.com/alext/heating-controller/sensor"
 	"github.com/alext/heating-controller/units"
 	"github.com/gorilla/mux"
@@ -14,7 +17,7 @@ import (
 
 func (srv *WebServer) sensorIndex(w http.ResponseWriter, req *http.Request) {
 	data := make(map[string]*jsonSensor)
-	for name, s := range srv.controller.SensorsByName {
+	for name, s := range srv.controller.Sensors() {
 		data[name] = newJSONSensor(s)
 	}
 	writeJSON(w, data)
@@ -23,6 +26,8 @@ func (srv *WebServer) sensorIndex(w http.ResponseWriter, req *http.Request) {
 func (srv *WebServer) sensorBulkPut(w http.ResponseWriter, req *http.Request) {
 	var reqData struct {
 		Temperatures map[string]units.Temperature `json:"temperatures"`
+		Humidities   map[string]units.Humidity    `json:"humidities"`
+		Pressures    map[string]units.Pressure    `json:"pressures"`
 	}
 	err := json.NewDecoder(req.Body).Decode(&reqData)
 	if err != nil {
@@ -30,8 +35,9 @@ func (srv *WebServer) sensorBulkPut(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	now := time.Now()
 	for id, temp := range reqData.Temperatures {
-		s, ok := srv.controller.SensorsByID[id]
+		s, ok := srv.controller.SensorByID(id)
 		if !ok {
 			log.Printf("[webserver] sensor bulk update ignoring unknown sensor '%s'", id)
 			continue
@@ -41,14 +47,41 @@ func (srv *WebServer) sensorBulkPut(w http.ResponseWriter, req *http.Request) {
 			log.Printf("[webserver] sensor bulk update ignoring non-settable sensor '%s'", id)
 			continue
 		}
-		ss.Set(temp, time.Now())
+		ss.Set(temp, now)
+	}
+
+	quantities := make(map[string]sensor.Quantities)
+	for id, h := range reqData.Humidities {
+		h := h
+		q := quantities[id]
+		q.Humidity = &h
+		quantities[id] = q
+	}
+	for id, p := range reqData.Pressures {
+		p := p
+		q := quantities[id]
+		q.Pressure = &p
+		quantities[id] = q
+	}
+	for id, q := range quantities {
+		s, ok := srv.controller.SensorByID(id)
+		if !ok {
+			log.Printf("[webserver] sensor bulk update ignoring unknown sensor '%s'", id)
+			continue
+		}
+		qs, ok := s.(sensor.SettableQuantitySensor)
+		if !ok {
+			log.Printf("[webserver] sensor bulk update ignoring quantities for non-settable sensor '%s'", id)
+			continue
+		}
+		qs.SetQuantities(q, now)
 	}
 
 	fmt.Fprintln(w, "OK")
 }
 
 func (srv *WebServer) sensorGet(w http.ResponseWriter, req *http.Request) {
-	s, ok := srv.controller.SensorsByName[mux.Vars(req)["sensor_id"]]
+	s, ok := srv.controller.Sensor(mux.Vars(req)["sensor_id"])
 	if !ok {
 		write404(w)
 		return
@@ -59,7 +92,7 @@ func (srv *WebServer) sensorGet(w http.ResponseWriter, req *http.Request) {
 
 func (srv *WebServer) sensorPut(w http.ResponseWriter, req *http.Request) {
 	sensorID := mux.Vars(req)["sensor_id"]
-	s, ok := srv.controller.SensorsByName[sensorID]
+	s, ok := srv.controller.Sensor(sensorID)
 	if !ok {
 		write404(w)
 		return
@@ -73,31 +106,142 @@ func (srv *WebServer) sensorPut(w http.ResponseWriter, req *http.Request) {
 
 	var data struct {
 		Temp *units.Temperature `json:"temperature"`
+		sensor.Quantities
 	}
 	err := json.NewDecoder(req.Body).Decode(&data)
 	if err != nil {
 		writeError(w, err, http.StatusBadRequest)
 		return
 	}
-	if data.Temp == nil {
+	if data.Temp == nil && data.Quantities.Empty() {
 		writeError(w, fmt.Errorf("Missing temperature data in request"), http.StatusBadRequest)
 		return
 	}
+	qs, ok := ss.(sensor.SettableQuantitySensor)
+	if !ok && !data.Quantities.Empty() {
+		writeError(w, fmt.Errorf("Sensor %s doesn't support quantities other than temperature", sensorID), http.StatusBadRequest)
+		return
+	}
 
-	ss.Set(*data.Temp, time.Now())
+	now := time.Now()
+	if data.Temp != nil {
+		ss.Set(*data.Temp, now)
+	}
+	if !data.Quantities.Empty() {
+		qs.SetQuantities(data.Quantities, now)
+	}
 
 	writeJSON(w, newJSONSensor(ss))
 }
 
+func (srv *WebServer) sensorPutCalibration(w http.ResponseWriter, req *http.Request) {
+	sensorID := mux.Vars(req)["sensor_id"]
+	s, ok := srv.controller.Sensor(sensorID)
+	if !ok {
+		write404(w)
+		return
+	}
+	cs, ok := s.(sensor.CalibratedSensor)
+	if !ok {
+		writeError(w, fmt.Errorf("Non-calibratable sensor %s", sensorID), http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Any values missing from the request are left unchanged.
+	cal := cs.Calibration()
+	err := json.NewDecoder(req.Body).Decode(&cal)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	err = srv.controller.SetSensorCalibration(sensorID, cal)
+	if err == controller.ErrInvalidCalibration {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, newJSONSensor(s))
+}
+
+func (srv *WebServer) sensorDiscover(w http.ResponseWriter, req *http.Request) {
+	devices, err := srv.controller.DiscoverW1Sensors()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t, err := template.ParseFiles(
+		filepath.Join(srv.templatesPath, "_base.tmpl"),
+		filepath.Join(srv.templatesPath, "sensor_discover.tmpl"),
+	)
+	if err != nil {
+		log.Println("Error parsing template:", err)
+		writeError(w, err)
+		return
+	}
+	err = t.Execute(w, struct{ Devices []sensor.W1Device }{Devices: devices})
+	if err != nil {
+		log.Println("Error executing template:", err)
+	}
+}
+
+func (srv *WebServer) sensorDiscoverJSON(w http.ResponseWriter, req *http.Request) {
+	devices, err := srv.controller.DiscoverW1Sensors()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, devices)
+}
+
+func (srv *WebServer) sensorAdopt(w http.ResponseWriter, req *http.Request) {
+	err := srv.controller.AdoptW1Sensor(req.FormValue("name"), req.FormValue("id"))
+	switch err {
+	case nil:
+	case controller.ErrInvalidSensorName, controller.ErrUnknownW1Device, controller.ErrSensorExists:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, req, "/sensors/discover", http.StatusFound)
+}
+
 type jsonSensor struct {
-	Temperature units.Temperature `json:"temperature"`
-	UpdatedAt   time.Time         `json:"updated_at"`
+	Temperature    units.Temperature   `json:"temperature"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	RawTemperature *units.Temperature  `json:"raw_temperature,omitempty"`
+	Calibration    *sensor.Calibration `json:"calibration,omitempty"`
+	Errors         *sensor.ReadErrors  `json:"errors,omitempty"`
+	Stale          *bool               `json:"stale,omitempty"`
+	sensor.Quantities
 }
 
 func newJSONSensor(s sensor.Sensor) *jsonSensor {
 	temperature, updatedAt := s.Read()
-	return &jsonSensor{
+	js := &jsonSensor{
 		Temperature: temperature,
 		UpdatedAt:   updatedAt,
 	}
+	if cs, ok := s.(sensor.CalibratedSensor); ok {
+		raw := cs.Raw()
+		cal := cs.Calibration()
+		js.RawTemperature = &raw
+		js.Calibration = &cal
+	}
+	if es, ok := s.(sensor.ErrorCountingSensor); ok {
+		errors := es.ReadErrors()
+		js.Errors = &errors
+	}
+	if qs, ok := s.(sensor.QuantitySensor); ok {
+		js.Quantities, _ = qs.ReadQuantities()
+	}
+	if sr, ok := s.(sensor.StaleReporter); ok {
+		stale := sr.Stale()
+		js.Stale = &stale
+	}
+	return js
 }
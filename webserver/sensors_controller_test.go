@@ -1,7 +1,10 @@
 package webserver_test
 
 import (
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -22,8 +25,22 @@ type dummySensor struct {
 func (s *dummySensor) Read() (units.Temperature, time.Time) {
 	return s.temp, s.updateTime
 }
-func (s *dummySensor) ID() string                          { return s.id }
-func (s *dummySensor) Subscribe() <-chan units.Temperature { return nil }
+func (s *dummySensor) ID() string                      { return s.id }
+func (s *dummySensor) Subscribe() *sensor.Subscription { return nil }
+func (s *dummySensor) Close()                          {}
+
+type erroringSensor struct {
+	dummySensor
+	errors sensor.ReadErrors
+}
+
+func (s *erroringSensor) ReadErrors() sensor.ReadErrors { return s.errors }
+
+type staleSensor struct {
+	dummySensor
+}
+
+func (s *staleSensor) Stale() bool { return true }
 
 var _ = Describe("sensors controller", func() {
 	var (
@@ -97,6 +114,30 @@ var _ = Describe("sensors controller", func() {
 			Expect(updated).To(BeTemporally("~", time.Now(), 100*time.Millisecond))
 		})
 
+		It("allows setting humidity and pressure by sensor ID", func() {
+			data := map[string]interface{}{
+				"temperatures": map[string]interface{}{"1234": 15643},
+				"humidities":   map[string]interface{}{"1234": 48500, "2345": 61250, "3456": 50000},
+				"pressures":    map[string]interface{}{"1234": 101325},
+			}
+			resp := doJSONPutRequest(server, "/sensors", data)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+
+			q, updated := s1.(sensor.QuantitySensor).ReadQuantities()
+			Expect(q.Humidity).NotTo(BeNil())
+			Expect(*q.Humidity).To(BeEquivalentTo(48500))
+			Expect(q.Pressure).NotTo(BeNil())
+			Expect(*q.Pressure).To(BeEquivalentTo(101325))
+			Expect(updated).To(BeTemporally("~", time.Now(), 100*time.Millisecond))
+
+			q, _ = s2.(sensor.QuantitySensor).ReadQuantities()
+			Expect(q.Humidity).NotTo(BeNil())
+			Expect(*q.Humidity).To(BeEquivalentTo(61250))
+			Expect(q.Pressure).To(BeNil())
+			_, updated = s2.Read()
+			Expect(updated.IsZero()).To(BeTrue())
+		})
+
 		It("ignores non-existent sensors in the input", func() {
 			data := map[string]interface{}{
 				"temperatures": map[string]interface{}{
@@ -161,6 +202,37 @@ var _ = Describe("sensors controller", func() {
 		})
 	})
 
+	Describe("reading a sensor with read errors", func() {
+		It("includes any read errors", func() {
+			errorAt := time.Now().Add(-time.Minute)
+			ctrl.AddSensor("two", &erroringSensor{errors: sensor.ReadErrors{
+				CRCFailures: 2,
+				LastError:   "CRC check failed",
+				LastErrorAt: errorAt,
+			}})
+
+			resp := doGetRequest(server, "/sensors/two")
+			data := decodeJsonResponse(resp)
+			errorAtStr, _ := errorAt.MarshalText()
+			Expect(data["errors"]).To(Equal(map[string]interface{}{
+				"crc_failures":  float64(2),
+				"read_failures": float64(0),
+				"last_error":    "CRC check failed",
+				"last_error_at": string(errorAtStr),
+			}))
+		})
+	})
+
+	It("includes whether a sensor reports itself stale", func() {
+		ctrl.AddSensor("one", &dummySensor{})
+		ctrl.AddSensor("two", &staleSensor{})
+
+		data := decodeJsonResponse(doGetRequest(server, "/sensors/one"))
+		Expect(data).NotTo(HaveKey("stale"))
+		data = decodeJsonResponse(doGetRequest(server, "/sensors/two"))
+		Expect(data["stale"]).To(Equal(true))
+	})
+
 	Describe("setting a sensor", func() {
 		var (
 			s1 sensor.SettableSensor
@@ -186,6 +258,27 @@ var _ = Describe("sensors controller", func() {
 			Expect(respData["temperature"]).To(BeEquivalentTo(15643))
 		})
 
+		It("updates the humidity and pressure, leaving the temperature unchanged if not given", func() {
+			data := map[string]interface{}{
+				"humidity": 48500,
+				"pressure": 101325,
+			}
+			resp := doJSONPutRequest(server, "/sensors/one", data)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			temp, _ := s1.Read()
+			Expect(temp).To(BeEquivalentTo(12345))
+
+			respData := decodeJsonResponse(resp)
+			Expect(respData["humidity"]).To(BeEquivalentTo(48500))
+			Expect(respData["pressure"]).To(BeEquivalentTo(101325))
+
+			data = map[string]interface{}{"humidity": 50000}
+			resp = doJSONPutRequest(server, "/sensors/one", data)
+			respData = decodeJsonResponse(resp)
+			Expect(respData["humidity"]).To(BeEquivalentTo(50000))
+			Expect(respData["pressure"]).To(BeEquivalentTo(101325))
+		})
+
 		It("returns a 400 for invalid data", func() {
 			data := map[string]interface{}{
 				"foo": "bar",
@@ -213,4 +306,78 @@ var _ = Describe("sensors controller", func() {
 		})
 	})
 
+	Describe("discovering sensors", func() {
+		It("returns the discovered sensors as JSON", func() {
+			// There's no 1-wire bus in the test environment.
+			resp := doGetRequest(server, "/sensors/discover.json")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(resp.Body.String()).To(MatchJSON(`[]`))
+		})
+
+		It("returns a 400 when adopting an unknown device", func() {
+			resp := doRequestWithValues(server, "POST", "/sensors/discover", url.Values{"name": {"loft"}, "id": {"28-0001"}})
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(ctrl.Sensors()).NotTo(HaveKey("loft"))
+		})
+	})
+
+	Describe("calibrating a sensor", func() {
+		var (
+			s1          sensor.SettableSensor
+			tempDataDir string
+		)
+
+		BeforeEach(func() {
+			tempDataDir, _ = ioutil.TempDir("", "sensors_controller_test")
+			controller.DataDir = tempDataDir
+			s1 = sensor.NewPushSensor("one", "something")
+			s1.Set(19000, time.Now().Add(-1*time.Minute))
+			ctrl.AddSensor("one", s1)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDataDir)
+		})
+
+		It("includes the raw value and calibration in the sensor data", func() {
+			s1.(sensor.CalibratedSensor).SetCalibration(sensor.Calibration{Offset: -500})
+
+			resp := doGetRequest(server, "/sensors/one")
+			data := decodeJsonResponse(resp)
+			Expect(data["temperature"]).To(BeEquivalentTo(18500))
+			Expect(data["raw_temperature"]).To(BeEquivalentTo(19000))
+			Expect(data["calibration"]).To(Equal(map[string]interface{}{"offset": float64(-500)}))
+		})
+
+		It("updates the calibration, leaving missing values unchanged", func() {
+			s1.(sensor.CalibratedSensor).SetCalibration(sensor.Calibration{Scale: 1.1})
+
+			resp := doJSONPutRequest(server, "/sensors/one/calibration", map[string]interface{}{"offset": 300})
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(s1.(sensor.CalibratedSensor).Calibration()).To(Equal(sensor.Calibration{Offset: 300, Scale: 1.1}))
+			temp, _ := s1.Read()
+			Expect(temp).To(BeEquivalentTo(21200))
+
+			respData := decodeJsonResponse(resp)
+			Expect(respData["temperature"]).To(BeEquivalentTo(21200))
+		})
+
+		It("returns a 400 for an invalid calibration", func() {
+			resp := doJSONPutRequest(server, "/sensors/one/calibration", map[string]interface{}{"scale": -1})
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(s1.(sensor.CalibratedSensor).Calibration()).To(Equal(sensor.Calibration{}))
+		})
+
+		It("returns 405 for a sensor that can't be calibrated", func() {
+			ctrl.AddSensor("two", &dummySensor{})
+			resp := doJSONPutRequest(server, "/sensors/two/calibration", map[string]interface{}{"offset": 300})
+			Expect(resp.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+
+		It("returns 404 for a non-existent sensor", func() {
+			resp := doJSONPutRequest(server, "/sensors/non-existent/calibration", map[string]interface{}{"offset": 300})
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		})
+	})
 })
@@ -0,0 +1,212 @@
+package webserver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/units"
+)
+
+// The period covered by the zone charts.
+const chartPeriod = 24 * time.Hour
+
+// Layout of the zone charts, in px.
+const (
+	chartWidth        = 720
+	chartHeight       = 240
+	chartMarginLeft   = 40
+	chartMarginRight  = 10
+	chartMarginTop    = 20
+	chartMarginBottom = 25
+)
+
+const (
+	chartCurrentColour = "#1f77b4"
+	chartTargetColour  = "#d62728"
+	chartActiveColour  = "#ffdca8"
+	chartGridColour    = "#e0e0e0"
+)
+
+// The range shown when there are no temperatures to chart.
+const (
+	chartDefaultMin units.Temperature = 15000
+	chartDefaultMax units.Temperature = 25000
+)
+
+func (srv *WebServer) zoneChart(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
+	to := time.Now()
+	from := to.Add(-chartPeriod)
+	var b bytes.Buffer
+	newZoneHistoryChart(z.History(from), z.Thermostat != nil, from, to).writeSVG(&b)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(b.Bytes())
+}
+
+// zoneHistoryChart renders a zone's history as an SVG chart, with the periods the
+// output was on shaded behind lines of the current and target temperatures.
+type zoneHistoryChart struct {
+	samples     []controller.ZoneSample
+	temperature bool
+	from, to    time.Time
+	min, max    units.Temperature
+}
+
+func newZoneHistoryChart(samples []controller.ZoneSample, temperature bool, from, to time.Time) *zoneHistoryChart {
+	c := &zoneHistoryChart{samples: samples, temperature: temperature, from: from, to: to}
+	c.min, c.max = chartDefaultMin, chartDefaultMax
+	if temperature && len(samples) > 0 {
+		c.min, c.max = samples[0].Target, samples[0].Target
+		for _, s := range samples {
+			temps := []units.Temperature{s.Target}
+			if !s.NoReading {
+				temps = append(temps, s.Current)
+			}
+			for _, t := range temps {
+				if t < c.min {
+					c.min = t
+				}
+				if t > c.max {
+					c.max = t
+				}
+			}
+		}
+		// Pad to the whole degree beyond the range.
+		c.min = (c.min/1000 - 1) * 1000
+		c.max = (c.max/1000 + 1) * 1000
+	}
+	return c
+}
+
+func (c *zoneHistoryChart) x(t time.Time) float64 {
+	if t.Before(c.from) {
+		t = c.from
+	}
+	plotWidth := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	return chartMarginLeft + plotWidth*float64(t.Sub(c.from))/float64(c.to.Sub(c.from))
+}
+
+func (c *zoneHistoryChart) y(temp units.Temperature) float64 {
+	plotHeight := float64(chartHeight - chartMarginTop - chartMarginBottom)
+	return chartMarginTop + plotHeight*float64(c.max-temp)/float64(c.max-c.min)
+}
+
+// end returns the time the i'th sample applies until.
+func (c *zoneHistoryChart) end(i int) time.Time {
+	if i+1 < len(c.samples) {
+		return c.samples[i+1].At
+	}
+	return c.to
+}
+
+func (c *zoneHistoryChart) writeSVG(w io.Writer) {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	c.writeActive(w)
+	c.writeAxes(w)
+	if c.temperature {
+		c.writeTarget(w)
+		c.writeCurrent(w)
+	}
+	c.writeLegend(w)
+	fmt.Fprintln(w, `</svg>`)
+}
+
+func (c *zoneHistoryChart) writeActive(w io.Writer) {
+	for i, s := range c.samples {
+		if !s.Active {
+			continue
+		}
+		x1, x2 := c.x(s.At), c.x(c.end(i))
+		fmt.Fprintf(w, `<rect class="active" x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`+"\n",
+			x1, chartMarginTop, x2-x1, chartHeight-chartMarginTop-chartMarginBottom, chartActiveColour)
+	}
+}
+
+func (c *zoneHistoryChart) writeAxes(w io.Writer) {
+	left, right := float64(chartMarginLeft), float64(chartWidth-chartMarginRight)
+	bottom := float64(chartHeight - chartMarginBottom)
+
+	if c.temperature {
+		step := units.Temperature(1000)
+		for (c.max-c.min)/step > 8 {
+			step *= 2
+		}
+		for t := (c.min + step - 1) / step * step; t <= c.max; t += step {
+			y := c.y(t)
+			fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", left, y, right, y, chartGridColour)
+			fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n", left-4, y, t)
+		}
+	}
+
+	// Label every 3 hours, on the hour in local time.
+	from := c.from.Local()
+	tick := time.Date(from.Year(), from.Month(), from.Day(), from.Hour()/3*3, 0, 0, 0, from.Location())
+	for ; !tick.After(c.to); tick = tick.Add(3 * time.Hour) {
+		if tick.Before(c.from) {
+			continue
+		}
+		x := c.x(tick)
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x, chartMarginTop, x, bottom, chartGridColour)
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", x, bottom+15, tick.Format("15:04"))
+	}
+	fmt.Fprintf(w, `<rect x="%.1f" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#888"/>`+"\n",
+		left, chartMarginTop, right-left, bottom-chartMarginTop)
+}
+
+func (c *zoneHistoryChart) writeTarget(w io.Writer) {
+	if len(c.samples) == 0 {
+		return
+	}
+	var path bytes.Buffer
+	for i, s := range c.samples {
+		if i == 0 {
+			fmt.Fprintf(&path, "M%.1f %.1f", c.x(s.At), c.y(s.Target))
+		} else {
+			fmt.Fprintf(&path, " V%.1f", c.y(s.Target))
+		}
+		fmt.Fprintf(&path, " H%.1f", c.x(c.end(i)))
+	}
+	fmt.Fprintf(w, `<path class="target" d="%s" fill="none" stroke="%s" stroke-dasharray="4 2"/>`+"\n", path.String(), chartTargetColour)
+}
+
+// writeCurrent draws the current temperature, with a gap wherever there was no
+// reading.
+func (c *zoneHistoryChart) writeCurrent(w io.Writer) {
+	var points bytes.Buffer
+	flush := func() {
+		if points.Len() > 0 {
+			fmt.Fprintf(w, `<polyline class="current" points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n", points.String(), chartCurrentColour)
+			points.Reset()
+		}
+	}
+	for _, s := range c.samples {
+		if s.NoReading {
+			flush()
+			continue
+		}
+		if points.Len() > 0 {
+			points.WriteByte(' ')
+		}
+		fmt.Fprintf(&points, "%.1f,%.1f", c.x(s.At), c.y(s.Current))
+	}
+	flush()
+}
+
+func (c *zoneHistoryChart) writeLegend(w io.Writer) {
+	type entry struct{ label, colour string }
+	entries := []entry{{"heating", chartActiveColour}}
+	if c.temperature {
+		entries = append(entries, entry{"current", chartCurrentColour}, entry{"target", chartTargetColour})
+	}
+	x := chartMarginLeft
+	for _, e := range entries {
+		fmt.Fprintf(w, `<rect x="%d" y="5" width="10" height="10" fill="%s"/>`+"\n", x, e.colour)
+		fmt.Fprintf(w, `<text x="%d" y="14">%s</text>`+"\n", x+14, e.label)
+		x += 70
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"time"
 
 	"github.com/alext/heating-controller/controller"
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/thermostat"
+	"github.com/alext/heating-controller/units"
 )
 
 func (srv *WebServer) zonesIndex(w http.ResponseWriter, req *http.Request) {
@@ -22,8 +25,15 @@ func (srv *WebServer) zonesIndex(w http.ResponseWriter, req *http.Request) {
 		writeError(w, err)
 		return
 	}
+	data := struct {
+		Zones map[string]*controller.Zone
+		Away  *controller.AwayMode
+	}{
+		Zones: srv.controller.Zones,
+		Away:  srv.controller.Away(),
+	}
 	var b bytes.Buffer
-	err = t.Execute(&b, srv.controller.Zones)
+	err = t.Execute(&b, data)
 	if err != nil {
 		log.Println("Error executing template:", err)
 		writeError(w, err)
@@ -33,13 +43,23 @@ func (srv *WebServer) zonesIndex(w http.ResponseWriter, req *http.Request) {
 }
 
 type jsonZone struct {
-	Active bool `json:"active"`
+	Active           bool                  `json:"active"`
+	Away             bool                  `json:"away"`
+	PID              *thermostat.PIDTerms  `json:"pid,omitempty"`
+	ThermostatInputs []sensor.InputReading `json:"thermostat_inputs,omitempty"`
 }
 
 func newJSONZone(z *controller.Zone) *jsonZone {
-	return &jsonZone{
-		Active: z.Active(),
+	jz := &jsonZone{
+		Active:           z.Active(),
+		Away:             z.Away(),
+		ThermostatInputs: z.ThermostatInputs(),
+	}
+	if t, ok := z.Thermostat.(thermostat.PIDThermostat); ok {
+		terms := t.PIDTerms()
+		jz.PID = &terms
 	}
+	return jz
 }
 
 func (srv *WebServer) zonesAPIIndex(w http.ResponseWriter, req *http.Request) {
@@ -98,3 +118,46 @@ func (srv *WebServer) thermostatDec(w http.ResponseWriter, req *http.Request, z
 	}
 	http.Redirect(w, req, "/", http.StatusFound)
 }
+
+func (srv *WebServer) thermostatSetHysteresis(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
+	if !z.UsesHysteresis() {
+		write404(w)
+		return
+	}
+	lower, err := units.ParseTemperature(req.FormValue("lower"))
+	if err != nil {
+		http.Error(w, "lower must be a number: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	upper, err := units.ParseTemperature(req.FormValue("upper"))
+	if err != nil {
+		http.Error(w, "upper must be a number: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	h := thermostat.Hysteresis{Lower: lower, Upper: upper}
+	if !h.Valid() {
+		http.Error(w, "hysteresis must not be negative", http.StatusBadRequest)
+		return
+	}
+	z.SetThermostatHysteresis(h)
+	err = z.Save()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, req, "/", http.StatusFound)
+}
+
+func (srv *WebServer) thermostatResetHysteresis(w http.ResponseWriter, req *http.Request, z *controller.Zone) {
+	if !z.UsesHysteresis() {
+		write404(w)
+		return
+	}
+	z.ResetThermostatHysteresis()
+	err := z.Save()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, req, "/", http.StatusFound)
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/alext/heating-controller/controller"
 	"github.com/alext/heating-controller/controller/controllerfakes"
 	"github.com/alext/heating-controller/output"
+	"github.com/alext/heating-controller/sensor"
+	"github.com/alext/heating-controller/thermostat"
 	"github.com/alext/heating-controller/thermostat/thermostatfakes"
 	"github.com/alext/heating-controller/webserver"
 )
@@ -54,9 +56,52 @@ var _ = Describe("zones controller", func() {
 			Expect(data).To(HaveKey("two"))
 			data1 := data["one"].(map[string]interface{})
 			Expect(data1["active"]).To(BeTrue())
+			Expect(data1["away"]).To(BeFalse())
 			data2 := data["two"].(map[string]interface{})
 			Expect(data2["active"]).To(BeFalse())
 		})
+
+		It("includes the thermostat inputs for zones combining several sensors", func() {
+			s1 := sensor.NewPushSensor("s1", "1")
+			s1.Set(19000, time.Now())
+			s2 := sensor.NewPushSensor("s2", "2")
+			s2.Set(20000, time.Now())
+			agg, err := sensor.NewAggregateSensor("agg", sensor.AggregateMean, []sensor.AggregateInput{
+				{Name: "s1", Sensor: s1},
+				{Name: "s2", Sensor: s2},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			ctrl.Zones["one"].SetupThermostat(agg, 20000)
+			defer ctrl.Zones["one"].Thermostat.Close()
+
+			data := decodeJsonResponse(doGetRequest(server, "/zones"))
+			data1 := data["one"].(map[string]interface{})
+			inputs := data1["thermostat_inputs"].([]interface{})
+			Expect(inputs).To(HaveLen(2))
+			Expect(inputs[0]).To(HaveKeyWithValue("name", "s1"))
+			Expect(inputs[0]).To(HaveKeyWithValue("temperature", 19000.0))
+			Expect(inputs[0]).To(HaveKeyWithValue("excluded", false))
+			data2 := data["two"].(map[string]interface{})
+			Expect(data2).NotTo(HaveKey("thermostat_inputs"))
+		})
+
+		It("includes the PID terms for zones with a PID thermostat", func() {
+			s := sensor.NewPushSensor("one", "1234")
+			s.Set(19000, time.Now())
+			ctrl.Zones["one"].SetupPIDThermostat(s, 20000, thermostat.PIDSettings{CycleLength: time.Hour, Kp: 0.5})
+			defer ctrl.Zones["one"].Thermostat.Close()
+
+			data := decodeJsonResponse(doGetRequest(server, "/zones"))
+			data1 := data["one"].(map[string]interface{})
+			Expect(data1["pid"]).To(Equal(map[string]interface{}{
+				"p":      0.5,
+				"i":      0.0,
+				"d":      0.0,
+				"output": 0.5,
+			}))
+			data2 := data["two"].(map[string]interface{})
+			Expect(data2).NotTo(HaveKey("pid"))
+		})
 	})
 
 	Describe("boosting", func() {
@@ -176,6 +221,67 @@ var _ = Describe("zones controller", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(data["thermostat_target"]).To(BeNumerically("==", 19500))
 			})
+
+			Describe("setting the hysteresis", func() {
+				It("sets the hysteresis and redirects back", func() {
+					w := doRequestWithValues(server, "POST", "/zones/one/thermostat/hysteresis", url.Values{
+						"lower": {"0.3"},
+						"upper": {"0.1"},
+					})
+
+					Expect(w.Code).To(Equal(302))
+					Expect(w.Header().Get("Location")).To(Equal("/"))
+
+					Expect(ts.SetHysteresisCallCount()).To(Equal(1))
+					Expect(ts.SetHysteresisArgsForCall(0)).To(Equal(thermostat.Hysteresis{Lower: 300, Upper: 100}))
+				})
+
+				It("saves the zone state", func() {
+					ts.HysteresisReturns(thermostat.Hysteresis{Lower: 300, Upper: 100})
+
+					doRequestWithValues(server, "POST", "/zones/one/thermostat/hysteresis", url.Values{
+						"lower": {"0.3"},
+						"upper": {"0.1"},
+					})
+
+					data := readFile(controller.DataDir + "/one.json")
+					Expect(data).To(ContainSubstring(`"thermostat_hysteresis"`))
+				})
+
+				It("returns an error for invalid values", func() {
+					w := doRequestWithValues(server, "POST", "/zones/one/thermostat/hysteresis", url.Values{
+						"lower": {"foo"},
+						"upper": {"0.1"},
+					})
+					Expect(w.Code).To(Equal(400))
+
+					w = doRequestWithValues(server, "POST", "/zones/one/thermostat/hysteresis", url.Values{
+						"lower": {"-0.5"},
+						"upper": {"0.1"},
+					})
+					Expect(w.Code).To(Equal(400))
+
+					Expect(ts.SetHysteresisCallCount()).To(Equal(0))
+				})
+			})
+
+			Describe("resetting the hysteresis", func() {
+				It("reverts to the configured hysteresis and saves the zone state", func() {
+					doRequestWithValues(server, "POST", "/zones/one/thermostat/hysteresis", url.Values{
+						"lower": {"0.3"},
+						"upper": {"0.1"},
+					})
+
+					w := doRequest(server, "POST", "/zones/one/thermostat/hysteresis/reset")
+					Expect(w.Code).To(Equal(302))
+					Expect(w.Header().Get("Location")).To(Equal("/"))
+
+					Expect(ts.SetHysteresisCallCount()).To(Equal(2))
+					Expect(ts.SetHysteresisArgsForCall(1)).To(Equal(thermostat.DefaultHysteresis))
+					data := readFile(controller.DataDir + "/one.json")
+					Expect(data).NotTo(ContainSubstring(`"thermostat_hysteresis"`))
+				})
+			})
 		})
 
 		Context("for a zone without a thermostat configured", func() {
@@ -189,6 +295,98 @@ var _ = Describe("zones controller", func() {
 				w := doRequest(server, "POST", "/zones/one/thermostat/decrement")
 				Expect(w.Code).To(Equal(404))
 			})
+
+			It("should 404 on setting the hysteresis", func() {
+				w := doRequestWithValues(server, "POST", "/zones/one/thermostat/hysteresis", url.Values{
+					"lower": {"0.3"},
+					"upper": {"0.1"},
+				})
+				Expect(w.Code).To(Equal(404))
+			})
+		})
+
+		Context("for a zone with a duty cycle thermostat", func() {
+			BeforeEach(func() {
+				s := sensor.NewPushSensor("foo", "1234")
+				s.Set(19000, time.Now())
+				zone1.SetupTPIThermostat(s, 20000, thermostat.DefaultTPISettings)
+			})
+			AfterEach(func() {
+				zone1.Thermostat.Close()
+			})
+
+			It("should 404 on setting or resetting the hysteresis", func() {
+				w := doRequestWithValues(server, "POST", "/zones/one/thermostat/hysteresis", url.Values{
+					"lower": {"0.3"},
+					"upper": {"0.1"},
+				})
+				Expect(w.Code).To(Equal(404))
+
+				w = doRequest(server, "POST", "/zones/one/thermostat/hysteresis/reset")
+				Expect(w.Code).To(Equal(404))
+				Expect(zone1.HysteresisOverridden()).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("history chart", func() {
+		var zone1 *controller.Zone
+
+		BeforeEach(func() {
+			zone1 = controller.NewZone("one", output.Virtual("one"))
+			ctrl.AddZone(zone1)
+		})
+
+		It("charts the temperatures and output of a zone with a thermostat", func() {
+			s := sensor.NewPushSensor("foo", "1234")
+			s.Set(19000, time.Now())
+			zone1.SetupThermostat(s, 20000)
+			zone1.Boost(0)
+
+			w := doGetRequest(server, "/zones/one/chart.svg")
+			Expect(w.Code).To(Equal(200))
+			Expect(w.Header().Get("Content-Type")).To(Equal("image/svg+xml"))
+			body := w.Body.String()
+			Expect(body).To(HavePrefix("<svg "))
+			Expect(body).To(ContainSubstring(`<rect class="active"`))
+			Expect(body).To(ContainSubstring(`<polyline class="current"`))
+			Expect(body).To(ContainSubstring(`<path class="target"`))
+			Expect(body).To(ContainSubstring(">20°C</text>"))
+		})
+
+		It("leaves out the current temperature while there's no reading", func() {
+			s := sensor.NewPushSensor("foo", "1234")
+			zone1.SetupThermostat(s, 20000)
+			zone1.Boost(0)
+
+			w := doGetRequest(server, "/zones/one/chart.svg")
+			body := w.Body.String()
+			Expect(body).To(ContainSubstring(`<path class="target"`))
+			Expect(body).NotTo(ContainSubstring(`class="current"`))
+			// The scale isn't stretched down to 0°C.
+			Expect(body).NotTo(ContainSubstring(">0°C</text>"))
+		})
+
+		It("charts only the output of a zone without a thermostat", func() {
+			zone1.Boost(0)
+
+			w := doGetRequest(server, "/zones/one/chart.svg")
+			Expect(w.Code).To(Equal(200))
+			body := w.Body.String()
+			Expect(body).To(ContainSubstring(`<rect class="active"`))
+			Expect(body).NotTo(ContainSubstring(`class="current"`))
+			Expect(body).NotTo(ContainSubstring(`class="target"`))
+		})
+
+		It("returns an empty chart for a zone with no history", func() {
+			w := doGetRequest(server, "/zones/one/chart.svg")
+			Expect(w.Code).To(Equal(200))
+			Expect(w.Body.String()).NotTo(ContainSubstring(`class="active"`))
+		})
+
+		It("should 404 for a non-existent zone", func() {
+			w := doGetRequest(server, "/zones/non-existent/chart.svg")
+			Expect(w.Code).To(Equal(404))
 		})
 	})
 })